@@ -0,0 +1,465 @@
+// Package api serves the scraped terms over HTTP, with the same data also
+// available over GraphQL and gRPC, and runs the scrapes that keep them
+// current.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	termstore "scrape_cp/store"
+)
+
+// route is one entry in the API's routing table. Both the router and the
+// OpenAPI document are built from the table so they can't drift apart.
+type route struct {
+	path    string
+	method  string
+	handler http.HandlerFunc
+
+	summary string
+	params  []apiParam
+	// request and response are zero values of the body types, used to
+	// derive schemas; produces lists the media types of non-JSON bodies.
+	request  interface{}
+	response interface{}
+	produces []string
+	// notFound is the body of a 404 that carries more than the usual
+	// error.
+	notFound interface{}
+
+	// streaming routes keep writing for as long as they need to, so they
+	// are exempt from RequestTimeout and the server's write timeout.
+	streaming bool
+}
+
+// serve returns the route's handler with the deadlines that suit it.
+func (rt route) serve() http.HandlerFunc {
+	if rt.streaming {
+		return withoutWriteDeadline(rt.handler)
+	}
+	return withDeadline(rt.handler)
+}
+
+// apiParam documents a query parameter. Path parameters are taken from
+// the route's path.
+type apiParam struct {
+	name        string
+	kind        string
+	description string
+	required    bool
+}
+
+var (
+	paramLimit  = apiParam{name: "limit", kind: "integer", description: "maximum number of results to return"}
+	paramOffset = apiParam{name: "offset", kind: "integer", description: "number of results to skip"}
+	paramSource = apiParam{name: "source", kind: "string", description: "only terms whose definition came from this source, ignoring case; one of the names /sources lists, or another source that supplied terms"}
+	paramEnrich = apiParam{name: "enrich", kind: "boolean", description: "look missing or thin terms up on Wikipedia and keep what it says"}
+	paramFormat = apiParam{name: "format", kind: "string", description: "response format (json, xml or yaml), overriding the Accept header"}
+	paramMinLen = apiParam{name: "min_len", kind: "integer", description: "only terms whose definition is at least this many characters long"}
+	paramMaxLen = apiParam{name: "max_len", kind: "integer", description: "only terms whose definition is at most this many characters long; 0 sets no limit"}
+)
+
+// apiRoutes lists the API endpoints relative to a version prefix. Fixed
+// paths must come before the {term} patterns that would otherwise
+// swallow them.
+var apiRoutes = []route{
+	{
+		path: "/health", method: http.MethodGet, handler: getHealth,
+		summary:  "Report whether the service is up and has terms to serve; 503 until it does",
+		response: HealthResponse{},
+	},
+	{
+		path: "/graphql", method: http.MethodPost, handler: requireTerms(postGraphQL),
+		summary:  "Run a GraphQL query against the terms",
+		request:  GraphQLRequest{},
+		produces: []string{"application/json"},
+	},
+	{
+		path: "/refresh", method: http.MethodPost, handler: requireWriteKey(refreshTerms),
+		summary: "Scrape every source again in the background; needs the write API key as a bearer token",
+		params: []apiParam{
+			{name: "force", kind: "boolean", description: "download sources even if they report no changes"},
+		},
+		response: RefreshResponse{},
+	},
+	{
+		path: "/import", method: http.MethodPost, handler: requireWriteKey(importTerms),
+		summary: "Merge a glossary into the terms, given as a map of names to definitions or a list of terms; needs the write API key as a bearer token",
+		params: []apiParam{
+			{name: "dry_run", kind: "boolean", description: "report what the import would do without changing the terms"},
+		},
+		request:  []termstore.Term{},
+		response: ImportResponse{},
+	},
+	{
+		path: "/refresh/last-diff", method: http.MethodGet, handler: getLastDiff,
+		summary:  "Get the terms the latest refresh added, removed or changed",
+		response: ScrapeDiff{},
+	},
+	{
+		path: "/refresh/events", method: http.MethodGet, handler: streamRefreshEvents,
+		summary:   "Follow refresh progress as Server-Sent Events carrying ScrapeEvent data",
+		produces:  []string{"text/event-stream"},
+		streaming: true,
+	},
+	{
+		path: "/stats", method: http.MethodGet, handler: getStats,
+		summary:  "Report the term count and how the latest scrape of each source went",
+		response: StatsResponse{},
+	},
+	{
+		path: "/stats/definitions", method: http.MethodGet, handler: requireTerms(getDefinitionStats),
+		summary:  "Describe the lengths of the definitions and how the terms spread over the alphabet",
+		response: termstore.DefinitionStats{},
+	},
+	{
+		path: "/sources", method: http.MethodGet, handler: getSources,
+		summary:  "List the sources scraped and the request settings each one uses",
+		response: SourcesResponse{},
+	},
+	{
+		path: "/snapshots", method: http.MethodGet, handler: getSnapshots,
+		summary:  "List the saved snapshots of the terms, newest first",
+		response: SnapshotsResponse{},
+	},
+	{
+		path: "/snapshots/compare", method: http.MethodGet, handler: compareSnapshots,
+		summary: "Compare two snapshots",
+		params: []apiParam{
+			{name: "from", kind: "string", description: "timestamp of the earlier snapshot", required: true},
+			{name: "to", kind: "string", description: "timestamp of the later snapshot", required: true},
+		},
+		response: SnapshotComparison{},
+	},
+	{
+		path: "/terms", method: http.MethodGet, handler: requireTerms(getAllTerms),
+		summary: "List terms",
+		params: []apiParam{
+			{name: "sort", kind: "string", description: "alpha, alpha_desc, length or recent"},
+			paramSource, paramMinLen, paramMaxLen, paramLimit, paramOffset, paramFormat,
+		},
+		response: TermsResponse{},
+	},
+	{
+		path: "/terms/search", method: http.MethodGet, handler: requireTerms(searchTerms),
+		summary: "Search terms and definitions",
+		params: []apiParam{
+			{name: "q", kind: "string", description: "search query, at most 200 characters, or 50 when fuzzy", required: true},
+			{name: "fields", kind: "string", description: "term, definition or both"},
+			{name: "op", kind: "string", description: "and to match entries containing every word or quoted phrase of the query, or to match any of them (default and)"},
+			{name: "exact", kind: "boolean", description: "match whole words only"},
+			{name: "stem", kind: "boolean", description: "also match words with the same stem as a word of the query, so compiling finds Compiler; never applies to quoted phrases or exact searches (default set by the server)"},
+			{name: "fuzzy", kind: "boolean", description: "match term names within a small edit distance"},
+			{name: "distance", kind: "integer", description: "maximum edit distance for fuzzy matching"},
+			{name: "pre_tag", kind: "string", description: "marker inserted before highlighted matches"},
+			{name: "post_tag", kind: "string", description: "marker inserted after highlighted matches"},
+			paramSource, paramMinLen, paramMaxLen, paramLimit, paramOffset, paramFormat,
+		},
+		response: SearchResponse{},
+	},
+	{
+		path: "/terms/stream", method: http.MethodGet, handler: streamTerms,
+		summary:   "Stream additions and updates as JSON TermEvent messages over a WebSocket",
+		streaming: true,
+	},
+	{
+		path: "/terms/random", method: http.MethodGet, handler: requireTerms(getRandomTerms),
+		summary: "Pick random terms",
+		params: []apiParam{
+			{name: "count", kind: "integer", description: "number of distinct terms to return; without it a single TermResponse is returned"},
+			{name: "seed", kind: "string", description: "makes the selection reproducible"},
+		},
+		response: RandomResponse{},
+	},
+	{
+		path: "/quiz", method: http.MethodGet, handler: requireTerms(getQuiz),
+		summary: "Make a multiple-choice quiz: each question is a definition and four terms to pick it from",
+		params: []apiParam{
+			{name: "count", kind: "integer", description: "number of questions, each about a different term (default 10)"},
+			{name: "q", kind: "string", description: "only ask about terms matching this search query"},
+		},
+		response: QuizResponse{},
+	},
+	{
+		path: "/quiz/answer", method: http.MethodPost, handler: requireTerms(answerQuiz),
+		summary:  "Check the choice made for a quiz question against its token",
+		request:  QuizAnswer{},
+		response: QuizResult{},
+	},
+	{
+		path: "/terms/today", method: http.MethodGet, handler: requireTerms(getTermOfTheDay),
+		summary: "Get the term of the day",
+		params: []apiParam{
+			{name: "date", kind: "string", description: "day to pick for, as YYYY-MM-DD"},
+		},
+		response: TodayResponse{},
+	},
+	{
+		path: "/terms/suggest", method: http.MethodGet, handler: requireTerms(suggestTerms),
+		summary: "Autocomplete term names by prefix",
+		params: []apiParam{
+			{name: "q", kind: "string", description: "prefix to complete, at most 200 characters", required: true},
+			paramLimit,
+		},
+		response: SuggestResponse{},
+	},
+	{
+		path: "/terms/names", method: http.MethodGet, handler: requireTerms(getTermNames),
+		summary: "List term names alone, in alphabetical order",
+		params: []apiParam{
+			{name: "prefix", kind: "string", description: "only names starting with this, ignoring case"},
+			paramSource, paramLimit, paramOffset,
+		},
+		response: []string{},
+	},
+	{
+		path: "/terms/letters", method: http.MethodGet, handler: requireTerms(getLetters),
+		summary:  "List the letters that have terms",
+		response: LettersResponse{},
+	},
+	{
+		path: "/terms/letter/{letter}", method: http.MethodGet, handler: requireTerms(getTermsByLetter),
+		summary:  "List the terms starting with a letter",
+		response: LetterResponse{},
+	},
+	{
+		path: "/terms/lookup", method: http.MethodPost, handler: requireTerms(lookupTerms),
+		summary:  "Look up many terms at once",
+		params:   []apiParam{paramEnrich},
+		request:  []string{},
+		response: []LookupResult{},
+	},
+	{
+		path: "/terms/{term}", method: http.MethodGet, handler: requireTerms(getTerm),
+		summary: "Get a term",
+		params: []apiParam{
+			{name: "linkify", kind: "string", description: "html to return the definition as HTML linking the other terms it mentions"},
+			paramEnrich, paramFormat,
+		},
+		response: termstore.TermResponse{},
+		notFound: TermNotFoundResponse{},
+	},
+	{
+		path: "/terms/{term}/related", method: http.MethodGet, handler: requireTerms(getRelatedTerms),
+		summary:  "Find terms related through their definitions",
+		params:   []apiParam{paramLimit},
+		response: RelatedResponse{},
+		notFound: TermNotFoundResponse{},
+	},
+	{
+		path: "/export", method: http.MethodGet, handler: requireTerms(exportTerms),
+		summary: "Export every term as a file",
+		params: []apiParam{
+			{name: "format", kind: "string", description: "csv, tsv, markdown, anki, or zip for a bundle of JSON, CSV and Markdown with a manifest"},
+			{name: "q", kind: "string", description: "only export terms matching this query"},
+			paramMinLen, paramMaxLen,
+		},
+		produces:  []string{"text/csv", "text/tab-separated-values", "text/markdown", "text/plain", "application/zip"},
+		streaming: true,
+	},
+}
+
+// rootRoutes are served once, outside the versioned prefixes.
+var rootRoutes = []route{
+	{
+		path: "/api/versions", method: http.MethodGet, handler: getVersions,
+		summary:  "List the available API versions",
+		response: VersionsResponse{},
+	},
+	{
+		path: "/api/openapi.json", method: http.MethodGet, handler: getOpenAPI,
+		summary:  "Get this OpenAPI document",
+		produces: []string{"application/json"},
+	},
+	{
+		path: "/feed.xml", method: http.MethodGet, handler: requireTerms(getFeed),
+		summary:  "Atom feed of the terms added or changed by the latest scrape",
+		produces: []string{"application/atom+xml"},
+	},
+	{
+		path: "/metrics", method: http.MethodGet, handler: promhttp.Handler().ServeHTTP,
+		summary:  "Get Prometheus metrics",
+		produces: []string{"text/plain"},
+	},
+}
+
+// apiVersion is a mounted version of the API. Versions share handlers and
+// differ in the encoders their responses go through.
+type apiVersion struct {
+	name    string
+	prefix  string
+	formats []responseFormat
+	// successor is set on deprecated mounts and names the version that
+	// replaces them
+	successor *apiVersion
+}
+
+var apiV1 = &apiVersion{name: "v1", prefix: "/api/v1", formats: responseFormats}
+
+// The unversioned /api prefix predates versioning. It serves v1 so
+// existing clients keep working, but marks every response deprecated.
+var apiLegacy = &apiVersion{name: "v1", prefix: "/api", formats: responseFormats, successor: apiV1}
+
+// apiMounts is every prefix the API is served under, most specific first
+// so /api doesn't shadow /api/v1.
+var apiMounts = []*apiVersion{apiV1, apiLegacy}
+
+type apiVersionKey struct{}
+
+// versionOf returns the API version a request came in through.
+func versionOf(r *http.Request) *apiVersion {
+	if v, ok := r.Context().Value(apiVersionKey{}).(*apiVersion); ok {
+		return v
+	}
+	return apiV1
+}
+
+type storeKey struct{}
+
+// storeOf returns the store a request is served from.
+func storeOf(ctx context.Context) termstore.TermStore {
+	return ctx.Value(storeKey{}).(termstore.TermStore)
+}
+
+// NewRouter returns a router serving every endpoint from store, without
+// the logging, metrics and rate limiting StartServer adds around it.
+func NewRouter(store termstore.TermStore) *mux.Router {
+	// Routes match the path as sent, so a term such as TCP%2FIP stays one
+	// segment; handlers unescape it with pathVar
+	router := mux.NewRouter().UseEncodedPath()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), storeKey{}, store)))
+		})
+	})
+
+	// API endpoints are served under /api/v1, and under /api for clients
+	// that predate versioning
+	registerAPI(router)
+	return router
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, "no such endpoint")
+}
+
+// routeMethods are the methods any route is registered for, sorted.
+var routeMethods []string
+
+// handleOtherMethods registers handler for the path of each of routes,
+// once they are all registered, to take the requests none of them accept.
+// mux's MethodNotAllowedHandler can't be used instead, as a later route
+// under the same subrouter makes it answer 404.
+func handleOtherMethods(r *mux.Router, routes []route, handler http.Handler) {
+	var paths []string
+	for _, rt := range routes {
+		if !slices.Contains(paths, rt.path) {
+			paths = append(paths, rt.path)
+			r.Handle(rt.path, handler)
+		}
+	}
+}
+
+// methodNotAllowed answers requests for a path whose routes don't take
+// their method with the methods they do take, in the Allow header. OPTIONS
+// requests get just that; anything else is an error.
+func methodNotAllowed(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(router, r)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeErrorDetails(w, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+			fmt.Sprintf("method %s is not allowed here; allowed: %s", r.Method, strings.Join(allowed, ", ")),
+			map[string]any{"allowed": allowed})
+	})
+}
+
+// allowedMethods lists the methods router has a route for at r's path,
+// along with OPTIONS.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		req := r.Clone(r.Context())
+		req.Method = method
+		var match mux.RouteMatch
+		if !router.Match(req, &match) || match.MatchErr != nil {
+			continue
+		}
+		// The route handleOtherMethods added matches too, but takes no
+		// method in particular
+		if methods, err := match.Route.GetMethods(); err == nil && slices.Contains(methods, method) {
+			allowed = append(allowed, method)
+		}
+	}
+	return append(allowed, http.MethodOptions)
+}
+
+// registerAPI mounts every route of the API under each version prefix.
+func registerAPI(router *mux.Router) {
+	routeMethods = nil
+	for _, rt := range slices.Concat(rootRoutes, apiRoutes) {
+		if !slices.Contains(routeMethods, rt.method) {
+			routeMethods = append(routeMethods, rt.method)
+		}
+	}
+	slices.Sort(routeMethods)
+
+	for _, rt := range rootRoutes {
+		router.HandleFunc(rt.path, rt.serve()).Methods(rt.method)
+	}
+
+	otherMethods := methodNotAllowed(router)
+	handleOtherMethods(router, rootRoutes, otherMethods)
+
+	for _, version := range apiMounts {
+		sub := router.PathPrefix(version.prefix).Subrouter()
+		sub.Use(version.middleware)
+		for _, rt := range apiRoutes {
+			sub.HandleFunc(rt.path, rt.serve()).Methods(rt.method)
+		}
+		handleOtherMethods(sub, apiRoutes, otherMethods)
+	}
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+
+	openAPISpec = buildOpenAPI()
+}
+
+func (v *apiVersion) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v.successor != nil {
+			successorPath := v.successor.prefix + strings.TrimPrefix(r.URL.EscapedPath(), v.prefix)
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successorPath+`>; rel="successor-version"`)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, v)))
+	})
+}
+
+type VersionInfo struct {
+	Version    string `json:"version"`
+	Path       string `json:"path"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+type VersionsResponse struct {
+	Versions []VersionInfo `json:"versions"`
+	Current  string        `json:"current"`
+}
+
+func getVersions(w http.ResponseWriter, r *http.Request) {
+	versions := make([]VersionInfo, len(apiMounts))
+	for i, v := range apiMounts {
+		versions[i] = VersionInfo{Version: v.name, Path: v.prefix, Deprecated: v.successor != nil}
+	}
+	writeJSON(w, http.StatusOK, VersionsResponse{Versions: versions, Current: apiV1.name})
+}
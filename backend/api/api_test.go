@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the output the tests got")
+
+// checkGolden compares got with testdata/name, or rewrites the file with
+// it when the tests are run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update if the change is intended):\n%s", path, got)
+	}
+}
+
+// newTestStore returns a store holding terms, as scraped from one source.
+func newTestStore(t testing.TB, terms map[string]string) *termstore.MemoryStore {
+	t.Helper()
+	store := termstore.NewMemoryStore()
+	scraped := make(map[string][]string, len(terms))
+	for name, def := range terms {
+		scraped[name] = []string{def}
+	}
+	store.Merge(scraped, scraper.Source{Name: "Test", URL: "https://example.com/glossary"})
+	return store
+}
+
+// newTestServer returns the API serving store as StartServer would, less
+// the logging, metrics and rate limiting, with the terms marked loaded.
+func newTestServer(t testing.TB, store termstore.TermStore) http.Handler {
+	t.Helper()
+	markLoaded(t)
+	return withRequestID(NewRouter(store))
+}
+
+// markLoaded lets the terms be served for the rest of the test.
+func markLoaded(t testing.TB) {
+	wasLoaded := loaded.Swap(true)
+	t.Cleanup(func() { loaded.Store(wasLoaded) })
+}
+
+// serve sends a request to h and returns the response. headers are
+// name, value pairs.
+func serve(t testing.TB, h http.Handler, method, target string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
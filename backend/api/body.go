@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errTrailingData = errors.New("request body must contain a single JSON value")
+
+// readJSON reads a request body that must be sent as application/json
+// and be at most limit bytes, answering 415 or 413 and reporting false if
+// it isn't. Reading stops at the limit rather than draining the body.
+func readJSON(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "request body must be sent as Content-Type: application/json")
+		return nil, false
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeErrorDetails(w, http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+			fmt.Sprintf("request body must be at most %d bytes", limit), map[string]any{"limit": limit})
+		return nil, false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "request body could not be read")
+		return nil, false
+	}
+	return data, true
+}
+
+// decodeStrict decodes data into v, failing on fields v has no place for
+// and on anything after the first JSON value.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingData
+	}
+	return nil
+}
+
+// decodeError answers a body decodeStrict turned down, naming an unknown
+// field or trailing data and otherwise describing the body expected.
+func decodeError(w http.ResponseWriter, err error, expected string) {
+	switch field, unknown := strings.CutPrefix(err.Error(), "json: unknown field "); {
+	case unknown:
+		name, _ := strconv.Unquote(field)
+		writeErrorDetails(w, http.StatusBadRequest, codeUnknownField,
+			"request body has an unknown field "+field, map[string]any{"field": name})
+	case errors.Is(err, errTrailingData):
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, codeInvalidBody, expected)
+	}
+}
+
+// decodeJSON reads and strictly decodes a JSON request body into v,
+// answering the request and reporting false if it can't.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, limit int64, expected string) bool {
+	data, ok := readJSON(w, r, limit)
+	if !ok {
+		return false
+	}
+	if err := decodeStrict(data, v); err != nil {
+		decodeError(w, err, expected)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodies(t *testing.T) {
+	saved := WriteAPIKey
+	t.Cleanup(func() { WriteAPIKey = saved })
+	WriteAPIKey = "secret"
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+
+	endpoints := []struct {
+		path  string
+		limit int64
+		// valid is a body the endpoint accepts, and unknown one with a
+		// field it doesn't, named misspelt
+		valid   string
+		unknown string
+	}{
+		{"/api/v1/terms/lookup", maxLookupBytes, `["Cache"]`, ""},
+		{"/api/v1/graphql", maxGraphQLBytes, `{"query":"{ stats { total } }"}`, `{"query":"{ stats { total } }","misspelt":{}}`},
+		{"/api/v1/quiz/answer", maxQuizAnswerBytes, `{"token":"t","choice":"c"}`, `{"token":"t","choice":"c","misspelt":1}`},
+		{"/api/v1/import?dry_run=true", maxImportBytes, `{"Stack":"A last-in, first-out collection."}`, `[{"term":"Stack","definition":"A last-in, first-out collection.","misspelt":""}]`},
+	}
+	const (
+		wrongType = `{"error":"request body must be sent as Content-Type: application/json","code":"unsupported_media_type","request_id":"req-1"}`
+		unknown   = `{"error":"request body has an unknown field \"misspelt\"","code":"unknown_field","details":{"field":"misspelt"},"request_id":"req-1"}`
+		trailing  = `{"error":"request body must contain a single JSON value","code":"invalid_body","request_id":"req-1"}`
+	)
+
+	type bodyTest struct {
+		name        string
+		body        string
+		contentType string
+		status      int
+		want        string
+	}
+	for _, ep := range endpoints {
+		tooLarge := fmt.Sprintf(`{"error":"request body must be at most %d bytes","code":"body_too_large","details":{"limit":%[1]d},"request_id":"req-1"}`, ep.limit)
+		tests := []bodyTest{
+			{"oversized", strings.Repeat(" ", int(ep.limit)) + ep.valid, "application/json", http.StatusRequestEntityTooLarge, tooLarge},
+			{"no content type", ep.valid, "", http.StatusUnsupportedMediaType, wrongType},
+			{"plain text", ep.valid, "text/plain", http.StatusUnsupportedMediaType, wrongType},
+			{"form", "query=x", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType, wrongType},
+			{"JSON-like type", ep.valid, "application/json-patch+json", http.StatusUnsupportedMediaType, wrongType},
+			{"trailing value", ep.valid + ep.valid, "application/json", http.StatusBadRequest, trailing},
+			{"trailing garbage", ep.valid + " garbage", "application/json", http.StatusBadRequest, trailing},
+		}
+		if ep.unknown != "" {
+			tests = append(tests, bodyTest{"unknown field", ep.unknown, "application/json", http.StatusBadRequest, unknown})
+		}
+
+		for _, tt := range tests {
+			t.Run(ep.path+"/"+tt.name, func(t *testing.T) {
+				rec := serve(t, h, http.MethodPost, ep.path, strings.NewReader(tt.body),
+					"Content-Type", tt.contentType, "Authorization", "Bearer secret", requestIDHeader, "req-1")
+				if got := strings.TrimSpace(rec.Body.String()); rec.Code != tt.status || got != tt.want {
+					t.Errorf("got %d %s\nwant %d %s", rec.Code, got, tt.status, tt.want)
+				}
+			})
+		}
+
+		// Within the limit, with parameters on the type, the body is taken
+		t.Run(ep.path+"/valid", func(t *testing.T) {
+			body := strings.Repeat(" ", int(ep.limit)-len(ep.valid)) + ep.valid
+			rec := serve(t, h, http.MethodPost, ep.path, strings.NewReader(body),
+				"Content-Type", "application/json; charset=utf-8", "Authorization", "Bearer secret")
+			switch rec.Code {
+			case http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+				t.Errorf("a body of exactly the limit got %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
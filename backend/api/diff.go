@@ -0,0 +1,92 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TermChange is a term whose primary definition changed.
+type TermChange struct {
+	Term string `json:"term"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// ScrapeDiff is what a refresh changed in the store.
+type ScrapeDiff struct {
+	Time  time.Time `json:"time"`
+	Added []string  `json:"added"`
+	// Removed stays empty while scrapes only ever add and update terms
+	Removed []string     `json:"removed"`
+	Changed []TermChange `json:"changed"`
+}
+
+// empty reports whether the refresh changed nothing.
+func (d ScrapeDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d ScrapeDiff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// diffTerms compares the definitions from before and after a refresh, each
+// list in order of term name.
+func diffTerms(before, after map[string]string) ScrapeDiff {
+	diff := ScrapeDiff{
+		Time:    time.Now(),
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []TermChange{},
+	}
+	for name, def := range after {
+		old, existed := before[name]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, name)
+		case old != def:
+			diff.Changed = append(diff.Changed, TermChange{Term: name, Old: old, New: def})
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.SortFunc(diff.Changed, func(a, b TermChange) int {
+		return cmp.Compare(a.Term, b.Term)
+	})
+	return diff
+}
+
+// lastDiff holds the diff of the latest refresh.
+var lastDiff struct {
+	mu   sync.Mutex
+	diff *ScrapeDiff
+}
+
+func setLastDiff(diff ScrapeDiff) {
+	lastDiff.mu.Lock()
+	defer lastDiff.mu.Unlock()
+
+	lastDiff.diff = &diff
+}
+
+// getLastDiff returns what the latest refresh changed.
+func getLastDiff(w http.ResponseWriter, r *http.Request) {
+	lastDiff.mu.Lock()
+	diff := lastDiff.diff
+	lastDiff.mu.Unlock()
+
+	if diff == nil {
+		writeError(w, http.StatusNotFound, codeNoRefreshYet, "no refresh has finished yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// scrapeDefinitions extracts the entries of a page's definition list.
+func scrapeDefinitions(doc *goquery.Document) scraper.Extracted {
+	e := scraper.Extracted{Terms: make(map[string][]string)}
+	doc.Find("dt").Each(func(_ int, dt *goquery.Selection) {
+		e.Found++
+		name, def := dt.Text(), dt.Next().Text()
+		e.Terms[name] = append(e.Terms[name], def)
+	})
+	return e
+}
+
+// newEndToEndServer scrapes a glossary the way a library consumer would
+// and serves the terms over HTTP with the middleware StartServer adds.
+func newEndToEndServer(t *testing.T, cfg ServerConfig) *httptest.Server {
+	t.Helper()
+	savedDelay := scraper.HostDelay
+	t.Cleanup(func() { scraper.HostDelay = savedDelay })
+	scraper.HostDelay = 0
+	scraper.SetStateDir(t.TempDir())
+
+	glossary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<dl>
+			<dt>Cache</dt><dd>Fast memory holding recently used data.</dd>
+			<dt>Big O notation</dt><dd>A way of describing how an algorithm's cost grows.</dd>
+			<dt>TCP/IP</dt><dd>The suite of protocols the internet runs on.</dd>
+		</dl>`)
+	}))
+	t.Cleanup(glossary.Close)
+
+	src := scraper.Source{Name: "Glossary", URL: glossary.URL + "/glossary", ScrapeFunc: scrapeDefinitions}
+	results, failed := scraper.ScrapeAll(context.Background(), []scraper.Source{src})
+	if len(failed) > 0 {
+		t.Fatalf("scrape failed: %v", failed)
+	}
+	store := termstore.NewMemoryStore()
+	store.Merge(results[src.Name].Terms, src)
+	if store.Len() != 3 {
+		t.Fatalf("scraped %d terms, want 3", store.Len())
+	}
+
+	markLoaded(t)
+	server := httptest.NewServer(newHandler(cfg, store))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get requests path from server. headers are name, value pairs.
+func get(t *testing.T, server *httptest.Server, method, path string, headers ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestEndToEnd(t *testing.T) {
+	captureLogs(t)
+	server := newEndToEndServer(t, ServerConfig{})
+
+	tests := []struct {
+		path   string
+		status int
+		// terms lists the names the response should carry, in order
+		terms []string
+		code  string
+	}{
+		{"/api/terms", http.StatusOK, []string{"Big O notation", "Cache", "TCP/IP"}, ""},
+		{"/api/v1/terms", http.StatusOK, []string{"Big O notation", "Cache", "TCP/IP"}, ""},
+		{"/api/v1/terms/search?q=memory", http.StatusOK, []string{"Cache"}, ""},
+		{"/api/terms/search?q=protocols", http.StatusOK, []string{"TCP/IP"}, ""},
+		{"/api/v1/terms/Nothing", http.StatusNotFound, nil, codeTermNotFound},
+		{"/nowhere", http.StatusNotFound, nil, codeNotFound},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, server, http.MethodGet, tt.path)
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d: %s", tt.path, resp.StatusCode, tt.status, body)
+			continue
+		}
+		if resp.Header.Get(requestIDHeader) == "" {
+			t.Errorf("GET %s has no %s header", tt.path, requestIDHeader)
+		}
+		if tt.code != "" {
+			var e ErrorResponse
+			if err := json.Unmarshal(body, &e); err != nil {
+				t.Fatalf("GET %s: %v", tt.path, err)
+			}
+			if e.Code != tt.code || e.RequestID != resp.Header.Get(requestIDHeader) {
+				t.Errorf("GET %s = %+v, want code %s and the request's ID", tt.path, e, tt.code)
+			}
+			continue
+		}
+		var got struct{ Terms []termstore.TermResponse }
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		var gotNames []string
+		for _, term := range got.Terms {
+			gotNames = append(gotNames, term.Term)
+		}
+		if strings.Join(gotNames, "|") != strings.Join(tt.terms, "|") {
+			t.Errorf("GET %s = %q, want %q", tt.path, gotNames, tt.terms)
+		}
+	}
+
+	resp, body := get(t, server, http.MethodGet, "/api/v1/terms/Cache")
+	var term termstore.TermResponse
+	if err := json.Unmarshal(body, &term); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || term.Definition != "Fast memory holding recently used data." || term.Source != "Glossary" {
+		t.Errorf("GET /api/v1/terms/Cache = %d %+v", resp.StatusCode, term)
+	}
+}
+
+func TestEndToEndMiddleware(t *testing.T) {
+	server := newEndToEndServer(t, ServerConfig{CORSOrigins: []string{"https://example.org"}})
+	logs := captureLogs(t)
+
+	resp, _ := get(t, server, http.MethodGet, "/api/v1/terms", requestIDHeader, "client-chosen-id")
+	if got := resp.Header.Get(requestIDHeader); got != "client-chosen-id" {
+		t.Errorf("%s = %q, want the client's", requestIDHeader, got)
+	}
+	if !strings.Contains(logs.String(), `"request_id":"client-chosen-id"`) {
+		t.Errorf("the request wasn't logged with its ID: %s", logs)
+	}
+
+	// Setting Accept-Encoding stops the client decompressing the body
+	resp, body := get(t, server, http.MethodGet, "/api/openapi.json", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+	} else if _, err := gzip.NewReader(bytes.NewReader(body)); err != nil {
+		t.Errorf("body isn't gzip: %v", err)
+	}
+
+	resp, _ = get(t, server, http.MethodOptions, "/api/v1/terms",
+		"Origin", "https://example.org", "Access-Control-Request-Method", http.MethodGet)
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://example.org" {
+		t.Errorf("preflight Access-Control-Allow-Origin = %q, want https://example.org", got)
+	}
+	resp, _ = get(t, server, http.MethodGet, "/api/v1/terms", "Origin", "https://elsewhere.example")
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an origin not allowed", got)
+	}
+}
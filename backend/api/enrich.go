@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"unicode/utf8"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// thinDefinition is the length, in characters, below which ?enrich=true
+// asks Wikipedia about a term even though the store has it.
+const thinDefinition = 40
+
+// maxEnrichedLookups caps how many terms one bulk lookup asks Wikipedia
+// about, since each request waits on the host delay.
+const maxEnrichedLookups = 10
+
+// needsEnrichment reports whether ?enrich=true should ask Wikipedia about
+// a term: it is missing, or its definition is thin and hasn't been
+// enriched before.
+func needsEnrichment(t termstore.Term, exists bool) bool {
+	if !exists {
+		return true
+	}
+	enriched := slices.ContainsFunc(t.Definitions, func(sense termstore.Sense) bool {
+		return sense.Source == scraper.WikipediaAPISource
+	})
+	return !enriched && utf8.RuneCountInString(t.Definition) < thinDefinition
+}
+
+// enrichTerm asks Wikipedia about name, which the store holds as t if it
+// exists, and merges the first sentence of the article into the store. A
+// missing term is stored under the article's title. It returns the term
+// as it is stored afterwards and, when name leads to a disambiguation
+// page, the articles it might mean. Failures are logged and leave t as it
+// was, so the caller can answer as it would have without enrichment.
+func enrichTerm(ctx context.Context, store termstore.TermStore, name string, t termstore.Term, exists bool) (termstore.Term, bool, []string) {
+	title := name
+	if exists {
+		title = t.Name
+	}
+
+	summary, err := scraper.FetchSummary(ctx, title)
+	switch {
+	case errors.Is(err, scraper.ErrNoArticle):
+		return t, exists, nil
+	case err != nil:
+		logger(ctx).Warn("Could not enrich term from Wikipedia", "term", title, "err", err)
+		return t, exists, nil
+	case summary.Definition == "":
+		return t, exists, summary.Candidates
+	}
+
+	// A thin term gets the article's definition whatever the article is
+	// called
+	if exists {
+		summary.Title = t.Name
+	}
+	if _, err := store.Merge(map[string][]string{summary.Title: {summary.Definition}}, summary.Source()); err != nil {
+		// The store has the definition; only the backend is behind
+		logger(ctx).Error("Failed to save enriched term", "term", summary.Title, "err", err)
+	}
+	logger(ctx).Info("Enriched term from Wikipedia", "term", summary.Title, "url", summary.URL)
+	enriched, found := store.Get(summary.Title)
+	return enriched, found, nil
+}
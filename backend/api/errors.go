@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrorResponse is the body of every error the API answers with. Error is
+// meant for people and may be reworded; Code is stable and is what
+// clients should branch on. Details, when present, carries what the
+// message describes in a form that can be used directly, such as the
+// values a parameter may take. RequestID identifies the request in the
+// server's logs.
+type ErrorResponse struct {
+	Error     string         `json:"error"`
+	Code      string         `json:"code"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// Error codes. They are part of the API, so existing ones are never
+// renamed; add new ones to errorCodes too, which the OpenAPI document
+// lists.
+const (
+	codeInvalidParameter     = "invalid_parameter"
+	codeInvalidQuery         = "invalid_query"
+	codeUnknownSource        = "unknown_source"
+	codeInvalidBody          = "invalid_body"
+	codeUnknownField         = "unknown_field"
+	codeBodyTooLarge         = "body_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeNotAcceptable        = "not_acceptable"
+	codeInvalidAnswer        = "invalid_answer"
+	codeUnauthorized         = "unauthorized"
+	codeWritesDisabled       = "writes_disabled"
+	codeNotFound             = "not_found"
+	codeTermNotFound         = "term_not_found"
+	codeNoMatchingTerms      = "no_matching_terms"
+	codeSnapshotNotFound     = "snapshot_not_found"
+	codeSnapshotDeleted      = "snapshot_deleted"
+	codeSnapshotUnreadable   = "snapshot_unreadable"
+	codeNoRefreshYet         = "no_refresh_yet"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeRateLimited          = "rate_limited"
+	codeRefreshInProgress    = "refresh_in_progress"
+	codeShuttingDown         = "shutting_down"
+	codeNotReady             = "not_ready"
+	codeNoTerms              = "no_terms"
+	codeStoreUnavailable     = "store_unavailable"
+	codeTimeout              = "timeout"
+	codeInternal             = "internal_error"
+)
+
+var errorCodes = []string{
+	codeInvalidParameter, codeInvalidQuery, codeUnknownSource,
+	codeInvalidBody, codeUnknownField, codeBodyTooLarge,
+	codeUnsupportedMediaType, codeNotAcceptable, codeInvalidAnswer,
+	codeUnauthorized, codeWritesDisabled,
+	codeNotFound, codeTermNotFound, codeNoMatchingTerms,
+	codeSnapshotNotFound, codeSnapshotDeleted, codeSnapshotUnreadable,
+	codeNoRefreshYet, codeMethodNotAllowed, codeRateLimited,
+	codeRefreshInProgress, codeShuttingDown, codeNotReady, codeNoTerms,
+	codeStoreUnavailable, codeTimeout, codeInternal,
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, newErrorResponse(w, code, message))
+}
+
+// writeErrorDetails is writeError with details.
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details map[string]any) {
+	e := newErrorResponse(w, code, message)
+	e.Details = details
+	writeJSON(w, status, e)
+}
+
+// newErrorResponse builds an error body for the response w, taking the
+// request's ID from the header withRequestID has already set on it.
+func newErrorResponse(w http.ResponseWriter, code, message string) ErrorResponse {
+	return ErrorResponse{Error: message, Code: code, RequestID: w.Header().Get(requestIDHeader)}
+}
+
+// recoverMiddleware turns a panicking handler into a 500 with the usual
+// error body, rather than a dropped connection. The response can only be
+// replaced if the handler hadn't started writing it. It goes around
+// everything else, withRequestID included, so the request's context
+// doesn't have the ID; it is read back from the response's header.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.Error("Handler panicked", "request_id", rec.Header().Get(requestIDHeader), "method", r.Method, "path", r.URL.Path,
+				"err", fmt.Sprint(err), "stack", string(debug.Stack()))
+			if !rec.wroteHeader {
+				writeError(rec, http.StatusInternalServerError, codeInternal, "internal server error")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	termstore "scrape_cp/store"
+)
+
+// panickingStore panics on every search.
+type panickingStore struct{ termstore.TermStore }
+
+func (panickingStore) Search(context.Context, string, termstore.SearchOptions) ([]termstore.TermResponse, error) {
+	panic("index corrupted")
+}
+
+func TestErrorBodies(t *testing.T) {
+	captureLogs(t)
+	markLoaded(t)
+	h := newHandler(ServerConfig{}, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+
+	tests := []struct {
+		target string
+		status int
+		want   string
+	}{
+		{"/nowhere", http.StatusNotFound, `{"error":"no such endpoint","code":"not_found","request_id":"req-1"}`},
+		{"/api/v1/terms/Nothing", http.StatusNotFound, `{"error":"term not found","code":"term_not_found","request_id":"req-1","suggestions":[]}`},
+		{"/api/v1/terms/names?limit=0", http.StatusBadRequest, `{"error":"limit must be a positive integer","code":"invalid_parameter","request_id":"req-1"}`},
+		{"/api/v1/terms/names?offset=-1", http.StatusBadRequest, `{"error":"offset must be a non-negative integer","code":"invalid_parameter","request_id":"req-1"}`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, tt.target, nil, requestIDHeader, "req-1")
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != tt.status || got != tt.want {
+			t.Errorf("GET %s = %d %s, want %d %s", tt.target, rec.Code, got, tt.status, tt.want)
+		}
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	const want = `{"error":"internal server error","code":"internal_error","request_id":"req-1"}`
+	logs := captureLogs(t)
+	markLoaded(t)
+
+	// A panic in a handler the router runs
+	store := panickingStore{newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	})}
+	rec := serve(t, newHandler(ServerConfig{}, store), http.MethodGet, "/api/v1/terms/search?q=cache", nil, requestIDHeader, "req-1")
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusInternalServerError || got != want {
+		t.Errorf("panicking search = %d %s, want 500 %s", rec.Code, got, want)
+	}
+	for _, s := range []string{`"msg":"Handler panicked"`, `"request_id":"req-1"`, `"err":"index corrupted"`} {
+		if !strings.Contains(logs.String(), s) {
+			t.Errorf("the panic wasn't logged with %s: %s", s, logs)
+		}
+	}
+	// and the request still gets its access-log line
+	var logged bool
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `"msg":"Request"`) {
+			logged = true
+			for _, s := range []string{`"request_id":"req-1"`, `"status":500`, `"path":"/api/v1/terms/search"`, `"duration":`} {
+				if !strings.Contains(line, s) {
+					t.Errorf("the panicking request was logged without %s: %s", s, line)
+				}
+			}
+		}
+	}
+	if !logged {
+		t.Errorf("the panicking request wasn't logged: %s", logs)
+	}
+
+	// A panic outside the router, as newHandler wraps it
+	h := recoverMiddleware(withRequestID(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("middleware bug")
+	})))
+	rec = serve(t, h, http.MethodGet, "/nowhere", nil, requestIDHeader, "req-1")
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusInternalServerError || got != want {
+		t.Errorf("panic outside the router = %d %s, want 500 %s", rec.Code, got, want)
+	}
+
+	// Aborting a response is left to net/http
+	h = recoverMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler passed on", err)
+		}
+	}()
+	serve(t, h, http.MethodGet, "/api/v1/terms", nil)
+	t.Error("http.ErrAbortHandler was swallowed")
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagPrefix distinguishes this process's data versions from those of a
+// previous run, whose counter started from zero as well.
+var etagPrefix = fmt.Sprintf("%x", time.Now().UnixNano())
+
+// checkNotModified tags the response with the current data version and
+// representation, and answers 304 Not Modified when the client already
+// holds that version. It reports whether the response has been written.
+func checkNotModified(w http.ResponseWriter, r *http.Request, format responseFormat) bool {
+	etag := fmt.Sprintf(`"%s-%d-%s"`, etagPrefix, storeOf(r.Context()).DataVersion(), format.name)
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for, so
+// W/"x" and "x" are considered the same tag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
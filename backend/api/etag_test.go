@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"scrape_cp/scraper"
+)
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc-3-json"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc-3-json"`, true},
+		{`W/"abc-3-json"`, true},
+		{` "abc-3-json" `, true},
+		{`"old-1-json", "abc-3-json"`, true},
+		{`"old-1-json",W/"abc-3-json"`, true},
+		{`*`, true},
+		{``, false},
+		{`"abc-2-json"`, false},
+		{`"abc-3-xml"`, false},
+		// The tag is compared quotes and all
+		{`abc-3-json`, false},
+		{`W/abc-3-json`, false},
+		{`"old-1-json", "older-0-json"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	store := newTestStore(t, map[string]string{"Cache": "Fast storage close to where it is used."})
+	h := newTestServer(t, store)
+
+	for _, target := range []string{"/api/v1/terms", "/api/v1/terms/Cache", "/api/v1/terms/search?q=cache"} {
+		t.Run(target, func(t *testing.T) {
+			first := serve(t, h, http.MethodGet, target, nil)
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("first request answered %d with ETag %q", first.Code, etag)
+			}
+
+			for _, header := range []string{etag, "W/" + etag, `"other", ` + etag} {
+				rec := serve(t, h, http.MethodGet, target, nil, "If-None-Match", header)
+				if rec.Code != http.StatusNotModified {
+					t.Errorf("If-None-Match: %s answered %d, want 304", header, rec.Code)
+				}
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 has a body: %q", rec.Body)
+				}
+			}
+
+			rec := serve(t, h, http.MethodGet, target, nil, "If-None-Match", `"stale"`)
+			if rec.Code != http.StatusOK {
+				t.Errorf("a stale ETag answered %d, want 200", rec.Code)
+			}
+
+			// The same Accept gets the same tag; another format a different one
+			xml := serve(t, h, http.MethodGet, target, nil, "Accept", "application/xml")
+			if xml.Code != http.StatusOK || xml.Header().Get("ETag") == "" {
+				t.Fatalf("XML request answered %d with ETag %q", xml.Code, xml.Header().Get("ETag"))
+			}
+			if xml.Header().Get("ETag") == etag {
+				t.Errorf("XML response has the JSON response's ETag %s", etag)
+			}
+		})
+	}
+
+	before := serve(t, h, http.MethodGet, "/api/v1/terms", nil).Header().Get("ETag")
+	store.Merge(map[string][]string{"Queue": {"A first-in, first-out collection."}}, scraper.Source{Name: "Other"})
+	rec := serve(t, h, http.MethodGet, "/api/v1/terms", nil, "If-None-Match", before)
+	if rec.Code != http.StatusOK {
+		t.Errorf("after a change, the old ETag answered %d, want 200", rec.Code)
+	}
+	if after := rec.Header().Get("ETag"); after == before {
+		t.Errorf("ETag %s didn't change with the terms", after)
+	}
+}
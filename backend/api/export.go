@@ -0,0 +1,275 @@
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"scrape_cp/internal/atomicfile"
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// exportFormat describes one of the formats served by GET /api/export.
+type exportFormat struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, terms []termstore.Term) error
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		write:       delimitedWriter(','),
+	},
+	"tsv": {
+		contentType: "text/tab-separated-values; charset=utf-8",
+		extension:   "tsv",
+		write:       delimitedWriter('\t'),
+	},
+	"markdown": {
+		contentType: "text/markdown; charset=utf-8",
+		extension:   "md",
+		write:       renderMarkdown,
+	},
+	"anki": {
+		contentType: "text/plain; charset=utf-8",
+		extension:   "txt",
+		write:       writeAnki,
+	},
+	"zip": {
+		contentType: "application/zip",
+		extension:   "zip",
+		write:       writeBundle,
+	},
+}
+
+var ExportFormatNames = []string{"csv", "tsv", "markdown", "anki", "zip"}
+
+// outputFileFormats are the formats --output-format can save terms in,
+// besides JSON, which is saved as a snapshot.
+var outputFileFormats = map[string]exportFormat{
+	"csv":      exportFormats["csv"],
+	"markdown": exportFormats["markdown"],
+	"yaml":     {extension: "yaml", write: writeYAML},
+}
+
+var OutputFormatNames = []string{"json", "csv", "yaml", "markdown"}
+
+// writeYAML encodes terms as the single-term endpoints serve them, with
+// every definition, using the YAML encoder behind their format=yaml.
+func writeYAML(w io.Writer, terms []termstore.Term) error {
+	responses := make([]termstore.TermResponse, len(terms))
+	for i, t := range terms {
+		responses[i] = t.Detail()
+	}
+	yaml, _ := formatNamed("yaml")
+	return yaml.encode(w, responses)
+}
+
+// delimitedWriter returns a writer producing one row per term with a
+// header row. encoding/csv takes care of quoting fields that contain the
+// delimiter, quotes or newlines.
+func delimitedWriter(comma rune) func(io.Writer, []termstore.Term) error {
+	return func(w io.Writer, terms []termstore.Term) error {
+		cw := csv.NewWriter(w)
+		cw.Comma = comma
+
+		if err := cw.Write([]string{"term", "definition", "source"}); err != nil {
+			return err
+		}
+		for _, t := range terms {
+			if err := cw.Write([]string{t.Name, t.Definition, t.Source}); err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// ankiField makes a value safe for one field of an Anki note: HTML is
+// escaped since the deck is imported with HTML enabled, line breaks become
+// <br> and tabs become spaces so a row never spills into extra fields.
+var ankiField = strings.NewReplacer("\t", " ", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// writeAnki produces a tab-separated file Anki can import directly, with
+// the term on the front of each card and the definition on the back.
+func writeAnki(w io.Writer, terms []termstore.Term) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#separator:tab\n#html:true\n")
+	for _, t := range terms {
+		fmt.Fprintf(bw, "%s\t%s\n",
+			ankiField.Replace(html.EscapeString(t.Name)),
+			ankiField.Replace(html.EscapeString(t.Definition)))
+	}
+	return bw.Flush()
+}
+
+// BundleManifest describes the terms in a ZIP bundle. ScrapedAt is when
+// the latest scrape finished, if there has been one since startup; Origin
+// says where the terms came from otherwise.
+type BundleManifest struct {
+	ScrapedAt *time.Time     `json:"scraped_at,omitempty"`
+	Origin    string         `json:"origin"`
+	Terms     int            `json:"terms"`
+	Sources   []BundleSource `json:"sources"`
+	Files     []string       `json:"files"`
+}
+
+type BundleSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// bundleFiles are the renderings in a ZIP bundle, in the order they are
+// written.
+var bundleFiles = []struct {
+	name  string
+	write func(w io.Writer, terms []termstore.Term) error
+}{
+	{"terms.json", func(w io.Writer, terms []termstore.Term) error { return writeIndentedJSON(w, terms) }},
+	{"terms.csv", delimitedWriter(',')},
+	{"terms.md", renderMarkdown},
+}
+
+// writeBundle streams a ZIP archive of terms as JSON, CSV and Markdown,
+// with a manifest.json describing them. Each file is compressed as it is
+// written, so the archive is never held in memory whole.
+func writeBundle(w io.Writer, terms []termstore.Term) error {
+	manifest := BundleManifest{
+		Origin: stats.dataOrigin(),
+		Terms:  len(terms),
+	}
+	if last := scrapes.refreshStats().LastRun; last != nil {
+		manifest.ScrapedAt = &last.Time
+	}
+	for _, src := range scraper.Sources {
+		manifest.Sources = append(manifest.Sources, BundleSource{Name: src.Name, URL: src.URL})
+	}
+	for _, f := range bundleFiles {
+		manifest.Files = append(manifest.Files, f.name)
+	}
+
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	add := func(name string, write func(io.Writer) error) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := write(fw); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := add("manifest.json", func(w io.Writer) error { return writeIndentedJSON(w, manifest) }); err != nil {
+		return err
+	}
+	for _, f := range bundleFiles {
+		if err := add(f.name, func(w io.Writer) error { return f.write(w, terms) }); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeIndentedJSON encodes v indented the way saved JSON files are.
+func writeIndentedJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
+
+// matchingTerms narrows terms to those matching every part of query in
+// either the name or the definition, keeping their order.
+func matchingTerms(terms []termstore.Term, query string) ([]termstore.Term, error) {
+	m, err := termstore.NewMatcher(query, termstore.SearchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]termstore.Term, 0, len(terms))
+	for _, t := range terms {
+		if m.Rank(t.Name, t.Aliases, t.Definition, termstore.FieldsBoth) > 0 {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+func exportTerms(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "csv"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter,
+			"format must be one of "+strings.Join(ExportFormatNames, ", "))
+		return
+	}
+
+	length, err := parseLengthBounds(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	// Encode from a single copy of the store so a refresh running at the
+	// same time can't leave the export half old and half new
+	terms := storeOf(r.Context()).Snapshot()
+	if length != (termstore.LengthBounds{}) {
+		terms = slices.DeleteFunc(terms, func(t termstore.Term) bool { return !length.Contains(t.Definition) })
+	}
+	if query := strings.TrimSpace(r.URL.Query().Get("q")); query != "" {
+		if terms, err = matchingTerms(terms, query); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("cs_terms_%s.%s", time.Now().Format(TimestampLayout), format.extension)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := format.write(w, terms); err != nil {
+		logger(r.Context()).Error("Failed to export terms", "format", name, "err", err)
+	}
+}
+
+// ExportFile writes every term in store to a file at path in the named
+// export format.
+func ExportFile(path, format string, store termstore.TermStore) error {
+	f, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	return writeExportFile(path, f, store.Snapshot())
+}
+
+// Export writes every term in store to w in the named export format.
+func Export(w io.Writer, format string, store termstore.TermStore) error {
+	f, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	return f.write(w, store.Snapshot())
+}
+
+// writeExportFile renders terms into a file at path.
+func writeExportFile(path string, format exportFormat, terms []termstore.Term) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		return format.write(w, terms)
+	})
+}
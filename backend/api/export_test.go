@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	termstore "scrape_cp/store"
+)
+
+// awkwardTerms have definitions needing every kind of CSV quoting.
+var awkwardTerms = []termstore.Term{
+	{Name: "Comma", Definition: "Separates items, such as these.", Source: "Test"},
+	{Name: "Quote", Definition: `Marks text as "quoted".`, Source: "Test"},
+	{Name: "Newline", Definition: "Ends a line.\nThen another starts.", Source: "Test"},
+	{Name: "Tab", Definition: "Moves to the next\tstop.", Source: "Test"},
+	{Name: "Plain", Definition: "Nothing to quote", Source: "Test"},
+}
+
+func TestDelimitedQuoting(t *testing.T) {
+	for _, comma := range []rune{',', '\t'} {
+		var buf bytes.Buffer
+		if err := delimitedWriter(comma)(&buf, awkwardTerms); err != nil {
+			t.Fatalf("writing with %q: %v", comma, err)
+		}
+
+		r := csv.NewReader(&buf)
+		r.Comma = comma
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("reading back what was written with %q: %v", comma, err)
+		}
+		if want := []string{"term", "definition", "source"}; !slices.Equal(rows[0], want) {
+			t.Errorf("header = %q, want %q", rows[0], want)
+		}
+		if len(rows) != len(awkwardTerms)+1 {
+			t.Fatalf("read %d rows written with %q, want %d", len(rows), comma, len(awkwardTerms)+1)
+		}
+		for i, term := range awkwardTerms {
+			if want := []string{term.Name, term.Definition, term.Source}; !slices.Equal(rows[i+1], want) {
+				t.Errorf("row %d written with %q = %q, want %q", i+1, comma, rows[i+1], want)
+			}
+		}
+	}
+}
+
+func TestCSVQuotesOnlyWhereNeeded(t *testing.T) {
+	var buf bytes.Buffer
+	if err := delimitedWriter(',')(&buf, awkwardTerms); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"term,definition,source",
+		`Comma,"Separates items, such as these.",Test`,
+		`Quote,"Marks text as ""quoted"".",Test`,
+		"Newline,\"Ends a line.\nThen another starts.\",Test",
+		"Tab,Moves to the next\tstop.,Test",
+		"Plain,Nothing to quote,Test",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportEndpoint(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": `Fast storage, often called "L1".`,
+	}))
+
+	rec := serve(t, h, http.MethodGet, "/api/v1/export?format=csv", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="cs_terms_`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	want := "term,definition,source\nCache,\"Fast storage, often called \"\"L1\"\".\",Test\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	rec = serve(t, h, http.MethodGet, "/api/v1/export?format=xls", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown format answered %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxFeedEntries caps how many changes the feed lists.
+const maxFeedEntries = 100
+
+// feedID identifies the feed; entry ids are built from it so they stay
+// the same across restarts and hosts.
+const feedID = "urn:scrape-cp:terms"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// getFeed serves an Atom feed of the terms added or changed by the most
+// recent scrape, newest first.
+func getFeed(w http.ResponseWriter, r *http.Request) {
+	base := requestBaseURL(r)
+	since := scrapes.lastStarted()
+	changed := storeOf(r.Context()).ChangedSince(since, maxFeedEntries)
+
+	feed := atomFeed{
+		ID:      feedID,
+		Title:   "Computer Science Terms",
+		Updated: since.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + "/feed.xml"},
+			{Rel: "alternate", Type: "application/json", Href: base + apiV1.prefix + "/terms"},
+		},
+		Author:  atomAuthor{Name: "scrape_cp"},
+		Entries: make([]atomEntry, 0, len(changed)),
+	}
+	if len(changed) > 0 {
+		feed.Updated = changed[0].Updated.UTC().Format(time.RFC3339)
+	}
+
+	for _, t := range changed {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        feedID + ":" + url.PathEscape(strings.ToLower(t.Name)),
+			Title:     t.Name,
+			Published: t.FirstSeen.UTC().Format(time.RFC3339),
+			Updated:   t.Updated.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: base + apiV1.prefix + "/terms/" + url.PathEscape(t.Name)},
+			Content:   atomContent{Type: "text", Body: t.Definition},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		logger(r.Context()).Error("Failed to write feed", "err", err)
+	}
+}
+
+// requestBaseURL returns the scheme and host the request was made to, for
+// building absolute links.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	termstore "scrape_cp/store"
+)
+
+// maxGraphQLBytes caps the size of a GraphQL request body.
+const maxGraphQLBytes = 1 << 20
+
+// GraphQLRequest is the body of a POST to the GraphQL endpoint.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLStats is what the stats query field resolves to.
+type GraphQLStats struct {
+	Terms   int
+	Letters []termstore.LetterCount
+}
+
+// Each field resolves through its own store call, so the store lock is held
+// per field rather than across a whole query.
+var graphQLSchema = mustGraphQLSchema()
+
+func mustGraphQLSchema() graphql.Schema {
+	var termType *graphql.Object
+	termType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Term",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(termstore.TermResponse).Term, nil
+					},
+				},
+				"definition": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(termstore.TermResponse).Definition, nil
+					},
+				},
+				"related": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termType))),
+					Description: "Terms linked to this one by mentions in either definition.",
+					Args: graphql.FieldConfigArgument{
+						"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultRelatedLimit},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						limit, err := limitArg(p, maxRelatedLimit)
+						if err != nil {
+							return nil, err
+						}
+						_, related, _ := storeOf(p.Context).Related(p.Source.(termstore.TermResponse).Term, limit)
+						return related, nil
+					},
+				},
+			}
+		}),
+	})
+
+	letterType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "LetterCount",
+		Fields: graphql.Fields{
+			"letter": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"count":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"terms":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"letters": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(letterType)))},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"term": &graphql.Field{
+				Type:        termType,
+				Description: "Look a term up by name, ignoring case. Null when there is no such term.",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					t, exists, err := findTerm(storeOf(p.Context), p.Args["name"].(string))
+					if err != nil {
+						logger(p.Context).Error("Failed to look up a stored term", "err", err)
+						return nil, errors.New("term store unavailable")
+					}
+					if !exists {
+						return nil, nil
+					}
+					return termstore.TermResponse{Term: t.Name, Definition: t.Definition}, nil
+				},
+			},
+			"terms": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(termType)),
+				Args: graphql.FieldConfigArgument{
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageLimit},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"sort":   &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: termstore.SortAlpha},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					order := p.Args["sort"].(string)
+					if !slices.Contains(termstore.SortOrders, order) {
+						return nil, fmt.Errorf("sort must be one of %s", strings.Join(termstore.SortOrders, ", "))
+					}
+					page, err := pageArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					return paginate(storeOf(p.Context).List(termstore.ListOptions{Order: order}), page), nil
+				},
+			},
+			"search": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(termType)),
+				Description: "Search term names and definitions, best matches first.",
+				Args: graphql.FieldConfigArgument{
+					"q":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"fuzzy": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageLimit},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					q, err := checkSearchQuery(p.Args["q"].(string), p.Args["fuzzy"].(bool))
+					if err != nil {
+						return nil, err
+					}
+					limit, err := limitArg(p, maxPageLimit)
+					if err != nil {
+						return nil, err
+					}
+					store := storeOf(p.Context)
+					var results []termstore.TermResponse
+					if p.Args["fuzzy"].(bool) {
+						results, err = store.Fuzzy(p.Context, q, termstore.DefaultFuzzyDistance)
+					} else {
+						opts := termstore.SearchOptions{Fields: termstore.FieldsBoth}
+						if _, err := termstore.NewMatcher(q, opts); err != nil {
+							return nil, err
+						}
+						results, err = store.Search(p.Context, q, opts)
+					}
+					if err != nil {
+						return nil, err
+					}
+					return paginate(results, pagination{limit: limit}), nil
+				},
+			},
+			"suggest": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Term names starting with prefix, ignoring case.",
+				Args: graphql.FieldConfigArgument{
+					"prefix": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultSuggestLimit},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit, err := limitArg(p, maxSuggestLimit)
+					if err != nil {
+						return nil, err
+					}
+					return storeOf(p.Context).WithPrefix(p.Args["prefix"].(string), limit), nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type: graphql.NewNonNull(statsType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					store := storeOf(p.Context)
+					return GraphQLStats{Terms: store.Len(), Letters: store.LetterCounts()}, nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		panic(fmt.Sprintf("building GraphQL schema: %v", err))
+	}
+	return schema
+}
+
+// limitArg reads a positive limit argument, clamping it to max.
+func limitArg(p graphql.ResolveParams, max int) (int, error) {
+	limit := p.Args["limit"].(int)
+	if limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	return min(limit, max), nil
+}
+
+// pageArgs reads the limit and offset arguments the way parsePagination
+// reads query parameters.
+func pageArgs(p graphql.ResolveParams) (pagination, error) {
+	limit, err := limitArg(p, maxPageLimit)
+	if err != nil {
+		return pagination{}, err
+	}
+	offset := p.Args["offset"].(int)
+	if offset < 0 {
+		return pagination{}, fmt.Errorf("offset must be a non-negative integer")
+	}
+	return pagination{limit: limit, offset: offset}, nil
+}
+
+// postGraphQL executes a GraphQL query. Problems with the query itself are
+// reported in the errors array of a 200 response, as GraphQL clients
+// expect; only an unreadable request body is an HTTP error.
+func postGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if !decodeJSON(w, r, &req, maxGraphQLBytes, "request body must be a JSON object with a query") {
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        r.Context(),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// graphQL posts query to the GraphQL endpoint of h and decodes the data it
+// answers with into data, failing the test on any error.
+func graphQL(t *testing.T, h http.Handler, query string, data any) {
+	t.Helper()
+	body, _ := json.Marshal(GraphQLRequest{Query: query})
+	rec := serve(t, h, http.MethodPost, "/api/v1/graphql", strings.NewReader(string(body)),
+		"Content-Type", "application/json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("query failed: %s", result.Errors[0].Message)
+	}
+	if err := json.Unmarshal(result.Data, data); err != nil {
+		t.Fatalf("decoding data: %v", err)
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, nil))
+	var data struct {
+		Schema struct {
+			QueryType struct {
+				Name string `json:"name"`
+			} `json:"queryType"`
+			Types []struct {
+				Name   string `json:"name"`
+				Fields []struct {
+					Name string `json:"name"`
+				} `json:"fields"`
+			} `json:"types"`
+		} `json:"__schema"`
+	}
+	graphQL(t, h, `{ __schema { queryType { name } types { name fields { name } } } }`, &data)
+
+	if data.Schema.QueryType.Name != "Query" {
+		t.Errorf("query type = %q, want Query", data.Schema.QueryType.Name)
+	}
+	want := map[string][]string{
+		"Query":       {"search", "stats", "suggest", "term", "terms"},
+		"Term":        {"definition", "name", "related"},
+		"Stats":       {"letters", "terms"},
+		"LetterCount": {"count", "letter"},
+	}
+	for _, typ := range data.Schema.Types {
+		wantFields, ok := want[typ.Name]
+		if !ok {
+			continue
+		}
+		delete(want, typ.Name)
+		var fields []string
+		for _, f := range typ.Fields {
+			fields = append(fields, f.Name)
+		}
+		slices.Sort(fields)
+		if !slices.Equal(fields, wantFields) {
+			t.Errorf("%s has fields %q, want %q", typ.Name, fields, wantFields)
+		}
+	}
+	for name := range want {
+		t.Errorf("schema has no %s type", name)
+	}
+}
+
+func TestGraphQLMultiFieldQuery(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache":       "Fast memory that keeps copies of data held in main memory.",
+		"Cache line":  "The unit of data moved between a cache and main memory.",
+		"Main memory": "The memory a CPU addresses directly.",
+		"Heap":        "Memory allocated at run time.",
+	}))
+
+	var data struct {
+		Term    *graphQLTerm  `json:"term"`
+		Missing *graphQLTerm  `json:"missing"`
+		Search  []graphQLTerm `json:"search"`
+		Suggest []string      `json:"suggest"`
+		Terms   []graphQLTerm `json:"terms"`
+		Stats   struct {
+			Terms   int `json:"terms"`
+			Letters []struct {
+				Letter string `json:"letter"`
+				Count  int    `json:"count"`
+			} `json:"letters"`
+		} `json:"stats"`
+	}
+	graphQL(t, h, `{
+		term(name: "cache") { name definition related { name } }
+		missing: term(name: "Stack") { name }
+		search(q: "cache") { name }
+		suggest(prefix: "ca")
+		terms(limit: 2, offset: 1) { name }
+		stats { terms letters { letter count } }
+	}`, &data)
+
+	if data.Term == nil || data.Term.Name != "Cache" {
+		t.Fatalf("term(name: cache) = %+v, want Cache", data.Term)
+	}
+	if !strings.HasPrefix(data.Term.Definition, "Fast memory") {
+		t.Errorf("Cache's definition = %q", data.Term.Definition)
+	}
+	related := graphQLNames(data.Term.Related)
+	if !slices.Contains(related, "Main memory") {
+		t.Errorf("Cache is related to %q, want Main memory among them", related)
+	}
+	if data.Missing != nil {
+		t.Errorf("an unknown term resolved to %+v, want null", data.Missing)
+	}
+	if got := graphQLNames(data.Search); !slices.Equal(got, []string{"Cache", "Cache line"}) {
+		t.Errorf("search = %q", got)
+	}
+	if !slices.Equal(data.Suggest, []string{"Cache", "Cache line"}) {
+		t.Errorf("suggest = %q", data.Suggest)
+	}
+	if got := graphQLNames(data.Terms); !slices.Equal(got, []string{"Cache line", "Heap"}) {
+		t.Errorf("terms(limit: 2, offset: 1) = %q", got)
+	}
+	if data.Stats.Terms != 4 || len(data.Stats.Letters) != 3 {
+		t.Errorf("stats = %+v, want 4 terms under 3 letters", data.Stats)
+	}
+}
+
+// graphQLTerm is a Term as a query selects it.
+type graphQLTerm struct {
+	Name       string        `json:"name"`
+	Definition string        `json:"definition"`
+	Related    []graphQLTerm `json:"related"`
+}
+
+// graphQLNames returns the name of each term, in order.
+func graphQLNames(terms []graphQLTerm) []string {
+	var out []string
+	for _, t := range terms {
+		out = append(out, t.Name)
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package api
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	termstore "scrape_cp/store"
+	"scrape_cp/termspb"
+)
+
+// grpcChunkSize is how many terms ListTerms reads from the store under one
+// lock while streaming alphabetically.
+const grpcChunkSize = 100
+
+// grpcTerms implements the Terms gRPC service against the same store as
+// the HTTP handlers.
+type grpcTerms struct {
+	termspb.UnimplementedTermsServer
+	store termstore.TermStore
+}
+
+func (g grpcTerms) GetTerm(ctx context.Context, req *termspb.GetTermRequest) (*termspb.Term, error) {
+	t, exists, err := findTerm(g.store, req.GetName())
+	if err != nil {
+		logger(ctx).Error("Failed to look up a stored term", "err", err)
+		return nil, status.Error(codes.Unavailable, "term store unavailable")
+	}
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "term %q not found", req.GetName())
+	}
+	return &termspb.Term{Name: t.Name, Definition: t.Definition}, nil
+}
+
+func (g grpcTerms) ListTerms(req *termspb.ListTermsRequest, stream termspb.Terms_ListTermsServer) error {
+	order := req.GetSort()
+	if order == "" {
+		order = termstore.SortAlpha
+	}
+	if !slices.Contains(termstore.SortOrders, order) {
+		return status.Errorf(codes.InvalidArgument, "sort must be one of %s", strings.Join(termstore.SortOrders, ", "))
+	}
+
+	// Alphabetical order can be walked a chunk at a time; the others need
+	// the whole store sorted up front.
+	if order != termstore.SortAlpha {
+		return sendTerms(stream, g.store.List(termstore.ListOptions{Order: order}))
+	}
+	for cursor := ""; ; {
+		chunk := g.store.After(cursor, grpcChunkSize)
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := sendTerms(stream, chunk); err != nil {
+			return err
+		}
+		cursor = chunk[len(chunk)-1].Term
+	}
+}
+
+func sendTerms(stream termspb.Terms_ListTermsServer, terms []termstore.TermResponse) error {
+	for _, t := range terms {
+		if err := stream.Send(&termspb.Term{Name: t.Term, Definition: t.Definition}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g grpcTerms) Search(ctx context.Context, req *termspb.SearchRequest) (*termspb.SearchResponse, error) {
+	query, err := checkSearchQuery(req.GetQuery(), req.GetFuzzy())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page := pagination{limit: defaultPageLimit, offset: int(req.GetOffset())}
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be a positive integer")
+	} else if req.GetLimit() > 0 {
+		page.limit = min(int(req.GetLimit()), maxPageLimit)
+	}
+	if page.offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must be a non-negative integer")
+	}
+
+	var results []termstore.TermResponse
+	if req.GetFuzzy() {
+		maxDistance := termstore.DefaultFuzzyDistance
+		if req.Distance != nil {
+			maxDistance = int(req.GetDistance())
+			if maxDistance < 0 || maxDistance > termstore.MaxFuzzyDistance {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"distance must be an integer between 0 and %d", termstore.MaxFuzzyDistance)
+			}
+		}
+		results, err = g.store.Fuzzy(ctx, query, maxDistance)
+	} else {
+		var fields termstore.SearchFields
+		if fields, err = termstore.ParseSearchFields(req.GetFields()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts := termstore.SearchOptions{Fields: fields, Exact: req.GetExact()}
+		if _, err := termstore.NewMatcher(query, opts); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		results, err = g.store.Search(ctx, query, opts)
+	}
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	resp := &termspb.SearchResponse{Total: int32(len(results))}
+	for _, t := range paginate(results, page) {
+		hit := &termspb.Term{Name: t.Term, Definition: t.Definition, Score: int32(t.Score)}
+		if t.Distance != nil {
+			d := int32(*t.Distance)
+			hit.Distance = &d
+		}
+		resp.Terms = append(resp.Terms, hit)
+	}
+	return resp, nil
+}
+
+func (g grpcTerms) Stats(ctx context.Context, req *termspb.StatsRequest) (*termspb.StatsResponse, error) {
+	resp := &termspb.StatsResponse{Terms: int32(g.store.Len())}
+	for _, c := range g.store.LetterCounts() {
+		resp.Letters = append(resp.Letters, &termspb.LetterCount{Letter: c.Letter, Count: int32(c.Count)})
+	}
+	return resp, nil
+}
+
+// StartGRPCServer binds addr and serves the Terms service from store in
+// the background. fail is called if it stops serving other than by
+// StopGRPCServer.
+func StartGRPCServer(addr string, store termstore.TermStore, fail func(error)) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	server := grpc.NewServer()
+	termspb.RegisterTermsServer(server, grpcTerms{store: store})
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			fail(fmt.Errorf("gRPC server on %s failed: %w", listener.Addr(), err))
+		}
+	}()
+	slog.Info("gRPC server is running", "addr", listener.Addr().String())
+	return server, nil
+}
+
+// StopGRPCServer lets in-flight calls finish for up to grace, then cuts
+// off whatever is left.
+func StopGRPCServer(server *grpc.Server, grace time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+		server.Stop()
+	}
+}
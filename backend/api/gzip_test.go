@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var timeTook = regexp.MustCompile(`"time_took":"[^"]*"`)
+
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("response isn't gzip: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompressing: %v", err)
+	}
+	return data
+}
+
+func TestGzipMatchesUncompressed(t *testing.T) {
+	terms := make(map[string]string)
+	for i := range 200 {
+		terms[fmt.Sprintf("Term %03d", i)] = fmt.Sprintf("Definition number %d, long enough to be worth compressing.", i)
+	}
+	h := gzipMiddleware(newTestServer(t, newTestStore(t, terms)))
+
+	for _, target := range []string{"/api/v1/terms", "/api/v1/terms/search?q=definition", "/api/v1/export?format=csv"} {
+		plain := serve(t, h, http.MethodGet, target, nil)
+		zipped := serve(t, h, http.MethodGet, target, nil, "Accept-Encoding", "gzip")
+
+		if ce := plain.Header().Get("Content-Encoding"); ce != "" {
+			t.Errorf("%s without Accept-Encoding has Content-Encoding %q", target, ce)
+		}
+		if ce := zipped.Header().Get("Content-Encoding"); ce != "gzip" {
+			t.Fatalf("%s with Accept-Encoding: gzip has Content-Encoding %q", target, ce)
+		}
+		if vary := zipped.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept-Encoding") {
+			t.Errorf("%s Vary = %q, want Accept-Encoding in it", target, vary)
+		}
+		if zipped.Header().Get("Content-Length") != "" {
+			t.Errorf("%s kept a Content-Length for the uncompressed body", target)
+		}
+		// Searches report how long they took, which is all that may differ
+		got, want := gunzip(t, zipped.Body.Bytes()), plain.Body.Bytes()
+		got, want = timeTook.ReplaceAll(got, nil), timeTook.ReplaceAll(want, nil)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s decompressed differs from the uncompressed response", target)
+		}
+		if zipped.Body.Len() >= plain.Body.Len() {
+			t.Errorf("%s compressed to %d bytes from %d", target, zipped.Body.Len(), plain.Body.Len())
+		}
+	}
+}
+
+func TestGzipSkips(t *testing.T) {
+	big := strings.Repeat("compressible ", 1000)
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		acceptEncoding string
+	}{
+		{"small body", "text/plain", "short", "gzip"},
+		{"already compressed", "application/zip", big, "gzip"},
+		{"not accepted", "text/plain", big, "br"},
+		{"refused", "text/plain", big, "gzip;q=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			rec := serve(t, h, http.MethodGet, "/", nil, "Accept-Encoding", tt.acceptEncoding)
+			if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+				t.Errorf("Content-Encoding = %q, want none", ce)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("body was altered")
+			}
+		})
+	}
+}
+
+func TestGzipStreams(t *testing.T) {
+	flushed := make(chan struct{})
+	h := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		close(flushed)
+		io.WriteString(w, "data: second\n\n")
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(rec, req)
+		close(done)
+	}()
+	<-flushed
+	<-done
+
+	if !rec.Flushed {
+		t.Error("a flush by the handler didn't reach the client")
+	}
+	if got := string(gunzip(t, rec.Body.Bytes())); got != "data: first\n\ndata: second\n\n" {
+		t.Errorf("streamed body = %q", got)
+	}
+}
@@ -0,0 +1,876 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/unicode/norm"
+
+	termstore "scrape_cp/store"
+)
+
+// TermNotFoundResponse is the 404 for a term lookup, with the names the
+// caller most likely meant.
+type TermNotFoundResponse struct {
+	ErrorResponse
+	Suggestions []string `json:"suggestions"`
+	// Candidates lists the Wikipedia articles the term might mean, when
+	// ?enrich=true found a disambiguation page for it
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// HealthResponse reports whether the server is ready: Status is "ok" once
+// the terms have been loaded, and "starting" while the first scrape is
+// still running.
+type HealthResponse struct {
+	Status string `json:"status"`
+	Ready  bool   `json:"ready"`
+	Terms  int    `json:"terms"`
+}
+
+type RandomResponse struct {
+	Terms []termstore.TermResponse `json:"terms"`
+	Count int                      `json:"count"`
+	Seed  string                   `json:"seed,omitempty"`
+}
+
+type TodayResponse struct {
+	Date string `json:"date"`
+	termstore.TermResponse
+}
+
+type RelatedResponse struct {
+	Term    string                   `json:"term"`
+	Related []termstore.TermResponse `json:"related"`
+	Count   int                      `json:"count"`
+}
+
+type LookupResult struct {
+	Term       string   `json:"term"`
+	Match      string   `json:"match,omitempty"`
+	Definition string   `json:"definition,omitempty"`
+	Found      bool     `json:"found"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+type SuggestResponse struct {
+	Suggestions []string `json:"suggestions"`
+	Count       int      `json:"count"`
+	Query       string   `json:"query"`
+}
+
+type LetterResponse struct {
+	Letter string                   `json:"letter"`
+	Terms  []termstore.TermResponse `json:"terms"`
+	Count  int                      `json:"count"`
+}
+
+type LettersResponse struct {
+	Letters []termstore.LetterCount `json:"letters"`
+}
+
+type TermsResponse struct {
+	XMLName xml.Name                 `json:"-" xml:"terms" yaml:"-"`
+	Terms   []termstore.TermResponse `json:"terms" xml:"term" yaml:"terms"`
+	Count   int                      `json:"count" xml:"count,attr" yaml:"count"`
+	Total   int                      `json:"total" xml:"total,attr" yaml:"total"`
+	Limit   int                      `json:"limit,omitempty" xml:"limit,attr,omitempty" yaml:"limit,omitempty"`
+	Offset  int                      `json:"offset" xml:"offset,attr" yaml:"offset"`
+	Sort    string                   `json:"sort" xml:"sort,attr" yaml:"sort"`
+	Filters *AppliedFilters          `json:"filters,omitempty" xml:"filters,omitempty" yaml:"filters,omitempty"`
+}
+
+type SearchResponse struct {
+	XMLName  xml.Name                 `json:"-" xml:"search" yaml:"-"`
+	Terms    []termstore.TermResponse `json:"terms" xml:"term" yaml:"terms"`
+	Count    int                      `json:"count" xml:"count,attr" yaml:"count"`
+	Total    int                      `json:"total" xml:"total,attr" yaml:"total"`
+	Limit    int                      `json:"limit" xml:"limit,attr" yaml:"limit"`
+	Offset   int                      `json:"offset" xml:"offset,attr" yaml:"offset"`
+	Query    string                   `json:"query,omitempty" xml:"query,attr,omitempty" yaml:"query,omitempty"`
+	Stemmed  bool                     `json:"stemmed" xml:"stemmed,attr" yaml:"stemmed"`
+	TimeTook string                   `json:"time_took" xml:"time_took,attr" yaml:"time_took"`
+	Filters  *AppliedFilters          `json:"filters,omitempty" xml:"filters,omitempty" yaml:"filters,omitempty"`
+}
+
+// AppliedFilters echoes the filters a listing or search was narrowed by,
+// so clients can tell what they got.
+type AppliedFilters struct {
+	Source    string `json:"source,omitempty" xml:"source,attr,omitempty" yaml:"source,omitempty"`
+	MinLength int    `json:"min_len,omitempty" xml:"min_len,attr,omitempty" yaml:"min_len,omitempty"`
+	MaxLength int    `json:"max_len,omitempty" xml:"max_len,attr,omitempty" yaml:"max_len,omitempty"`
+}
+
+const (
+	maxRandomCount = 50
+
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+
+	maxLookupTerms = 500
+	// maxLookupBytes caps the size of a lookup request body.
+	maxLookupBytes = 64 << 10
+
+	defaultRelatedLimit = 10
+	maxRelatedLimit     = 50
+
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 100
+
+	// Queries are matched against every term, so their length is capped;
+	// fuzzy matching costs more per character and gets a lower cap
+	maxQueryLength      = 200
+	maxFuzzyQueryLength = 50
+
+	// notFoundSuggestions caps the names offered when a term lookup misses
+	notFoundSuggestions = 5
+
+	dateLayout      = "2006-01-02"
+	TimestampLayout = "2006-01-02_15-04-05"
+
+	// Backfilling with ?date= can ask for arbitrary days, so the per-date
+	// cache of term-of-the-day picks is bounded.
+	maxRememberedDays = 366
+)
+
+// getHealth answers 503 until the terms have been loaded, so a readiness
+// probe holds traffic back while the first scrape runs.
+func getHealth(w http.ResponseWriter, r *http.Request) {
+	terms := storeOf(r.Context()).Len()
+	if !ready() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "starting", Terms: terms})
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok", Ready: true, Terms: terms})
+}
+
+func getAllTerms(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	format, ok := negotiate(r)
+	if !ok {
+		writeNotAcceptable(w, r)
+		return
+	}
+	if checkNotModified(w, r, format) {
+		return
+	}
+
+	order := r.URL.Query().Get("sort")
+	if order == "" {
+		order = termstore.SortAlpha
+	}
+	if !slices.Contains(termstore.SortOrders, order) {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter,
+			"sort must be one of "+strings.Join(termstore.SortOrders, ", "))
+		return
+	}
+
+	// The listing returns everything unless a limit is asked for
+	page, err := parsePagination(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	filters, ok := parseTermFilters(w, r)
+	if !ok {
+		return
+	}
+
+	var pageTerms []termstore.TermResponse
+	var total int
+	if b := store.Persistent(); b != nil && order == termstore.SortAlpha && filters == (termFilters{}) {
+		// The backend pages through the terms itself
+		if pageTerms, total, err = backendPage(b, page); err != nil {
+			writeStoreUnavailable(w, r, "Failed to list stored terms", err)
+			return
+		}
+	} else {
+		// List hands back a listing no writer touches, so the lock isn't held
+		// while encoding
+		terms := store.List(termstore.ListOptions{Order: order, Source: filters.source, Length: filters.length})
+		pageTerms, total = paginate(terms, page), len(terms)
+	}
+	writeFormatted(w, r, format, http.StatusOK, TermsResponse{
+		Terms:   pageTerms,
+		Count:   len(pageTerms),
+		Total:   total,
+		Limit:   page.limit,
+		Offset:  page.offset,
+		Sort:    order,
+		Filters: filters.applied(),
+	})
+}
+
+func getTerm(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	format, ok := negotiate(r)
+	if !ok {
+		writeNotAcceptable(w, r)
+		return
+	}
+	// Enriching can change the term, so the version is only checked
+	// afterwards
+	enrich, _ := strconv.ParseBool(r.URL.Query().Get("enrich"))
+	if !enrich && checkNotModified(w, r, format) {
+		return
+	}
+
+	linkify := r.URL.Query().Get("linkify")
+	if linkify != "" && linkify != "html" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, "linkify must be html")
+		return
+	}
+
+	name, ok := pathVar(w, r, "term")
+	if !ok {
+		return
+	}
+	t, exists, err := findTerm(store, name)
+	if err != nil {
+		writeStoreUnavailable(w, r, "Failed to look up a stored term", err)
+		return
+	}
+	var candidates []string
+	if enrich && needsEnrichment(t, exists) {
+		t, exists, candidates = enrichTerm(r.Context(), store, name, t, exists)
+	}
+	if enrich && checkNotModified(w, r, format) {
+		return
+	}
+	if !exists {
+		writeTermNotFound(w, store, name, candidates)
+		return
+	}
+	term := t.Detail()
+	term.Candidates = candidates
+	if linkify == "html" {
+		term.Definition, _ = store.Linkified(term.Term, apiV1.prefix+"/terms/")
+	}
+
+	writeFormatted(w, r, format, http.StatusOK, term)
+}
+
+func getRelatedTerms(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRelatedLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxRelatedLimit)
+	}
+
+	name, ok := pathVar(w, r, "term")
+	if !ok {
+		return
+	}
+	store := storeOf(r.Context())
+	term, related, exists := store.Related(name, limit)
+	if !exists {
+		writeTermNotFound(w, store, name, nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RelatedResponse{
+		Term:    term,
+		Related: related,
+		Count:   len(related),
+	})
+}
+
+// lookupTerms resolves a batch of term names in one request, answering in
+// the order they were asked for. With ?enrich=true the first
+// maxEnrichedLookups missing or thin terms are looked up on Wikipedia.
+func lookupTerms(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	if !decodeJSON(w, r, &names, maxLookupBytes, "request body must be a JSON array of term names") {
+		return
+	}
+	if len(names) == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "at least one term is required")
+		return
+	}
+	if len(names) > maxLookupTerms {
+		writeError(w, http.StatusBadRequest, codeInvalidBody,
+			fmt.Sprintf("at most %d terms can be looked up at once", maxLookupTerms))
+		return
+	}
+
+	enrich, _ := strconv.ParseBool(r.URL.Query().Get("enrich"))
+	enriched := 0
+	store := storeOf(r.Context())
+	results := make([]LookupResult, len(names))
+	for i, name := range names {
+		match, found, err := findTerm(store, name)
+		if err != nil {
+			writeStoreUnavailable(w, r, "Failed to look up a stored term", err)
+			return
+		}
+		var candidates []string
+		if enrich && enriched < maxEnrichedLookups && needsEnrichment(match, found) {
+			match, found, candidates = enrichTerm(r.Context(), store, name, match, found)
+			enriched++
+		}
+		results[i] = LookupResult{Term: name, Definition: match.Definition, Found: found, Candidates: candidates}
+		if found && match.Name != name {
+			results[i].Match = match.Name
+		}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// StemSearches, set with --search-stem, stems the words of search queries
+// that don't say otherwise with ?stem.
+var StemSearches bool
+
+func searchTerms(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	format, ok := negotiate(r)
+	if !ok {
+		writeNotAcceptable(w, r)
+		return
+	}
+	if checkNotModified(w, r, format) {
+		return
+	}
+
+	fuzzy, _ := strconv.ParseBool(r.URL.Query().Get("fuzzy"))
+	query, err := checkSearchQuery(r.URL.Query().Get("q"), fuzzy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+	query = strings.ToLower(query)
+
+	page, err := parsePagination(r, defaultPageLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	filters, ok := parseTermFilters(w, r)
+	if !ok {
+		return
+	}
+
+	if fuzzy {
+		searchFuzzy(w, r, format, query, page, filters, start)
+		return
+	}
+
+	fields, err := termstore.ParseSearchFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	op, err := termstore.ParseSearchOp(r.URL.Query().Get("op"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	exact, _ := strconv.ParseBool(r.URL.Query().Get("exact"))
+	stem := StemSearches
+	if raw := r.URL.Query().Get("stem"); raw != "" {
+		stem, _ = strconv.ParseBool(raw)
+	}
+	opts := termstore.SearchOptions{Fields: fields, Exact: exact, Op: op, Stem: stem}
+	m, err := termstore.NewMatcher(query, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+		return
+	}
+
+	var results []termstore.TermResponse
+	store := storeOf(r.Context())
+	if b := store.Persistent(); b != nil && fields == termstore.FieldsTerm && !stem {
+		// A search of names alone is answered by the backend
+		results, err = termstore.SearchNames(r.Context(), b, query, opts)
+	} else {
+		results, err = store.Search(r.Context(), query, opts)
+	}
+	if r.Context().Err() != nil {
+		// The client has gone, so there is no one to answer
+		return
+	}
+	if err != nil {
+		writeStoreUnavailable(w, r, "Failed to search stored terms", err)
+		return
+	}
+	writeSearchResults(w, r, format, m, results, page, filters, start)
+}
+
+// writeSearchResults filters and pages through results, which must already
+// be in their final deterministic order so consecutive pages never overlap,
+// and attaches highlighted snippets to the hits on the requested page.
+func writeSearchResults(w http.ResponseWriter, r *http.Request, format responseFormat, m termstore.Matcher, results []termstore.TermResponse, page pagination, filters termFilters, start time.Time) {
+	hl := termstore.DefaultHighlighter
+	if params := r.URL.Query(); params.Has("pre_tag") || params.Has("post_tag") {
+		hl = termstore.Highlighter{Pre: params.Get("pre_tag"), Post: params.Get("post_tag")}
+	}
+
+	results = termstore.WithLength(termstore.FromSource(results, filters.source), filters.length)
+	terms := paginate(results, page)
+	for i := range terms {
+		terms[i].Snippet = termstore.Snippet(terms[i].Definition, m, hl)
+	}
+
+	writeFormatted(w, r, format, http.StatusOK, SearchResponse{
+		Terms:    terms,
+		Count:    len(terms),
+		Total:    len(results),
+		Limit:    page.limit,
+		Offset:   page.offset,
+		Query:    m.Query(),
+		Stemmed:  m.Stemmed(),
+		TimeTook: time.Since(start).String(),
+		Filters:  filters.applied(),
+	})
+}
+
+func searchFuzzy(w http.ResponseWriter, r *http.Request, format responseFormat, query string, page pagination, filters termFilters, start time.Time) {
+	maxDistance := termstore.DefaultFuzzyDistance
+	if raw := r.URL.Query().Get("distance"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > termstore.MaxFuzzyDistance {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter,
+				fmt.Sprintf("distance must be an integer between 0 and %d", termstore.MaxFuzzyDistance))
+			return
+		}
+		maxDistance = n
+	}
+
+	// Fuzzy hits are highlighted where the query happens to match exactly,
+	// if anywhere
+	m, _ := termstore.NewMatcher(query, termstore.SearchOptions{})
+	results, err := storeOf(r.Context()).Fuzzy(r.Context(), query, maxDistance)
+	if err != nil {
+		return
+	}
+	writeSearchResults(w, r, format, m, results, page, filters, start)
+}
+
+func suggestTerms(w http.ResponseWriter, r *http.Request) {
+	query, err := checkQuery(r.URL.Query().Get("q"), "prefix", maxQueryLength)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	limit := defaultSuggestLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxSuggestLimit)
+	}
+
+	var names []string
+	store := storeOf(r.Context())
+	if b := store.Persistent(); b != nil {
+		terms, err := b.Search(query, false, limit)
+		if err != nil {
+			writeStoreUnavailable(w, r, "Failed to search stored terms", err)
+			return
+		}
+		names = make([]string, len(terms))
+		for i, t := range terms {
+			names[i] = t.Name
+		}
+	} else {
+		names = store.WithPrefix(query, limit)
+	}
+	writeJSON(w, http.StatusOK, SuggestResponse{
+		Suggestions: names,
+		Count:       len(names),
+		Query:       query,
+	})
+}
+
+// getTermNames lists the term names alone, for clients such as
+// autocompleters that have no use for the definitions. The total before
+// paging is sent in X-Total-Count, since the body is a bare array.
+func getTermNames(w http.ResponseWriter, r *http.Request) {
+	if checkNotModified(w, r, responseFormats[0]) {
+		return
+	}
+	page, err := parsePagination(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	source, ok := sourceParam(w, r)
+	if !ok {
+		return
+	}
+	names := storeOf(r.Context()).Names(r.URL.Query().Get("prefix"), source)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(names)))
+	writeJSON(w, http.StatusOK, paginate(names, page))
+}
+
+func getTermsByLetter(w http.ResponseWriter, r *http.Request) {
+	letter, ok := pathVar(w, r, "letter")
+	if !ok {
+		return
+	}
+	if letter != termstore.OtherBucket {
+		if utf8.RuneCountInString(letter) != 1 || !unicode.IsLetter([]rune(letter)[0]) {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "letter must be a single letter or "+termstore.OtherBucket)
+			return
+		}
+		letter = strings.ToUpper(letter)
+	}
+
+	terms := storeOf(r.Context()).ByLetter(letter)
+	writeJSON(w, http.StatusOK, LetterResponse{
+		Letter: letter,
+		Terms:  terms,
+		Count:  len(terms),
+	})
+}
+
+func getLetters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, LettersResponse{Letters: storeOf(r.Context()).LetterCounts()})
+}
+
+func getRandomTerms(w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "count must be a positive integer")
+			return
+		}
+		count = min(n, maxRandomCount)
+	}
+
+	// A seed makes the selection reproducible for a given dataset
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	seed := r.URL.Query().Get("seed")
+	if seed != "" {
+		h := fnv.New64a()
+		h.Write([]byte(seed))
+		rng = rand.New(rand.NewSource(int64(h.Sum64())))
+	}
+
+	terms := storeOf(r.Context()).Pick(func(n int) []int {
+		return distinctIndexes(rng, n, count)
+	})
+
+	if len(terms) == 0 {
+		writeError(w, http.StatusServiceUnavailable, codeNoTerms, "no terms available")
+		return
+	}
+
+	if !r.URL.Query().Has("count") {
+		writeJSON(w, http.StatusOK, terms[0])
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RandomResponse{
+		Terms: terms,
+		Count: len(terms),
+		Seed:  seed,
+	})
+}
+
+// The term of the day is derived from the UTC date alone, so every instance
+// and every restart agree on the pick. Picks are remembered per date so a
+// refresh that changes the dataset size can't change the answer mid-day.
+var termOfTheDay = struct {
+	sync.Mutex
+	picks map[string]string
+}{picks: make(map[string]string)}
+
+func getTermOfTheDay(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	date := time.Now().UTC().Format(dateLayout)
+	if raw := r.URL.Query().Get("date"); raw != "" {
+		if _, err := time.Parse(dateLayout, raw); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "date must be formatted as YYYY-MM-DD")
+			return
+		}
+		date = raw
+	}
+
+	termOfTheDay.Lock()
+	defer termOfTheDay.Unlock()
+
+	if term, ok := termOfTheDay.picks[date]; ok {
+		if t, exists := store.Get(term); exists && t.Name == term {
+			writeJSON(w, http.StatusOK, TodayResponse{Date: date, TermResponse: t.Response()})
+			return
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(date))
+	sum := h.Sum64()
+
+	terms := store.Pick(func(n int) []int {
+		if n == 0 {
+			return nil
+		}
+		return []int{int(sum % uint64(n))}
+	})
+
+	if len(terms) == 0 {
+		writeError(w, http.StatusServiceUnavailable, codeNoTerms, "no terms available")
+		return
+	}
+
+	rememberPick(date, terms[0].Term)
+
+	writeJSON(w, http.StatusOK, TodayResponse{Date: date, TermResponse: terms[0]})
+}
+
+// rememberPick records the term picked for date, first forgetting the
+// earliest remembered date other than today if the cache is full, so a
+// ?date= backfill can't make today's pick change.
+func rememberPick(date, term string) {
+	if len(termOfTheDay.picks) >= maxRememberedDays {
+		today := time.Now().UTC().Format(dateLayout)
+		oldest := ""
+		for d := range termOfTheDay.picks {
+			// Dates formatted with dateLayout sort chronologically
+			if d != today && (oldest == "" || d < oldest) {
+				oldest = d
+			}
+		}
+		delete(termOfTheDay.picks, oldest)
+	}
+	termOfTheDay.picks[date] = term
+}
+
+// distinctIndexes picks up to count distinct positions in [0, n)
+func distinctIndexes(rng *rand.Rand, n, count int) []int {
+	count = min(count, n)
+	seen := make(map[int]bool, count)
+	indexes := make([]int, 0, count)
+	for len(indexes) < count {
+		i := rng.Intn(n)
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+type pagination struct {
+	limit  int
+	offset int
+}
+
+// checkQuery validates a search query, described as what in errors: it
+// must be at most maxLength characters once trimmed and free of control
+// characters. It is returned trimmed and normalized to NFC, so it matches
+// the cleaned terms however its accents were composed.
+func checkQuery(query, what string, maxLength int) (string, error) {
+	query = strings.TrimSpace(query)
+	switch {
+	case query == "":
+		return "", fmt.Errorf("%s is required", what)
+	case utf8.RuneCountInString(query) > maxLength:
+		return "", fmt.Errorf("%s must be at most %d characters", what, maxLength)
+	case strings.ContainsFunc(query, unicode.IsControl):
+		return "", fmt.Errorf("%s must not contain control characters", what)
+	}
+	return norm.NFC.String(query), nil
+}
+
+// checkSearchQuery validates the query of a search, which is held to
+// a lower limit when it is fuzzy.
+func checkSearchQuery(query string, fuzzy bool) (string, error) {
+	if fuzzy {
+		return checkQuery(query, "fuzzy search query", maxFuzzyQueryLength)
+	}
+	return checkQuery(query, "search query", maxQueryLength)
+}
+
+// parsePagination reads the limit and offset query parameters, using
+// defaultLimit when no limit is given; zero means no limit. Limits above
+// maxPageLimit are clamped rather than rejected.
+func parsePagination(r *http.Request, defaultLimit int) (pagination, error) {
+	page := pagination{limit: defaultLimit}
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return page, errors.New("limit must be a positive integer")
+		}
+		page.limit = min(n, maxPageLimit)
+	}
+
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return page, errors.New("offset must be a non-negative integer")
+		}
+		page.offset = n
+	}
+
+	return page, nil
+}
+
+// termFilters narrows a listing or search to the terms from one source
+// and with definitions of a length.
+type termFilters struct {
+	source string
+	length termstore.LengthBounds
+}
+
+// parseTermFilters reads the source, min_len and max_len query parameters,
+// answering 400 if any is invalid. It reports whether the request should
+// go on.
+func parseTermFilters(w http.ResponseWriter, r *http.Request) (termFilters, bool) {
+	source, ok := sourceParam(w, r)
+	if !ok {
+		return termFilters{}, false
+	}
+	length, err := parseLengthBounds(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return termFilters{}, false
+	}
+	return termFilters{source: source, length: length}, true
+}
+
+// parseLengthBounds reads the min_len and max_len query parameters, which
+// bound the length of definitions in runes. Either may be left out.
+func parseLengthBounds(r *http.Request) (termstore.LengthBounds, error) {
+	var lb termstore.LengthBounds
+	for _, param := range []struct {
+		name  string
+		bound *int
+	}{{"min_len", &lb.Min}, {"max_len", &lb.Max}} {
+		raw := r.URL.Query().Get(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return lb, fmt.Errorf("%s must be a non-negative integer", param.name)
+		}
+		*param.bound = n
+	}
+	if lb.Max > 0 && lb.Min > lb.Max {
+		return lb, errors.New("min_len must not be greater than max_len")
+	}
+	return lb, nil
+}
+
+// applied describes the filters for a response, or is nil if there are
+// none.
+func (f termFilters) applied() *AppliedFilters {
+	if f == (termFilters{}) {
+		return nil
+	}
+	return &AppliedFilters{Source: f.source, MinLength: f.length.Min, MaxLength: f.length.Max}
+}
+
+func paginate[T any](items []T, page pagination) []T {
+	if page.offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if page.limit > 0 {
+		end = min(page.offset+page.limit, end)
+	}
+	return items[page.offset:end]
+}
+
+// pathVar returns the path parameter name of r, unescaped, or writes an
+// error if it can't be. The router matches the escaped path, so a term
+// with an encoded slash arrives whole. A "+" is kept as it is, since names
+// like C++ need it.
+func pathVar(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	value, err := url.PathUnescape(mux.Vars(r)[name])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, name+" is not a valid path segment")
+		return "", false
+	}
+	return value, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeTermNotFound answers a lookup of a term that doesn't exist with the
+// closest existing names, and any Wikipedia articles it might mean.
+func writeTermNotFound(w http.ResponseWriter, store termstore.TermStore, name string, candidates []string) {
+	writeJSON(w, http.StatusNotFound, TermNotFoundResponse{
+		ErrorResponse: newErrorResponse(w, codeTermNotFound, "term not found"),
+		Suggestions:   store.Suggest(name, notFoundSuggestions),
+		Candidates:    candidates,
+	})
+}
+
+// findTerm looks name up as TermStore.Get does, but in the backend when
+// the store has one. Aliases are only indexed in memory, so a name the
+// backend doesn't hold is looked for among them before giving up.
+func findTerm(store termstore.TermStore, name string) (termstore.Term, bool, error) {
+	b := store.Persistent()
+	if b == nil {
+		t, exists := store.Get(name)
+		return t, exists, nil
+	}
+	// Names are stored in NFC, as in the store
+	t, exists, err := b.Get(norm.NFC.String(name))
+	if err != nil || exists {
+		return t, exists, err
+	}
+	t, exists = store.Get(name)
+	return t, exists, nil
+}
+
+// writeStoreUnavailable logs a failed read of the backend and answers
+// with a 503, since the backend may well be back for the next request.
+func writeStoreUnavailable(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	logger(r.Context()).Error(msg, "err", err)
+	writeError(w, http.StatusServiceUnavailable, codeStoreUnavailable, "term store unavailable")
+}
+
+// backendPage reads one page of terms in alphabetical order straight from
+// b, with the total number of terms.
+func backendPage(b termstore.Backend, page pagination) ([]termstore.TermResponse, int, error) {
+	total, err := b.Count()
+	if err != nil {
+		return nil, 0, err
+	}
+	limit := page.limit
+	if limit == 0 {
+		limit = -1
+	}
+	terms, err := b.List(page.offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	responses := make([]termstore.TermResponse, len(terms))
+	for i, t := range terms {
+		responses[i] = t.Response()
+	}
+	return responses, total, nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRememberPickKeepsToday(t *testing.T) {
+	saved := termOfTheDay.picks
+	t.Cleanup(func() { termOfTheDay.picks = saved })
+	termOfTheDay.picks = make(map[string]string)
+
+	today := time.Now().UTC().Format(dateLayout)
+	rememberPick(today, "Cache")
+	// Backfill far more days than are remembered, all before today
+	start := time.Now().UTC().AddDate(-3, 0, 0)
+	for i := 0; i < 2*maxRememberedDays; i++ {
+		rememberPick(start.AddDate(0, 0, i).Format(dateLayout), fmt.Sprint("Term ", i))
+	}
+
+	if got := termOfTheDay.picks[today]; got != "Cache" {
+		t.Errorf("today's pick = %q after a backfill, want Cache", got)
+	}
+	if n := len(termOfTheDay.picks); n > maxRememberedDays {
+		t.Errorf("%d picks remembered, want at most %d", n, maxRememberedDays)
+	}
+	last := start.AddDate(0, 0, 2*maxRememberedDays-1).Format(dateLayout)
+	if _, ok := termOfTheDay.picks[last]; !ok {
+		t.Errorf("the latest backfilled date was evicted instead of an older one")
+	}
+}
+
+func TestTermOfTheDayKeepsItsShape(t *testing.T) {
+	saved := termOfTheDay.picks
+	t.Cleanup(func() { termOfTheDay.picks = saved })
+	termOfTheDay.picks = make(map[string]string)
+
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+		"Heap":  "Memory allocated at run time.",
+	}))
+	// The first request picks the term and the second finds it remembered
+	first := serve(t, h, http.MethodGet, "/api/v1/terms/today?date=2024-03-01", nil)
+	second := serve(t, h, http.MethodGet, "/api/v1/terms/today?date=2024-03-01", nil)
+	if first.Code != http.StatusOK || first.Body.String() != second.Body.String() {
+		t.Errorf("the same day was answered with\n%s\nthen\n%s", first.Body, second.Body)
+	}
+	if !strings.Contains(first.Body.String(), `"source":"Test"`) {
+		t.Errorf("the term of the day is missing its source: %s", first.Body)
+	}
+}
+
+// BenchmarkListTermsParallel measures how many listings the API serves
+// with every goroutine asking at once.
+func BenchmarkListTermsParallel(b *testing.B) {
+	terms := make(map[string]string)
+	for i := range 2000 {
+		terms[fmt.Sprintf("Term %04d", i)] = fmt.Sprintf("Definition number %d.", i)
+	}
+	h := newTestServer(b, newTestStore(b, terms))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if rec := serve(b, h, http.MethodGet, "/api/v1/terms?limit=100", nil); rec.Code != http.StatusOK {
+				b.Fatalf("listing = %d", rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+const (
+	// maxImportBytes caps the size of an import request body.
+	maxImportBytes = 10 << 20
+	// maxImportRejects is how many rejected entries an import reports.
+	maxImportRejects = 20
+)
+
+// WriteAPIKey, set with --write-api-key, must be sent as a bearer token
+// to endpoints that change the terms. They are disabled while it is empty.
+var WriteAPIKey string
+
+// importSource is the source imported definitions are credited to.
+var importSource = scraper.Source{Name: "import"}
+
+// ImportResponse summarizes an import. Rejects holds a sample of the
+// rejected entries, the first maxImportRejects of them.
+type ImportResponse struct {
+	DryRun   bool           `json:"dry_run"`
+	Added    int            `json:"added"`
+	Updated  int            `json:"updated"`
+	Rejected int            `json:"rejected"`
+	Rejects  []ImportReject `json:"rejects,omitempty"`
+}
+
+// ImportReject is an entry an import left out, and why.
+type ImportReject struct {
+	Term   string `json:"term"`
+	Reason string `json:"reason"`
+}
+
+// requireWriteKey only lets requests carrying WriteAPIKey through to next.
+func requireWriteKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if WriteAPIKey == "" {
+			writeError(w, http.StatusForbidden, codeWritesDisabled, "writes are disabled until the server is started with --write-api-key")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(WriteAPIKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "a valid API key is required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// importTerms merges a glossary posted as either a flat map of names to
+// definitions or a list of Terms, cleaning and validating each entry the
+// way scraped ones are.
+func importTerms(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	data, ok := readJSON(w, r, maxImportBytes)
+	if !ok {
+		return
+	}
+	terms, err := decodeImport(data)
+	if err != nil {
+		decodeError(w, err, "request body must be a JSON object of terms to definitions or a JSON array of terms")
+		return
+	}
+
+	response := ImportResponse{DryRun: dryRun}
+	valid := make(map[string][]string)
+	for _, t := range terms {
+		name := scraper.CleanText(t.Name)
+		for _, def := range importTexts(t) {
+			def = scraper.CleanText(def)
+			if reason := scraper.RejectReason(name, def); reason != "" {
+				response.Rejected++
+				if len(response.Rejects) < maxImportRejects {
+					response.Rejects = append(response.Rejects, ImportReject{Term: name, Reason: reason})
+				}
+				continue
+			}
+			valid[name] = append(valid[name], def)
+		}
+	}
+
+	// A dry run merges into a copy of the store, so the counts are exactly
+	// what a real import would report
+	target := store
+	if dryRun {
+		target = termstore.NewMemoryStore()
+		target.Upsert(store.Snapshot())
+	}
+	counts, err := target.Merge(valid, importSource)
+	if err == nil && !dryRun && counts.Added+counts.Updated > 0 {
+		err = store.Link()
+	}
+	if err != nil {
+		writeStoreUnavailable(w, r, "Failed to save imported terms", err)
+		return
+	}
+	response.Added, response.Updated = counts.Added, counts.Updated
+	writeJSON(w, http.StatusOK, response)
+}
+
+// decodeImport reads either import format, telling them apart by the
+// first character of the body.
+func decodeImport(data []byte) ([]termstore.Term, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var flat map[string]string
+		if err := decodeStrict(data, &flat); err != nil {
+			return nil, err
+		}
+		terms := make([]termstore.Term, 0, len(flat))
+		for name, def := range flat {
+			terms = append(terms, termstore.Term{Name: name, Definition: def})
+		}
+		// Sorted so the same body always reports the same sample of rejects
+		slices.SortFunc(terms, func(a, b termstore.Term) int { return strings.Compare(a.Name, b.Name) })
+		return terms, nil
+	}
+
+	var terms []termstore.Term
+	if err := decodeStrict(data, &terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// importTexts returns every definition of a term being imported.
+func importTexts(t termstore.Term) []string {
+	if len(t.Definitions) == 0 {
+		return []string{t.Definition}
+	}
+	texts := make([]string, len(t.Definitions))
+	for i, sense := range t.Definitions {
+		texts[i] = sense.Text
+	}
+	return texts
+}
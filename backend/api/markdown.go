@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	termstore "scrape_cp/store"
+)
+
+// markdownEscaper backslash-escapes the characters that would otherwise be
+// read as emphasis, links, code or HTML.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+	"\n", " ",
+)
+
+// renderMarkdown writes terms as a glossary page: a table of contents
+// linking to each letter, then one section per letter. terms must be in
+// alphabetical order.
+func renderMarkdown(w io.Writer, terms []termstore.Term) error {
+	groups := make(map[string][]termstore.Term)
+	for _, t := range terms {
+		letter := termstore.LetterOf(t.Name)
+		groups[letter] = append(groups[letter], t)
+	}
+
+	letters := make([]string, 0, len(groups))
+	for letter := range groups {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i] == termstore.OtherBucket ||
+			letters[j] != termstore.OtherBucket && letters[i] < letters[j]
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Computer Science Terms\n\n")
+
+	toc := make([]string, len(letters))
+	for i, letter := range letters {
+		toc[i] = fmt.Sprintf("[%s](#%s)", markdownEscaper.Replace(letter), markdownAnchor(letter))
+	}
+	fmt.Fprintf(bw, "%s\n", strings.Join(toc, " · "))
+
+	for _, letter := range letters {
+		fmt.Fprintf(bw, "\n## %s\n", markdownHeading(letter))
+		for _, t := range groups[letter] {
+			fmt.Fprintf(bw, "\n**%s** — %s\n",
+				markdownEscaper.Replace(t.Name), markdownEscaper.Replace(t.Definition))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// markdownHeading is the section title for a letter bucket. The catch-all
+// bucket gets a word, since a lone "#" heading has no usable anchor.
+func markdownHeading(letter string) string {
+	if letter == termstore.OtherBucket {
+		return "Other"
+	}
+	return letter
+}
+
+// markdownAnchor matches the anchor renderers generate for a heading.
+func markdownAnchor(letter string) string {
+	return strings.ToLower(markdownHeading(letter))
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+
+	termstore "scrape_cp/store"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	// In the order Snapshot returns them
+	terms := []termstore.Term{
+		{Name: ".NET", Definition: "A developer platform from Microsoft."},
+		{Name: "Algorithm", Definition: "A finite sequence of well-defined instructions."},
+		{Name: "API", Definition: "An interface between programs; see <https://example.com>."},
+		{Name: "C++", Definition: "A language whose name uses the `++` operator."},
+		{Name: "Glob", Definition: "A pattern such as *.go or file_[0-9].txt."},
+		{Name: "Kernel", Definition: "The core of an operating system.\nIt manages resources."},
+		{Name: "snake_case", Definition: `Words joined by underscores, with \ left alone.`},
+	}
+
+	var buf bytes.Buffer
+	if err := renderMarkdown(&buf, terms); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "glossary.md", buf.Bytes())
+}
+
+func TestRenderMarkdownEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderMarkdown(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "glossary_empty.md", buf.Bytes())
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	termstore "scrape_cp/store"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scrape_cp_http_requests_total",
+		Help: "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scrape_cp_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	scrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scrape_cp_scrape_duration_seconds",
+		Help:    "Time taken to fetch and parse a source.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"source"})
+
+	scrapedTerms = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scrape_cp_scraped_terms_total",
+		Help: "Valid terms extracted from a source.",
+	}, []string{"source"})
+
+	scrapeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scrape_cp_scrape_failures_total",
+		Help: "Failed scrapes of a source, by the stage that failed.",
+	}, []string{"source", "reason"})
+)
+
+// newTermsGauge reports the size of store, which the server registers
+// when it starts.
+func newTermsGauge(store termstore.TermStore) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "scrape_cp_terms",
+		Help: "Number of terms currently in the store.",
+	}, func() float64 { return float64(store.Len()) })
+}
+
+// metricsMiddleware records every request against its route template, so
+// /terms/{term} is one series however many terms are looked up.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		route := routeOf(r)
+		status := strconv.Itoa(rec.status)
+		httpRequests.WithLabelValues(r.Method, route, status).Inc()
+		httpDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeOf returns the path template of the route r matched, or
+// "unmatched", keeping the number of distinct routes reported bounded.
+func routeOf(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// responseRecorder captures the status code and size of a response on its
+// way through, while still letting streaming handlers flush.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack lets protocol upgrades through the recorder.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+	return h.Hijack()
+}
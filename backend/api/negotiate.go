@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// responseFormat is a representation the read endpoints can produce.
+type responseFormat struct {
+	name        string
+	contentType string
+	// aliases are other media types clients use for the same format
+	aliases []string
+	encode  func(w io.Writer, v interface{}) error
+}
+
+var responseFormats = []responseFormat{
+	{
+		name:        "json",
+		contentType: "application/json",
+		encode: func(w io.Writer, v interface{}) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	},
+	{
+		name:        "xml",
+		contentType: "application/xml",
+		aliases:     []string{"text/xml"},
+		encode: func(w io.Writer, v interface{}) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			return xml.NewEncoder(w).Encode(v)
+		},
+	},
+	{
+		name:        "yaml",
+		contentType: "application/x-yaml",
+		aliases:     []string{"application/yaml", "text/yaml"},
+		encode: func(w io.Writer, v interface{}) error {
+			enc := yaml.NewEncoder(w)
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+	},
+}
+
+// formatNamed returns the response format called name.
+func formatNamed(name string) (responseFormat, bool) {
+	for _, f := range responseFormats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return responseFormat{}, false
+}
+
+// negotiate picks the response format for r from those its API version
+// offers. The format query parameter wins over the Accept header for
+// clients that can't set headers, and the version's first format is used
+// when neither asks for anything in particular.
+func negotiate(r *http.Request) (responseFormat, bool) {
+	formats := versionOf(r).formats
+
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range formats {
+			if f.name == name {
+				return f, true
+			}
+		}
+		return responseFormat{}, false
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formats[0], true
+	}
+
+	best, bestQ := responseFormat{}, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if f, ok := formatFor(formats, mediaType); ok && q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+func formatFor(formats []responseFormat, mediaType string) (responseFormat, bool) {
+	if mediaType == "*/*" || mediaType == "application/*" {
+		return formats[0], true
+	}
+	for _, f := range formats {
+		if f.contentType == mediaType {
+			return f, true
+		}
+		for _, alias := range f.aliases {
+			if alias == mediaType {
+				return f, true
+			}
+		}
+	}
+	return responseFormat{}, false
+}
+
+// writeNotAcceptable tells the client which representations are available.
+func writeNotAcceptable(w http.ResponseWriter, r *http.Request) {
+	formats := versionOf(r).formats
+	available := make([]string, len(formats))
+	for i, f := range formats {
+		available[i] = f.contentType
+	}
+	writeErrorDetails(w, http.StatusNotAcceptable, codeNotAcceptable,
+		"supported media types are "+strings.Join(available, ", "), map[string]any{"available": available})
+}
+
+func writeFormatted(w http.ResponseWriter, r *http.Request, format responseFormat, status int, v interface{}) {
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if err := format.encode(w, v); err != nil {
+		logger(r.Context()).Error("Failed to encode response", "format", format.name, "err", err)
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The OpenAPI document is assembled from the routing table, with schemas
+// derived from the Go response types, so it describes exactly what the
+// router serves.
+
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*openAPIOp `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOp struct {
+	Summary     string                     `json:"summary"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Parameters  []openAPIParam             `json:"parameters,omitempty"`
+	RequestBody *openAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParam struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	Type       string                    `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Enum       []string                  `json:"enum,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)`)
+
+// openAPISpec is built by registerAPI alongside the routes it describes.
+var openAPISpec openAPIDoc
+
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec)
+}
+
+func buildOpenAPI() openAPIDoc {
+	schemas := make(map[string]*openAPISchema)
+	doc := openAPIDoc{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "CS Terms API", Version: apiV1.name},
+		Paths:      make(map[string]map[string]*openAPIOp),
+		Components: openAPIComponents{Schemas: schemas},
+	}
+
+	add := func(path string, rt route, deprecated bool) {
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOp)
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = buildOperation(rt, deprecated, schemas)
+	}
+
+	for _, rt := range rootRoutes {
+		add(rt.path, rt, false)
+	}
+	for _, version := range apiMounts {
+		for _, rt := range apiRoutes {
+			add(version.prefix+rt.path, rt, version.successor != nil)
+		}
+	}
+
+	// Every operation answers errors with ErrorResponse, so its schema is
+	// there to list the codes on
+	schemas["ErrorResponse"].Properties["code"].Enum = errorCodes
+	return doc
+}
+
+func buildOperation(rt route, deprecated bool, schemas map[string]*openAPISchema) *openAPIOp {
+	op := &openAPIOp{
+		Summary:    rt.summary,
+		Deprecated: deprecated,
+		Responses: map[string]openAPIResponse{
+			"default": {
+				Description: "error",
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: schemaFor(reflect.TypeOf(ErrorResponse{}), schemas)},
+				},
+			},
+		},
+	}
+
+	for _, m := range pathParamPattern.FindAllStringSubmatch(rt.path, -1) {
+		op.Parameters = append(op.Parameters, openAPIParam{
+			Name: m[1], In: "path", Required: true, Schema: &openAPISchema{Type: "string"},
+		})
+	}
+	for _, p := range rt.params {
+		op.Parameters = append(op.Parameters, openAPIParam{
+			Name: p.name, In: "query", Description: p.description,
+			Required: p.required, Schema: &openAPISchema{Type: p.kind},
+		})
+	}
+
+	if rt.request != nil {
+		op.RequestBody = &openAPIBody{
+			Required: true,
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: schemaFor(reflect.TypeOf(rt.request), schemas)},
+			},
+		}
+	}
+
+	ok := openAPIResponse{Description: "success", Content: make(map[string]openAPIMediaType)}
+	if rt.response != nil {
+		schema := schemaFor(reflect.TypeOf(rt.response), schemas)
+		ok.Content["application/json"] = openAPIMediaType{Schema: schema}
+	}
+	for _, mediaType := range rt.produces {
+		ok.Content[mediaType] = openAPIMediaType{Schema: &openAPISchema{Type: "string"}}
+	}
+	op.Responses["200"] = ok
+
+	if rt.notFound != nil {
+		op.Responses["404"] = openAPIResponse{
+			Description: "not found",
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: schemaFor(reflect.TypeOf(rt.notFound), schemas)},
+			},
+		}
+	}
+	return op
+}
+
+// schemaFor describes t, registering named struct types as components and
+// referring to them so shared types like TermResponse appear once.
+func schemaFor(t reflect.Type, schemas map[string]*openAPISchema) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Uint, reflect.Uint64, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object"}
+	case reflect.Struct:
+		ref := &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+		if _, done := schemas[t.Name()]; !done {
+			schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+			schemas[t.Name()] = schema
+			addProperties(schema, t, schemas)
+		}
+		return ref
+	}
+	return &openAPISchema{}
+}
+
+// addProperties adds the JSON-visible fields of t to schema, flattening
+// embedded structs the way encoding/json does.
+func addProperties(schema *openAPISchema, t reflect.Type, schemas map[string]*openAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addProperties(schema, f.Type, schemas)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = schemaFor(f.Type, schemas)
+	}
+}
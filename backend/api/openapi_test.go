@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	store := newTestStore(t, nil)
+	router := NewRouter(store)
+
+	rec := serve(t, router, http.MethodGet, "/api/openapi.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json answered %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("the document isn't valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want a 3.x version", doc.OpenAPI)
+	}
+
+	// Every route the router serves is documented
+	served := make(map[string]map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// The catch-all routes answering other methods with 405
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		if served[path] == nil {
+			served[path] = make(map[string]bool)
+		}
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			served[path][method] = true
+			if _, ok := doc.Paths[path][method]; !ok {
+				t.Errorf("%s %s is served but not in the OpenAPI document", strings.ToUpper(method), path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !served["/api/v1/terms"]["get"] {
+		t.Fatalf("walking the router didn't find GET /api/v1/terms")
+	}
+
+	// and nothing else is
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			if !served[path][method] {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	// Referenced schemas exist
+	for _, ref := range strings.Split(rec.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is referenced but not defined", name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"scrape_cp/internal/atomicfile"
+	termstore "scrape_cp/store"
+)
+
+// LegacyOutput also saves terms in the original flat term → definition
+// format, for consumers that haven't moved to the list of Terms.
+var LegacyOutput bool
+
+// OutputDir, set with --output-dir, is where terms are saved after each
+// scrape and where earlier snapshots are looked for.
+var OutputDir = "output"
+
+// latestOutput always holds the most recently saved terms, so consumers
+// have a path that doesn't change with each scrape.
+const latestOutput = "cs_terms_latest.json"
+
+// outputFormats are the formats chosen with --output-format, each saved
+// to its own file after every scrape.
+var outputFormats = []string{"json"}
+
+// OutputFormats returns the formats saved after every scrape.
+func OutputFormats() []string {
+	return outputFormats
+}
+
+// SetOutputFormats sets the formats saved after every scrape, from the
+// names given to --output-format.
+func SetOutputFormats(names []string) error {
+	var formats []string
+	for _, name := range names {
+		if !slices.Contains(OutputFormatNames, name) {
+			return fmt.Errorf("%q must be one of %s", name, strings.Join(OutputFormatNames, ", "))
+		}
+		if !slices.Contains(formats, name) {
+			formats = append(formats, name)
+		}
+	}
+	if len(formats) == 0 {
+		return errors.New("no formats given")
+	}
+	outputFormats = formats
+	return nil
+}
+
+// SaveOutput writes every term in store under timestamp in each of
+// outputFormats, returning the names of the files written. All of them are
+// written from the same copy of the store, so they agree with each other.
+func SaveOutput(timestamp string, store termstore.TermStore) ([]string, error) {
+	terms := store.Snapshot()
+	var files []string
+	for _, name := range outputFormats {
+		if name == "json" {
+			filename, err := saveSnapshot(timestamp, terms)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, filename)
+			continue
+		}
+
+		format := outputFileFormats[name]
+		filename := filepath.Join(OutputDir, fmt.Sprintf("cs_terms_%s.%s", timestamp, format.extension))
+		if err := writeExportFile(filename, format, terms); err != nil {
+			return files, fmt.Errorf("writing %s: %w", name, err)
+		}
+		files = append(files, filename)
+	}
+	return files, nil
+}
+
+// saveSnapshot writes terms to a JSON file named for timestamp, and to
+// latestOutput, then rotates out old snapshots. It returns the first
+// file's name.
+func saveSnapshot(timestamp string, terms []termstore.Term) (string, error) {
+	filename := filepath.Join(OutputDir, fmt.Sprintf("cs_terms_%s.json", timestamp))
+	if err := writeJSONFile(filename, terms); err != nil {
+		return "", err
+	}
+	snapshots.record(Snapshot{Timestamp: timestamp, Time: time.Now(), File: filename, Terms: len(terms)})
+
+	// A copy rather than a symlink, which not every system can make
+	if err := writeJSONFile(filepath.Join(OutputDir, latestOutput), terms); err != nil {
+		return "", err
+	}
+
+	if LegacyOutput {
+		flat := make(map[string]string, len(terms))
+		for _, t := range terms {
+			flat[t.Name] = t.Definition
+		}
+		if err := writeJSONFile(filepath.Join(OutputDir, fmt.Sprintf("cs_terms_%s_legacy.json", timestamp)), flat); err != nil {
+			return "", err
+		}
+	}
+	rotateSnapshots(OutputDir)
+	return filename, nil
+}
+
+func writeJSONFile(filename string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("converting to JSON: %w", err)
+	}
+
+	if err := atomicfile.Write(filename, func(w io.Writer) error {
+		_, err := w.Write(jsonData)
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	return nil
+}
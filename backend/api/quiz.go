@@ -0,0 +1,328 @@
+package api
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	termstore "scrape_cp/store"
+)
+
+const (
+	defaultQuizCount = 10
+	maxQuizCount     = 50
+
+	// quizOptions is how many terms each question offers, one of them right
+	quizOptions = 4
+	// quizLengthSlack is how many characters longer or shorter than the
+	// answer a term may be and still make a plausible wrong option
+	quizLengthSlack = 3
+
+	// maxQuizAnswerBytes caps the size of an answer request body.
+	maxQuizAnswerBytes = 16 << 10
+)
+
+// QuizSecret, set with --quiz-secret, signs quiz answer tokens. Without
+// one a random key is used, so tokens stop working when the server
+// restarts and only the instance that issued them accepts them.
+var QuizSecret string
+
+var randomQuizKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	crand.Read(key)
+	return key
+})
+
+func quizKey() []byte {
+	if QuizSecret != "" {
+		return []byte(QuizSecret)
+	}
+	return randomQuizKey()
+}
+
+// QuizResponse is a set of multiple-choice questions, each about a
+// different term.
+type QuizResponse struct {
+	Items []QuizItem `json:"items"`
+	Count int        `json:"count"`
+	Query string     `json:"query,omitempty"`
+}
+
+// QuizItem asks which of Options a definition belongs to. Token is sent
+// back with the choice to POST /quiz/answer to find out.
+type QuizItem struct {
+	Definition string   `json:"definition"`
+	Options    []string `json:"options"`
+	Token      string   `json:"token"`
+}
+
+// QuizAnswer is a choice made for the question Token was issued with.
+type QuizAnswer struct {
+	Token  string `json:"token"`
+	Choice string `json:"choice"`
+}
+
+// QuizResult says whether a choice was right, and what was.
+type QuizResult struct {
+	Correct bool   `json:"correct"`
+	Choice  string `json:"choice"`
+	Answer  string `json:"answer"`
+}
+
+func getQuiz(w http.ResponseWriter, r *http.Request) {
+	count := defaultQuizCount
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "count must be a positive integer")
+			return
+		}
+		count = min(n, maxQuizCount)
+	}
+
+	store := storeOf(r.Context())
+	names := store.Names("", "")
+	if len(names) < quizOptions {
+		writeError(w, http.StatusServiceUnavailable, codeNoTerms, "not enough terms for a quiz")
+		return
+	}
+
+	// Questions can be limited to a topic, but wrong options still come
+	// from every term so that a narrow topic still has enough of them
+	pool := names
+	var query string
+	if raw := r.URL.Query().Get("q"); strings.TrimSpace(raw) != "" {
+		var err error
+		if query, err = checkQuery(raw, "query", maxQueryLength); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+		if _, err := termstore.NewMatcher(query, termstore.SearchOptions{}); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidQuery, err.Error())
+			return
+		}
+		results, err := store.Search(r.Context(), query, termstore.SearchOptions{})
+		if err != nil {
+			return
+		}
+		pool = nil
+		for _, t := range results {
+			pool = append(pool, t.Term)
+		}
+		if len(pool) == 0 {
+			writeError(w, http.StatusNotFound, codeNoMatchingTerms, "no terms match the query")
+			return
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	wrong := newQuizNames(names)
+	items := make([]QuizItem, 0, min(count, len(pool)))
+	for _, i := range distinctIndexes(rng, len(pool), count) {
+		t, ok := store.Get(pool[i])
+		if !ok {
+			// Deleted since the pool was taken
+			continue
+		}
+		options := append(wrong.distractors(rng, t.Name), t.Name)
+		rng.Shuffle(len(options), func(i, j int) {
+			options[i], options[j] = options[j], options[i]
+		})
+		answer := 0
+		for options[answer] != t.Name {
+			answer++
+		}
+		items = append(items, QuizItem{
+			Definition: t.Definition,
+			Options:    options,
+			Token:      signQuizToken(options, answer),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, QuizResponse{Items: items, Count: len(items), Query: query})
+}
+
+// quizNames holds the names wrong options are picked from, bucketed once
+// per quiz by their lowercased first letter and by their length in runes,
+// so that each question only looks at the names it could use.
+type quizNames struct {
+	all      []string
+	byLetter map[rune][]string
+	byLength map[int][]string
+}
+
+func newQuizNames(names []string) quizNames {
+	q := quizNames{all: names, byLetter: make(map[rune][]string), byLength: make(map[int][]string)}
+	for _, name := range names {
+		first, _ := utf8.DecodeRuneInString(strings.ToLower(name))
+		length := utf8.RuneCountInString(name)
+		q.byLetter[first] = append(q.byLetter[first], name)
+		q.byLength[length] = append(q.byLength[length], name)
+	}
+	return q
+}
+
+// distractors picks the wrong options for a question about answer, all
+// different. Terms starting with the same letter or about as long as the
+// answer are preferred, so that the right option can't be spotted by its
+// shape alone; the rest are only used when there are too few of those.
+func (q quizNames) distractors(rng *rand.Rand, answer string) []string {
+	first, _ := utf8.DecodeRuneInString(strings.ToLower(answer))
+	length := utf8.RuneCountInString(answer)
+	plausible := [][]string{q.byLetter[first]}
+	for n := length - quizLengthSlack; n <= length+quizLengthSlack; n++ {
+		plausible = append(plausible, q.byLength[n])
+	}
+
+	picked := make([]string, 0, quizOptions)
+	for _, buckets := range [][][]string{plausible, {q.all}} {
+		picked = pickNames(rng, buckets, quizOptions-1-len(picked), answer, picked)
+	}
+	return picked
+}
+
+// quizPickTries is how many random names pickNames draws for each one it
+// needs before going through the buckets instead.
+const quizPickTries = 8
+
+// pickNames appends to picked up to n names from buckets other than answer
+// and those already picked, taking each at random. A name in two buckets is a little more
+// likely to be drawn. Drawing costs nothing like going through large
+// buckets, so they are only gone through when draws keep failing, as they
+// will when the buckets hold few usable names.
+func pickNames(rng *rand.Rand, buckets [][]string, n int, answer string, picked []string) []string {
+	total := 0
+	for _, b := range buckets {
+		total += len(b)
+	}
+	if n <= 0 || total == 0 {
+		return picked
+	}
+
+	at := func(i int) string {
+		for _, b := range buckets {
+			if i < len(b) {
+				return b[i]
+			}
+			i -= len(b)
+		}
+		panic("index out of range")
+	}
+	usable := func(name string) bool { return name != answer && !slices.Contains(picked, name) }
+	want := len(picked) + n
+	for range n * quizPickTries {
+		if name := at(rng.Intn(total)); usable(name) {
+			if picked = append(picked, name); len(picked) == want {
+				return picked
+			}
+		}
+	}
+
+	var rest []string
+	for i := range total {
+		if name := at(i); usable(name) && !slices.Contains(rest, name) {
+			rest = append(rest, name)
+		}
+	}
+	for _, i := range distinctIndexes(rng, len(rest), want-len(picked)) {
+		picked = append(picked, rest[i])
+	}
+	return picked
+}
+
+// quizToken is what an answer token carries: the options of its question,
+// and a nonce so no two tokens are alike. Which option is right is only
+// in the token's signature, so it can't be read from the token.
+type quizToken struct {
+	Options []string `json:"o"`
+	Nonce   []byte   `json:"n"`
+}
+
+// signQuizToken returns the token for a question offering options, of
+// which the one at answer is right. The server keeps nothing; the token
+// alone is enough to check an answer later.
+func signQuizToken(options []string, answer int) string {
+	nonce := make([]byte, 12)
+	crand.Read(nonce)
+	payload, _ := json.Marshal(quizToken{Options: options, Nonce: nonce})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(quizMAC(payload, answer))
+}
+
+// quizMAC signs payload as a question whose answer is the option at
+// answer.
+func quizMAC(payload []byte, answer int) []byte {
+	mac := hmac.New(sha256.New, quizKey())
+	mac.Write(payload)
+	mac.Write([]byte{byte(answer)})
+	return mac.Sum(nil)
+}
+
+var errBadQuizToken = errors.New("token is not one this server issued")
+
+// verifyQuizToken returns the options of the question token was issued
+// for and which of them is right, trying the signature of each in turn.
+func verifyQuizToken(token string) ([]string, int, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, 0, errBadQuizToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, 0, errBadQuizToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, 0, errBadQuizToken
+	}
+	var tok quizToken
+	if err := json.Unmarshal(payload, &tok); err != nil || len(tok.Options) > quizOptions {
+		return nil, 0, errBadQuizToken
+	}
+	for i := range tok.Options {
+		if hmac.Equal(mac, quizMAC(payload, i)) {
+			return tok.Options, i, nil
+		}
+	}
+	return nil, 0, errBadQuizToken
+}
+
+func answerQuiz(w http.ResponseWriter, r *http.Request) {
+	var answer QuizAnswer
+	const expected = "request body must be a JSON object with a token and a choice"
+	if !decodeJSON(w, r, &answer, maxQuizAnswerBytes, expected) {
+		return
+	}
+	if answer.Token == "" || answer.Choice == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, expected)
+		return
+	}
+
+	options, right, err := verifyQuizToken(answer.Token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidAnswer, err.Error())
+		return
+	}
+	chosen := slices.Index(options, answer.Choice)
+	if chosen < 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidAnswer, "choice must be one of the question's options")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, QuizResult{
+		Correct: chosen == right,
+		Choice:  options[chosen],
+		Answer:  options[right],
+	})
+}
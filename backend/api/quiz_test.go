@@ -0,0 +1,73 @@
+package api
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuizChecksItsQuery(t *testing.T) {
+	captureLogs(t)
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache":  "Fast storage close to where it is used.",
+		"Heap":   "Memory allocated at run time.",
+		"Stack":  "A last-in, first-out collection.",
+		"Socket": "One end of a network connection.",
+	}))
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{strings.Repeat("a", maxQueryLength+1), `{"error":"query must be at most 200 characters","code":"invalid_query","request_id":"req-1"}`},
+		{"cache\x00", `{"error":"query must not contain control characters","code":"invalid_query","request_id":"req-1"}`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/v1/quiz?q="+url.QueryEscape(tt.query), nil, requestIDHeader, "req-1")
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusBadRequest || got != tt.want {
+			t.Errorf("GET /quiz?q=%.20q… = %d %s, want 400 %s", tt.query, rec.Code, got, tt.want)
+		}
+	}
+
+	if rec := serve(t, h, http.MethodGet, "/api/v1/quiz?q=cache", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /quiz?q=cache = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDistractors(t *testing.T) {
+	names := []string{
+		"Cache", "Cache line", "Cipher", "Compiler",
+		"Heap", "Hash", "Mutex", "Queue",
+		"Distributed hash table", "Write-ahead logging",
+	}
+	q := newQuizNames(names)
+	rng := rand.New(rand.NewSource(1))
+
+	for range 50 {
+		got := q.distractors(rng, "Cache")
+		if len(got) != quizOptions-1 {
+			t.Fatalf("distractors(Cache) = %q, want %d names", got, quizOptions-1)
+		}
+		for i, name := range got {
+			if name == "Cache" || slices.Contains(got[:i], name) {
+				t.Fatalf("distractors(Cache) = %q, with the answer or a name twice", got)
+			}
+			// Enough names start with C or are about as long, so the
+			// long, different ones are never needed
+			if name == "Distributed hash table" || name == "Write-ahead logging" {
+				t.Fatalf("distractors(Cache) = %q, with an implausible name", got)
+			}
+		}
+	}
+
+	// With too few plausible names, the rest fill the question
+	q = newQuizNames([]string{"Cache", "Cipher", "Distributed hash table", "Write-ahead logging"})
+	got := q.distractors(rng, "Cache")
+	slices.Sort(got)
+	if want := []string{"Cipher", "Distributed hash table", "Write-ahead logging"}; !slices.Equal(got, want) {
+		t.Errorf("distractors(Cache) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Buckets for clients that haven't been seen for this long are dropped.
+const rateLimitIdleTimeout = 3 * time.Minute
+
+// Paths that monitoring polls and which are never rate limited.
+var rateLimitExempt = map[string]bool{
+	"/api/health":    true,
+	"/api/v1/health": true,
+	"/metrics":       true,
+}
+
+// ipRateLimiter keeps a token bucket per client IP.
+type ipRateLimiter struct {
+	rps        rate.Limit
+	burst      int
+	trustProxy bool
+
+	mu      sync.Mutex
+	clients map[string]*rateClient
+}
+
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newIPRateLimiter(rps float64, burst int, trustProxy bool) *ipRateLimiter {
+	l := &ipRateLimiter{
+		rps:        rate.Limit(rps),
+		burst:      burst,
+		trustProxy: trustProxy,
+		clients:    make(map[string]*rateClient),
+	}
+	go l.evictIdle()
+	return l
+}
+
+func (l *ipRateLimiter) limiter(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &rateClient{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter
+}
+
+func (l *ipRateLimiter) evictIdle() {
+	for range time.Tick(time.Minute) {
+		l.mu.Lock()
+		for ip, c := range l.clients {
+			if time.Since(c.lastSeen) > rateLimitIdleTimeout {
+				delete(l.clients, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// clientIP identifies the caller. X-Forwarded-For is only believed when
+// the server is known to sit behind a proxy that sets it, since anyone can
+// send the header.
+func (l *ipRateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reservation := l.limiter(l.clientIP(r)).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// loaded is set once the store holds terms worth serving: a scrape has
+// supplied some, or they were loaded from a snapshot or the store. Until
+// then the server is up but not ready.
+var loaded atomic.Bool
+
+// ready reports whether the terms have been loaded.
+func ready() bool {
+	return loaded.Load()
+}
+
+// requireTerms answers requests for the terms with 503 until they have
+// been loaded, rather than with empty results that look like a real
+// answer.
+func requireTerms(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ready() {
+			w.Header().Set("Retry-After", "10")
+			writeError(w, http.StatusServiceUnavailable, codeNotReady, "the terms are still being loaded; try again shortly")
+			return
+		}
+		next(w, r)
+	}
+}
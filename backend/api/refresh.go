@@ -0,0 +1,533 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scrape_cp/internal/events"
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// Kinds of ScrapeEvent, in the order a run produces them.
+const (
+	runStarted    = "run_started"
+	sourceStarted = "source_started"
+	sourceFetched = "source_fetched"
+	sourceParsed  = "source_parsed"
+	sourceFailed  = "source_failed"
+	runFinished   = "run_finished"
+
+	// scrapeIdle is sent to new listeners when no run is in progress
+	scrapeIdle = "idle"
+)
+
+// ScrapeEvent reports progress of a scrape run.
+type ScrapeEvent struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source,omitempty"`
+	// Bytes is the size of the fetched page
+	Bytes int `json:"bytes,omitempty"`
+	// Terms is the number of terms parsed from a source, of which Added
+	// were new to the store and Updated replaced a shorter definition.
+	// Added and Updated are totalled over every source once the run has
+	// finished.
+	Terms   int `json:"terms,omitempty"`
+	Added   int `json:"added,omitempty"`
+	Updated int `json:"updated,omitempty"`
+	// Found counts the entries on the source's pages, Rejected those
+	// turned down as invalid, and Collided the terms another source had
+	// already supplied. They are totalled like Added and Updated.
+	Found    int    `json:"found,omitempty"`
+	Rejected int    `json:"rejected,omitempty"`
+	Collided int    `json:"collided,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Warning flags a source that succeeded but looks wrong, such as one
+	// whose page yielded no terms
+	Warning string `json:"warning,omitempty"`
+	// Unchanged is set when the source reported that its page hadn't
+	// changed, so its previous terms were kept
+	Unchanged bool `json:"unchanged,omitempty"`
+
+	// Sources maps each source that succeeded to its term count, Errors
+	// each one that failed to its error, and Total is the size of the
+	// store, once the run has finished
+	Sources map[string]int    `json:"sources,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+	Total   int               `json:"total,omitempty"`
+	// Details breaks the run down by source, in order of name, once it
+	// has finished
+	Details []SourceStats `json:"details,omitempty"`
+}
+
+// RefreshResponse acknowledges a refresh request.
+type RefreshResponse struct {
+	Status string `json:"status"`
+}
+
+var errScrapeRunning = errors.New("a scrape is already running")
+
+// scrapeRunner runs scrapes of every source one at a time and reports their
+// progress to listeners.
+type scrapeRunner struct {
+	mu      sync.Mutex
+	running bool
+	stopped bool
+	cancel  context.CancelFunc
+	// history holds the events of the run in progress, so listeners that
+	// join partway through can catch up
+	history []ScrapeEvent
+	// summary totals the run's source_parsed and source_failed events
+	summary ScrapeEvent
+	// started is when the latest run began
+	started time.Time
+	// last is the run_finished event of the latest run to finish, and
+	// nextRun when the periodic refresh will next start one, if it is on
+	last    *ScrapeEvent
+	nextRun time.Time
+
+	events *events.Hub[ScrapeEvent]
+}
+
+var scrapes = &scrapeRunner{events: events.NewHub[ScrapeEvent]()}
+
+// RefreshInterval is how often every source is scraped again while
+// serving; 0 only scrapes once at startup.
+var RefreshInterval time.Duration
+
+// begin marks a run as started, failing if one already is.
+func (sr *scrapeRunner) begin(ctx context.Context) (context.Context, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.stopped {
+		return nil, context.Canceled
+	}
+	if sr.running {
+		return nil, errScrapeRunning
+	}
+	sr.running = true
+	sr.started = time.Now()
+	sr.history = nil
+	sr.summary = ScrapeEvent{
+		Type:    runFinished,
+		Sources: make(map[string]int),
+		Errors:  make(map[string]string),
+	}
+	ctx, sr.cancel = context.WithCancel(ctx)
+	return ctx, nil
+}
+
+// Scrape scrapes every source into store and waits for it to finish, the
+// same way a refresh does. It returns the run_finished event, and
+// scraper.Errors if any source failed.
+func Scrape(ctx context.Context, store termstore.TermStore) (ScrapeEvent, error) {
+	return scrapes.run(ctx, store, false)
+}
+
+// ScheduleRefresh scrapes every source into store again each
+// RefreshInterval until ctx is done.
+func ScheduleRefresh(ctx context.Context, store termstore.TermStore) {
+	scrapes.schedule(ctx, store, RefreshInterval)
+}
+
+// run scrapes every source into store and waits for it to finish,
+// returning the run_finished event. It returns ctx's error if ctx was
+// cancelled along the way, and otherwise scraper.Errors if any source
+// failed; the terms from the others are still in the store. force
+// refetches sources even if they say they haven't changed.
+func (sr *scrapeRunner) run(ctx context.Context, store termstore.TermStore, force bool) (ScrapeEvent, error) {
+	runCtx, err := sr.begin(ctx)
+	if err != nil {
+		return ScrapeEvent{}, err
+	}
+	summary, failed := sr.scrape(runCtx, store, force)
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	if len(failed) > 0 {
+		return summary, failed
+	}
+	return summary, nil
+}
+
+// start runs a scrape into store in the background, saving the result and
+// what changed if anything did.
+func (sr *scrapeRunner) start(store termstore.TermStore, force bool) error {
+	ctx, err := sr.begin(context.Background())
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		before, defs := store.DataVersion(), store.Definitions()
+		summary, failed := sr.scrape(ctx, store, force)
+		if len(failed) > 0 {
+			slog.Warn("Some sources failed to refresh", "failed", len(failed), "sources", len(scraper.Sources), "err", failed)
+		}
+		diff := diffTerms(defs, store.Definitions())
+		setLastDiff(diff)
+		slog.Info("Refresh finished", "added", len(diff.Added), "removed", len(diff.Removed), "changed", len(diff.Changed))
+
+		var filename string
+		if store.DataVersion() != before {
+			timestamp := time.Now().Format(TimestampLayout)
+			if files, err := SaveOutput(timestamp, store); err != nil {
+				slog.Error("Failed to save refreshed terms", "err", err)
+			} else {
+				filename = files[0]
+				slog.Info("Saved refreshed terms", "files", strings.Join(files, ", "))
+			}
+			if !diff.empty() {
+				if err := writeJSONFile(filepath.Join(OutputDir, fmt.Sprintf("diff_%s.json", timestamp)), diff); err != nil {
+					slog.Error("Failed to save refresh diff", "err", err)
+				}
+			}
+		}
+		webhooks.notify(summary, filename)
+	}()
+	return nil
+}
+
+// scrape runs every source through the scraper, merging what each one
+// supplies into store as soon as it is done, and returns the summary of
+// the run and the sources that failed. The run is traced as one span.
+func (sr *scrapeRunner) scrape(ctx context.Context, store termstore.TermStore, force bool) (ScrapeEvent, scraper.Errors) {
+	ctx, span := startRefreshSpan(ctx, force)
+	sr.emit(ScrapeEvent{Type: runStarted})
+
+	s := scraper.Scraper{
+		Force:    force,
+		Previous: store.ScrapedFrom,
+		Known: func(term string) (string, bool) {
+			t, ok := store.Get(term)
+			return t.Definition, ok
+		},
+		Started: func(src scraper.Source) {
+			sr.emit(ScrapeEvent{Type: sourceStarted, Source: src.Name})
+		},
+		Fetched: func(src scraper.Source, bytes int) {
+			sr.emit(ScrapeEvent{Type: sourceFetched, Source: src.Name, Bytes: bytes})
+		},
+		Done: func(ctx context.Context, src scraper.Source, result scraper.Result, err error) {
+			sr.sourceDone(ctx, store, src, result, err)
+		},
+	}
+	_, failed := s.ScrapeAll(ctx, scraper.Sources)
+	if err := store.Link(); err != nil {
+		slog.Error("Failed to save linked terms", "err", err)
+	}
+
+	sr.mu.Lock()
+	summary := sr.summary
+	sr.mu.Unlock()
+	summary.Total = store.Len()
+	summary.Time = time.Now()
+	slices.SortFunc(summary.Details, func(a, b SourceStats) int { return strings.Compare(a.Name, b.Name) })
+	logSummary(summary)
+	if len(summary.Sources) > 0 {
+		stats.setOrigin(originScraped)
+		store.MarkScraped(summary.Time)
+	}
+	if summary.Total > 0 {
+		loaded.Store(true)
+	}
+	sr.emit(summary)
+	endRefreshSpan(span, summary, failed)
+
+	sr.mu.Lock()
+	sr.running = false
+	sr.last = &summary
+	sr.cancel()
+	sr.mu.Unlock()
+	return summary, failed
+}
+
+// sourceDone merges what src supplied into store, reporting the
+// outcome to listeners, stats and metrics. Sources the run never got to
+// are only reported to listeners. ctx carries the source's span.
+func (sr *scrapeRunner) sourceDone(ctx context.Context, store termstore.TermStore, src scraper.Source, result scraper.Result, err error) {
+	if result.Skipped {
+		sr.emit(ScrapeEvent{Type: sourceFailed, Source: src.Name, Error: err.Error()})
+		return
+	}
+
+	outcome := SourceStats{
+		Name:         src.Name,
+		URL:          src.URL,
+		Outcome:      outcomeOK,
+		Attempts:     result.Attempts,
+		Pages:        result.Pages,
+		Found:        result.Found,
+		Rejected:     result.Rejected,
+		Duration:     result.Duration.Seconds(),
+		FetchSeconds: result.Fetching.Seconds(),
+		ParseSeconds: result.Parsing.Seconds(),
+		Finished:     time.Now(),
+	}
+	defer func() { sr.record(outcome) }()
+
+	if err != nil {
+		scrapeFailures.WithLabelValues(src.Name, scraper.FailureReason(err)).Inc()
+		sr.emit(ScrapeEvent{Type: sourceFailed, Source: src.Name, Error: err.Error()})
+		outcome.Outcome = outcomeFailed
+		if errors.Is(err, scraper.ErrBlockedByRobots) {
+			outcome.Outcome = outcomeBlocked
+		}
+		outcome.Error = err.Error()
+		return
+	}
+
+	start := time.Now()
+	counts, err := traceMerge(ctx, store, src, result.Terms)
+	outcome.MergeSeconds = time.Since(start).Seconds()
+	if err != nil {
+		// The terms are served all the same; only the backend is behind
+		slog.Error("Failed to save scraped terms", "source", src.Name, "err", err)
+		outcome.Error = err.Error()
+	}
+	outcome.Terms, outcome.Added, outcome.Updated, outcome.Collided = len(result.Terms), counts.Added, counts.Updated, counts.Collided
+	slog.Info("Scraped source", "source", src.Name, "terms", len(result.Terms), "found", result.Found,
+		"rejected", result.Rejected, "added", counts.Added, "updated", counts.Updated, "collided", counts.Collided,
+		"unchanged", result.Unchanged, "duration", result.Duration.Round(time.Millisecond))
+	parsed := ScrapeEvent{Type: sourceParsed, Source: src.Name, Terms: len(result.Terms),
+		Added: counts.Added, Updated: counts.Updated, Found: result.Found, Rejected: result.Rejected,
+		Collided: counts.Collided, Unchanged: result.Unchanged}
+	if result.Unchanged {
+		sr.emit(parsed)
+		outcome.Outcome = outcomeUnchanged
+		return
+	}
+	if len(result.Terms) == 0 {
+		// The page came back fine, so the selectors no longer match it
+		slog.Error("Source yielded no terms; its page layout has probably changed", "source", src.Name,
+			"url", src.URL, "found", result.Found, "rejected", result.Rejected)
+		scrapeFailures.WithLabelValues(src.Name, outcomeEmpty).Inc()
+		parsed.Warning = "no terms found; the page layout has probably changed"
+		outcome.Outcome = outcomeEmpty
+	}
+	sr.emit(parsed)
+	scrapedTerms.WithLabelValues(src.Name).Add(float64(len(result.Terms)))
+	scrapeDuration.WithLabelValues(src.Name).Observe(result.Duration.Seconds())
+}
+
+// schedule starts a refresh of store every interval until ctx is done,
+// the same way /refresh does. A refresh still going when the next is due is left
+// to finish and that one skipped.
+func (sr *scrapeRunner) schedule(ctx context.Context, store termstore.TermStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sr.setNextRun(time.Now().Add(interval))
+	for {
+		select {
+		case <-ticker.C:
+			sr.setNextRun(time.Now().Add(interval))
+			switch err := sr.start(store, false); {
+			case errors.Is(err, errScrapeRunning):
+				slog.Warn("Skipping scheduled refresh, the previous one is still running")
+			case err != nil:
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (sr *scrapeRunner) setNextRun(t time.Time) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.nextRun = t
+}
+
+// emit records an event and passes it on to listeners.
+func (sr *scrapeRunner) emit(event ScrapeEvent) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	event.Time = time.Now()
+	switch event.Type {
+	case sourceParsed:
+		sr.summary.Sources[event.Source] = event.Terms
+		sr.summary.Added += event.Added
+		sr.summary.Updated += event.Updated
+		sr.summary.Found += event.Found
+		sr.summary.Rejected += event.Rejected
+		sr.summary.Collided += event.Collided
+	case sourceFailed:
+		sr.summary.Errors[event.Source] = event.Error
+	}
+	sr.history = append(sr.history, event)
+	// Publishing under the lock keeps listeners from seeing an event both
+	// in the history and live
+	sr.events.Publish(event)
+}
+
+// record keeps the outcome of a source's scrape, for /stats and the
+// details of the run in progress.
+func (sr *scrapeRunner) record(outcome SourceStats) {
+	stats.record(outcome)
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.summary.Details = append(sr.summary.Details, outcome)
+}
+
+// logSummary logs what a finished run did, in total and for each source,
+// as one structured line.
+func logSummary(summary ScrapeEvent) {
+	args := []any{"terms", summary.Total, "found", summary.Found, "rejected", summary.Rejected,
+		"added", summary.Added, "updated", summary.Updated, "collided", summary.Collided,
+		"failed", len(summary.Errors)}
+	for _, s := range summary.Details {
+		args = append(args, slog.Group(s.Name, "outcome", s.Outcome, "found", s.Found, "rejected", s.Rejected,
+			"terms", s.Terms, "added", s.Added, "updated", s.Updated, "collided", s.Collided,
+			"fetch", seconds(s.FetchSeconds), "parse", seconds(s.ParseSeconds), "merge", seconds(s.MergeSeconds)))
+	}
+	slog.Info("Scrape summary", args...)
+}
+
+// seconds turns a duration reported in seconds back into one for logging.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+// lastStarted returns when the latest run began, or the zero time if there
+// hasn't been one.
+func (sr *scrapeRunner) lastStarted() time.Time {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	return sr.started
+}
+
+// refreshStats reports when the periodic refresh runs next and how the
+// latest run went.
+func (sr *scrapeRunner) refreshStats() RefreshStats {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	rs := RefreshStats{LastRun: sr.last}
+	if RefreshInterval > 0 {
+		rs.Interval = RefreshInterval.String()
+		next := sr.nextRun
+		rs.NextRun = &next
+	}
+	return rs
+}
+
+// listen subscribes to events, returning what the run in progress has
+// reported so far, or nil when none is running.
+func (sr *scrapeRunner) listen() (*events.Subscriber[ScrapeEvent], []ScrapeEvent) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sub := sr.events.Subscribe()
+	if !sr.running {
+		return sub, nil
+	}
+	return sub, append([]ScrapeEvent{}, sr.history...)
+}
+
+// stop cancels the run in progress, refuses new ones and ends every event
+// stream, for shutdown.
+func (sr *scrapeRunner) stop() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.stopped = true
+	if sr.running {
+		sr.cancel()
+	}
+	sr.events.Close()
+}
+
+// refreshTerms starts scraping every source again in the background.
+// Progress can be followed on /refresh/events. Sources that report no
+// changes keep their terms unless ?force=true is given.
+func refreshTerms(w http.ResponseWriter, r *http.Request) {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	switch err := scrapes.start(storeOf(r.Context()), force); {
+	case errors.Is(err, errScrapeRunning):
+		writeError(w, http.StatusConflict, codeRefreshInProgress, "refresh already running")
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, codeShuttingDown, "server is shutting down")
+	default:
+		writeJSON(w, http.StatusAccepted, RefreshResponse{Status: "started"})
+	}
+}
+
+// sseKeepAlive is how often a comment is sent on an otherwise quiet event
+// stream so proxies don't time it out.
+const sseKeepAlive = 30 * time.Second
+
+// streamRefreshEvents sends scrape progress as Server-Sent Events. A client
+// that connects mid-run first gets the events it missed; one that connects
+// between runs gets an idle event and then waits for the next run.
+func streamRefreshEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternal, "streaming not supported")
+		return
+	}
+
+	sub, missed := scrapes.listen()
+	defer scrapes.events.Unsubscribe(sub)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if missed == nil {
+		missed = []ScrapeEvent{{Type: scrapeIdle, Time: time.Now()}}
+	}
+	for _, event := range missed {
+		writeSSE(w, event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeSSE(w, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSE writes one event in text/event-stream framing, named after its
+// type.
+func writeSSE(w http.ResponseWriter, event ScrapeEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode scrape event", "err", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRefreshNeedsTheWriteKey(t *testing.T) {
+	saved := WriteAPIKey
+	t.Cleanup(func() { WriteAPIKey = saved })
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+
+	tests := []struct {
+		key     string
+		headers []string
+		status  int
+		want    string
+	}{
+		{"secret", nil, http.StatusUnauthorized,
+			`{"error":"a valid API key is required","code":"unauthorized","request_id":"req-1"}`},
+		{"secret", []string{"Authorization", "Bearer guess"}, http.StatusUnauthorized,
+			`{"error":"a valid API key is required","code":"unauthorized","request_id":"req-1"}`},
+		{"", []string{"Authorization", "Bearer secret"}, http.StatusForbidden,
+			`{"error":"writes are disabled until the server is started with --write-api-key","code":"writes_disabled","request_id":"req-1"}`},
+	}
+	for _, tt := range tests {
+		WriteAPIKey = tt.key
+		for _, path := range []string{"/api/refresh", "/api/v1/refresh"} {
+			rec := serve(t, h, http.MethodPost, path, nil, append(tt.headers, requestIDHeader, "req-1")...)
+			if got := strings.TrimSpace(rec.Body.String()); rec.Code != tt.status || got != tt.want {
+				t.Errorf("POST %s with %q = %d %s, want %d %s", path, tt.headers, rec.Code, got, tt.status, tt.want)
+			}
+		}
+	}
+	scrapes.mu.Lock()
+	defer scrapes.mu.Unlock()
+	if scrapes.running {
+		t.Error("a refresh was started without the write key")
+	}
+}
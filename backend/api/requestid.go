@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader carries a request's ID in both directions: a client or
+// proxy may choose it, and the response always says what it was.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what a client-supplied ID has to look like to be
+// used as it is, so it can't smuggle anything odd into the logs. UUIDs and
+// the IDs common proxies generate fit.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// withRequestID gives each request an ID, the client's if it sent a valid
+// one, which goes back in the X-Request-ID header, into error bodies and
+// into every log line written while handling it.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestID returns the ID of the request ctx belongs to, or "" outside
+// of one.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logger returns the logger for work done on behalf of the request ctx
+// belongs to, which tags each line with its ID.
+func logger(ctx context.Context) *slog.Logger {
+	if id := requestID(ctx); id != "" {
+		return slog.With("request_id", id)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot retention, set with --keep-snapshots and
+// --delete-snapshots-after. Zero turns either limit off.
+var (
+	KeepSnapshots        = 10
+	DeleteSnapshotsAfter time.Duration
+)
+
+// RetentionStats describes the retention policy and how many snapshots
+// are on disk.
+type RetentionStats struct {
+	Keep        int    `json:"keep"`
+	DeleteAfter string `json:"delete_after,omitempty"`
+	Snapshots   int    `json:"snapshots"`
+}
+
+// snapshotFiles finds the snapshots in dir, oldest first, without reading
+// them. Only files named cs_terms_<timestamp>.json count, so _legacy
+// copies and cs_terms_latest.json are left out.
+func snapshotFiles(dir string) ([]Snapshot, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "cs_terms_*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Snapshot
+	for _, file := range files {
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "cs_terms_"), ".json")
+		t, err := time.ParseInLocation(TimestampLayout, timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+		found = append(found, Snapshot{Timestamp: timestamp, Time: t, File: file})
+	}
+	slices.SortFunc(found, func(a, b Snapshot) int { return a.Time.Compare(b.Time) })
+	return found, nil
+}
+
+// rotateSnapshots deletes the snapshots in dir beyond the newest
+// KeepSnapshots, and those older than DeleteSnapshotsAfter, along with
+// their _legacy copies. A file that can't be deleted is logged and left
+// for the next rotation to try again.
+func rotateSnapshots(dir string) {
+	found, err := snapshotFiles(dir)
+	if err != nil {
+		slog.Error("Failed to list snapshots to rotate", "err", err)
+		return
+	}
+
+	for i, s := range found {
+		tooMany := KeepSnapshots > 0 && i < len(found)-KeepSnapshots
+		tooOld := DeleteSnapshotsAfter > 0 && time.Since(s.Time) > DeleteSnapshotsAfter
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(s.File); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Failed to delete old snapshot", "err", err)
+			continue
+		}
+		snapshots.forget(s.Timestamp)
+		legacy := filepath.Join(dir, "cs_terms_"+s.Timestamp+"_legacy.json")
+		if err := os.Remove(legacy); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Failed to delete old snapshot", "err", err)
+		}
+		slog.Info("Deleted old snapshot", "file", s.File)
+	}
+}
+
+// retentionStats reports the policy and the snapshots left in dir.
+func retentionStats(dir string) RetentionStats {
+	rs := RetentionStats{Keep: KeepSnapshots}
+	if DeleteSnapshotsAfter > 0 {
+		rs.DeleteAfter = DeleteSnapshotsAfter.String()
+	}
+	if found, err := snapshotFiles(dir); err == nil {
+		rs.Snapshots = len(found)
+	}
+	return rs
+}
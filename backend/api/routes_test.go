@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	termstore "scrape_cp/store"
+)
+
+func TestTermPaths(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Big O notation":  "A way of describing how an algorithm's cost grows.",
+		"TCP/IP":          "The suite of protocols the internet runs on.",
+		"Müller's method": "A root-finding algorithm using quadratic interpolation.",
+		"C++":             "A general-purpose programming language.",
+		"C":               "A general-purpose programming language from Bell Labs.",
+	}))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/terms/Big%20O%20notation", "Big O notation"},
+		{"/api/terms/Big%20O%20notation", "Big O notation"},
+		{"/api/v1/terms/big%20o%20NOTATION", "Big O notation"},
+		{"/api/v1/terms/TCP%2FIP", "TCP/IP"},
+		{"/api/terms/TCP%2fIP", "TCP/IP"},
+		// Precomposed, decomposed and raw forms of the ü
+		{"/api/v1/terms/M%C3%BCller%27s%20method", "Müller's method"},
+		{"/api/v1/terms/Mu%CC%88ller's%20method", "Müller's method"},
+		{"/api/v1/terms/Müller's%20method", "Müller's method"},
+		// A plus is part of the name, not an encoded space
+		{"/api/v1/terms/C++", "C++"},
+		{"/api/v1/terms/C%2B%2B", "C++"},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, tt.path, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d: %s", tt.path, rec.Code, rec.Body)
+			continue
+		}
+		var got termstore.TermResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		if got.Term != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.path, got.Term, tt.want)
+		}
+	}
+
+	for _, path := range []string{"/api/v1/terms/TCP%2FIP/related", "/api/v1/terms/Big%20O%20notation/related"} {
+		if rec := serve(t, h, http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d: %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	// An unencoded slash is a different path altogether
+	rec := serve(t, h, http.MethodGet, "/api/v1/terms/TCP/IP", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/v1/terms/TCP/IP = %d, want 404", rec.Code)
+	}
+}
+
+// routePath fills in a route's path parameters.
+func routePath(path string) string {
+	return strings.NewReplacer("{term}", "Cache", "{letter}", "c").Replace(path)
+}
+
+func TestWrongMethods(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+
+	// Every path with the methods it takes. A fixed path may also match a
+	// pattern, as /terms/lookup does /terms/{term}, and takes its methods too
+	type pattern struct {
+		re     *regexp.Regexp
+		method string
+	}
+	var paths []string
+	var patterns []pattern
+	add := func(prefix string, routes []route) {
+		for _, rt := range routes {
+			if path := prefix + routePath(rt.path); !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+			segments := strings.Split(prefix+rt.path, "/")
+			for i, seg := range segments {
+				if strings.HasPrefix(seg, "{") {
+					segments[i] = "[^/]+"
+				} else {
+					segments[i] = regexp.QuoteMeta(seg)
+				}
+			}
+			re := regexp.MustCompile("^" + strings.Join(segments, "/") + "$")
+			patterns = append(patterns, pattern{re, rt.method})
+		}
+	}
+	add("", rootRoutes)
+	for _, version := range apiMounts {
+		add(version.prefix, apiRoutes)
+	}
+	allowed := make(map[string][]string)
+	for _, path := range paths {
+		for _, p := range patterns {
+			if p.re.MatchString(path) && !slices.Contains(allowed[path], p.method) {
+				allowed[path] = append(allowed[path], p.method)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		methods := slices.Sorted(slices.Values(allowed[path]))
+		wantAllow := strings.Join(append(methods, http.MethodOptions), ", ")
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			rec := serve(t, h, method, path, nil, requestIDHeader, "req-1")
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s = %d, want 405", method, path, rec.Code)
+				continue
+			}
+			if got := rec.Header().Get("Allow"); got != wantAllow {
+				t.Errorf("%s %s Allow = %q, want %q", method, path, got, wantAllow)
+			}
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			want := fmt.Sprintf("method %s is not allowed here; allowed: %s", method, wantAllow)
+			if body.Code != codeMethodNotAllowed || body.Error != want || body.RequestID != "req-1" {
+				t.Errorf("%s %s = %+v, want %q", method, path, body, want)
+			}
+		}
+	}
+	if len(paths) < 30 {
+		t.Errorf("only %d paths were tried", len(paths))
+	}
+}
+
+func TestOptions(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+	for _, path := range []string{"/api/terms", "/api/v1/terms"} {
+		rec := serve(t, h, http.MethodOptions, path, nil)
+		if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("OPTIONS %s = %d %q, want 204 with no body", path, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Allow"); got != "GET, OPTIONS" {
+			t.Errorf("OPTIONS %s Allow = %q, want GET, OPTIONS", path, got)
+		}
+	}
+	rec := serve(t, h, http.MethodOptions, "/api/v1/terms/Cache", nil)
+	if got := rec.Header().Get("Allow"); rec.Code != http.StatusNoContent || got != "GET, OPTIONS" {
+		t.Errorf("OPTIONS /api/v1/terms/Cache = %d with Allow %q", rec.Code, got)
+	}
+}
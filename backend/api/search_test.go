@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchQueryWords(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Hash table":   "A structure mapping keys to values through a hash function; a collision puts two keys in one bucket.",
+		"Trie":         "A search tree over the characters of keys, unlike a binary search tree.",
+		"Bloom filter": "A set that may report false positives, built from several hash functions.",
+	}))
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"q=hash+collision", []string{"Hash table"}},
+		{"q=collision+positives", nil},
+		{"q=collision+positives&op=or", []string{"Bloom filter", "Hash table"}},
+		{"q=" + url.QueryEscape(`"binary search tree"`), []string{"Trie"}},
+		{"q=" + url.QueryEscape(`"hash function" collision`), []string{"Hash table"}},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/v1/terms/search?"+tt.query, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("search %s = %d: %s", tt.query, rec.Code, rec.Body)
+			continue
+		}
+		var got SearchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		var gotNames []string
+		for _, term := range got.Terms {
+			gotNames = append(gotNames, term.Term)
+		}
+		if !slices.Equal(gotNames, tt.want) {
+			t.Errorf("search %s = %q, want %q", tt.query, gotNames, tt.want)
+		}
+	}
+}
+
+func TestSearchWithoutWords(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+	for _, q := range []string{`""`, `" "`, "!!! ???", `"", ;`} {
+		target := "/api/v1/terms/search?q=" + url.QueryEscape(q)
+		rec := serve(t, h, http.MethodGet, target, nil, requestIDHeader, "req-1")
+		want := `{"error":"search query has no words to match","code":"invalid_query","request_id":"req-1"}`
+		if rec.Code != http.StatusBadRequest || strings.TrimSpace(rec.Body.String()) != want {
+			t.Errorf("search %q = %d %s, want 400 %s", q, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestQueryRejections(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+	long := strings.Repeat("é", maxQueryLength+1)
+	longFuzzy := strings.Repeat("a", maxFuzzyQueryLength+1)
+
+	tests := []struct {
+		name    string
+		target  string
+		code    string
+		message string
+	}{
+		{"search without a query", "/api/v1/terms/search", codeInvalidQuery, "search query is required"},
+		{"search of spaces", "/api/v1/terms/search?q=" + url.QueryEscape(" \t "), codeInvalidQuery, "search query is required"},
+		{"long search", "/api/v1/terms/search?q=" + url.QueryEscape(long), codeInvalidQuery, "search query must be at most 200 characters"},
+		{"search with a control character", "/api/v1/terms/search?q=" + url.QueryEscape("cache\x00line"), codeInvalidQuery, "search query must not contain control characters"},
+		{"search with a tab inside", "/api/v1/terms/search?q=" + url.QueryEscape("cache\tline"), codeInvalidQuery, "search query must not contain control characters"},
+		{"search without words", "/api/v1/terms/search?q=" + url.QueryEscape("?!"), codeInvalidQuery, "search query has no words to match"},
+		{"search with a bad op", "/api/v1/terms/search?q=cache&op=xor", codeInvalidParameter, "op must be and or or"},
+		{"fuzzy search without a query", "/api/v1/terms/search?fuzzy=true", codeInvalidQuery, "fuzzy search query is required"},
+		{"long fuzzy search", "/api/v1/terms/search?fuzzy=true&q=" + longFuzzy, codeInvalidQuery, "fuzzy search query must be at most 50 characters"},
+		{"fuzzy search with a control character", "/api/v1/terms/search?fuzzy=true&q=" + url.QueryEscape("ca\x7fche"), codeInvalidQuery, "fuzzy search query must not contain control characters"},
+		{"fuzzy search with a bad distance", "/api/v1/terms/search?fuzzy=true&q=cache&distance=9", codeInvalidParameter, "distance must be an integer between 0 and 4"},
+		{"suggest without a prefix", "/api/v1/terms/suggest", codeInvalidParameter, "prefix is required"},
+		{"long suggest", "/api/v1/terms/suggest?q=" + url.QueryEscape(long), codeInvalidParameter, "prefix must be at most 200 characters"},
+		{"suggest with a control character", "/api/v1/terms/suggest?q=" + url.QueryEscape("ca\nche"), codeInvalidParameter, "prefix must not contain control characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h, http.MethodGet, tt.target, nil, requestIDHeader, "req-1")
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			var got ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := ErrorResponse{Error: tt.message, Code: tt.code, RequestID: "req-1"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("body = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// The longest queries allowed, counted in characters rather than bytes
+	for _, target := range []string{
+		"/api/v1/terms/search?q=" + url.QueryEscape(long[len("é"):]),
+		"/api/v1/terms/search?fuzzy=true&q=" + longFuzzy[1:],
+		"/api/v1/terms/suggest?q=" + url.QueryEscape(long[len("é"):]),
+	} {
+		if rec := serve(t, h, http.MethodGet, target, nil); rec.Code != http.StatusOK {
+			t.Errorf("GET %.60s... = %d: %s", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestSearchStopsForGoneClients(t *testing.T) {
+	h := newTestServer(t, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))
+	for _, target := range []string{"/api/v1/terms/search?q=cache", "/api/v1/terms/search?q=cahce&fuzzy=true"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Body.Len() != 0 {
+			t.Errorf("GET %s for a gone client wrote %s", target, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/cors"
+
+	termstore "scrape_cp/store"
+)
+
+// ServerConfig holds the settings for the API server that come from the
+// command line.
+type ServerConfig struct {
+	// Addr is the address to listen on; port 0 picks a free port
+	Addr string
+
+	// CORSOrigins lists the origins browsers may call the API from; "*"
+	// allows any. CORS headers are only sent when it isn't empty.
+	CORSOrigins []string
+
+	// RateLimit is the sustained number of requests per second allowed
+	// from one client IP, with bursts up to RateBurst. Zero disables it.
+	RateLimit float64
+	RateBurst int
+	// TrustProxy takes client IPs from X-Forwarded-For
+	TrustProxy bool
+
+	// TLSCert and TLSKey switch the server to HTTPS; TLSSelfSigned does
+	// the same with a generated certificate instead
+	TLSCert       string
+	TLSKey        string
+	TLSSelfSigned bool
+	// RedirectAddr, when set alongside TLS, serves redirects from plain
+	// HTTP to HTTPS
+	RedirectAddr string
+
+	// Trace traces each request, for when a tracer provider is set up
+	Trace bool
+
+	// The http.Server timeouts of the same names; zero leaves one
+	// unbounded. Streaming routes lift WriteTimeout for themselves.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// logRequests logs each request once it has been answered, with its
+// status, the size of the response, and who asked.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+		logger(r.Context()).Info("Request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"bytes", rec.bytes, "duration", time.Since(start), "remote", r.RemoteAddr, "user_agent", r.UserAgent())
+	})
+}
+
+// StartServer binds cfg.Addr and serves the API from store in the
+// background. The caller stops it with StopServer; fail is called if it
+// stops serving any other way.
+func StartServer(cfg ServerConfig, store termstore.TermStore, fail func(error)) (*http.Server, error) {
+	if err := prometheus.Register(newTermsGauge(store)); err != nil {
+		return nil, fmt.Errorf("registering metrics: %w", err)
+	}
+	handler := newHandler(cfg, store)
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", cfg.Addr, err)
+	}
+
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown doesn't wait for hijacked connections, so end the streams
+	// explicitly
+	server.RegisterOnShutdown(store.Events().Close)
+	server.RegisterOnShutdown(scrapes.stop)
+	scheme := "http"
+	serve := server.Serve
+	if tlsConfig != nil {
+		// The certificates are already in TLSConfig
+		scheme = "https"
+		serve = func(l net.Listener) error { return server.ServeTLS(l, "", "") }
+	}
+	go func() {
+		if err := serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			fail(fmt.Errorf("API server on %s failed: %w", server.Addr, err))
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(server.Addr)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	slog.Info("API server is running", "url", scheme+"://"+net.JoinHostPort(host, port))
+
+	if cfg.RedirectAddr != "" {
+		redirectListener, err := net.Listen("tcp", cfg.RedirectAddr)
+		if err != nil {
+			server.Close()
+			return nil, fmt.Errorf("listening on %s: %w", cfg.RedirectAddr, err)
+		}
+		redirect := &http.Server{
+			Addr:              redirectListener.Addr().String(),
+			Handler:           redirectToHTTPS(port),
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+		server.RegisterOnShutdown(func() { redirect.Close() })
+		go func() {
+			if err := redirect.Serve(redirectListener); !errors.Is(err, http.ErrServerClosed) {
+				fail(fmt.Errorf("HTTPS redirect server on %s failed: %w", redirect.Addr, err))
+			}
+		}()
+		slog.Info("Redirecting plain HTTP to HTTPS", "addr", redirect.Addr)
+	}
+	return server, nil
+}
+
+// newHandler returns the API serving store, wrapped in the middleware cfg
+// asks for, just as StartServer serves it.
+func newHandler(cfg ServerConfig, store termstore.TermStore) http.Handler {
+	router := NewRouter(store)
+	if cfg.Trace {
+		router.Use(traceMiddleware)
+	}
+
+	router.Use(metricsMiddleware)
+	if cfg.RateLimit > 0 {
+		router.Use(newIPRateLimiter(cfg.RateLimit, cfg.RateBurst, cfg.TrustProxy).middleware)
+	}
+	router.Use(gzipMiddleware)
+
+	// CORS wraps the router instead of being router middleware so that
+	// preflight requests are answered before mux rejects the OPTIONS
+	// method. Panics are recovered outside all of it, since mux doesn't run
+	// its middleware for requests no route matches, and requests are logged
+	// outside that, so that those matching no route and those that panicked
+	// are logged too.
+	var handler http.Handler = router
+	if len(cfg.CORSOrigins) > 0 {
+		handler = cors.New(cors.Options{
+			AllowedOrigins: cfg.CORSOrigins,
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-None-Match", requestIDHeader},
+			ExposedHeaders: []string{"ETag", "X-Total-Count", requestIDHeader},
+		}).Handler(handler)
+	}
+	return withRequestID(logRequests(recoverMiddleware(handler)))
+}
+
+// StopServer stops accepting connections and waits up to grace for
+// in-flight requests to finish.
+func StopServer(server *http.Server, grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down API server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureLogs sends slog's default logger to a buffer of JSON lines for
+// the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(saved) })
+	return &buf
+}
+
+func TestRequestLogging(t *testing.T) {
+	markLoaded(t)
+	h := withRequestID(logRequests(NewRouter(newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	}))))
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/api/v1/terms/Cache", http.StatusOK},
+		{"/api/v1/terms/Nonexistent", http.StatusNotFound},
+		{"/api/v1/no/such/endpoint", http.StatusNotFound},
+		{"/api/v1/terms?sort=bogus", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			logs := captureLogs(t)
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			req.Header.Set("User-Agent", "glossary-client/1.2")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			var line struct {
+				Msg       string  `json:"msg"`
+				Method    string  `json:"method"`
+				Path      string  `json:"path"`
+				Status    int     `json:"status"`
+				Bytes     int     `json:"bytes"`
+				Duration  float64 `json:"duration"`
+				Remote    string  `json:"remote"`
+				UserAgent string  `json:"user_agent"`
+				RequestID string  `json:"request_id"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatalf("want one JSON log line, got %q: %v", logs, err)
+			}
+			if line.Msg != "Request" || line.Method != http.MethodGet || line.Path != req.URL.Path {
+				t.Errorf("logged %s %s %s", line.Msg, line.Method, line.Path)
+			}
+			if line.Status != tt.status || rec.Code != tt.status {
+				t.Errorf("logged status %d, answered %d, want %d", line.Status, rec.Code, tt.status)
+			}
+			if line.Bytes != rec.Body.Len() || line.Bytes == 0 {
+				t.Errorf("logged %d bytes, sent %d", line.Bytes, rec.Body.Len())
+			}
+			if line.Remote != req.RemoteAddr {
+				t.Errorf("logged remote %q, want %q", line.Remote, req.RemoteAddr)
+			}
+			if line.UserAgent != "glossary-client/1.2" {
+				t.Errorf("logged user agent %q", line.UserAgent)
+			}
+			if line.RequestID == "" || line.RequestID != rec.Header().Get(requestIDHeader) {
+				t.Errorf("logged request ID %q, answered with %q", line.RequestID, rec.Header().Get(requestIDHeader))
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"scrape_cp/internal/atomicfile"
+	termstore "scrape_cp/store"
+)
+
+// siteFiles holds the templates of the static site and the files copied
+// into it as they are.
+//
+//go:embed site
+var siteFiles embed.FS
+
+var siteTemplates = template.Must(template.ParseFS(siteFiles, "site/*.html"))
+
+const (
+	siteTitle = "Computer Science Terms"
+	// siteTermDir is the directory of the site the term pages go in
+	siteTermDir = "terms"
+	// maxSlugRunes keeps term page file names well inside what file
+	// systems allow
+	maxSlugRunes = 80
+)
+
+// SiteOptions choose what GenerateSite writes.
+type SiteOptions struct {
+	// TermPages adds a page per term, which the other pages then link to
+	// instead of the term's entry on its letter's page
+	TermPages bool
+}
+
+// sitePage is what every page template is given.
+type sitePage struct {
+	Title string
+	// Root is the path from the page back to the top of the site
+	Root   string
+	Nav    []siteLetter
+	Letter string
+	Total  int
+
+	Entries []siteEntry
+	Term    *siteTerm
+}
+
+// siteLetter is a letter of the navigation. File is empty for letters no
+// term starts with.
+type siteLetter struct {
+	Letter string
+	File   string
+	Count  int
+}
+
+// siteEntry is a term listed on its letter's page. Href is its own page,
+// if terms have them.
+type siteEntry struct {
+	Name       string
+	ID         string
+	Href       string
+	Definition template.HTML
+}
+
+// siteTerm is the page of one term.
+type siteTerm struct {
+	Name       string
+	Aliases    []string
+	Definition template.HTML
+	Senses     []termstore.Sense
+	Source     string
+	SourceURL  string
+	Links      []siteLink
+	LetterFile string
+}
+
+type siteLink struct {
+	Name string
+	Href string
+}
+
+// GenerateSite renders every term in store as a static HTML site in dir:
+// an index page navigating to one page per letter, which lists that
+// letter's terms with their definitions, and with opts.TermPages a page
+// per term. Definitions link to the terms they mention. It returns how
+// many pages were written. Pages that an earlier run wrote for terms that
+// have since gone are left alone, so publish a fresh directory to drop
+// them.
+func GenerateSite(dir string, store termstore.TermStore, opts SiteOptions) (int, error) {
+	// The links of terms loaded from a snapshot may predate terms added
+	// since
+	if err := store.Link(); err != nil {
+		return 0, err
+	}
+	terms := store.Snapshot()
+
+	slugs := termSlugs(terms)
+	groups := make(map[string][]termstore.Term)
+	for _, t := range terms {
+		letter := termstore.LetterOf(t.Name)
+		groups[letter] = append(groups[letter], t)
+	}
+	nav := siteNav(groups)
+
+	// href is where a page root levels below the top of the site finds
+	// a term
+	href := func(root, name string) string {
+		if opts.TermPages {
+			return root + siteTermDir + "/" + url.PathEscape(slugs[name]) + ".html"
+		}
+		return root + url.PathEscape(letterFile(termstore.LetterOf(name))) + "#" + url.PathEscape(slugs[name])
+	}
+
+	page := func(root string) sitePage {
+		return sitePage{Title: siteTitle, Root: root, Nav: nav, Total: len(terms)}
+	}
+	pages := 0
+	write := func(name, tmpl string, data sitePage) error {
+		pages++
+		return atomicfile.Write(filepath.Join(dir, name), func(w io.Writer) error {
+			return siteTemplates.ExecuteTemplate(w, tmpl, data)
+		})
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	if err := copySiteAssets(dir); err != nil {
+		return 0, err
+	}
+	if err := write("index.html", "index.html", page("")); err != nil {
+		return pages, err
+	}
+
+	for _, letter := range nav {
+		if letter.File == "" {
+			continue
+		}
+		data := page("")
+		data.Letter = letter.Letter
+		for _, t := range groups[letter.Letter] {
+			definition, _ := store.LinkifiedFunc(t.Name, func(name string) string { return href("", name) })
+			entry := siteEntry{Name: t.Name, ID: slugs[t.Name], Definition: template.HTML(definition)}
+			if opts.TermPages {
+				entry.Href = href("", t.Name)
+			}
+			data.Entries = append(data.Entries, entry)
+		}
+		if err := write(letter.File, "letter.html", data); err != nil {
+			return pages, err
+		}
+	}
+
+	if !opts.TermPages {
+		return pages, nil
+	}
+	if err := os.MkdirAll(filepath.Join(dir, siteTermDir), 0o755); err != nil {
+		return pages, err
+	}
+	for _, t := range terms {
+		data := page("../")
+		data.Letter = termstore.LetterOf(t.Name)
+		definition, _ := store.LinkifiedFunc(t.Name, func(name string) string { return href("../", name) })
+		term := &siteTerm{
+			Name:       t.Name,
+			Aliases:    t.Aliases,
+			Definition: template.HTML(definition),
+			Source:     t.Source,
+			SourceURL:  t.SourceURL,
+			LetterFile: letterFile(data.Letter),
+		}
+		for _, sense := range t.Definitions {
+			if sense.Text != t.Definition {
+				term.Senses = append(term.Senses, sense)
+			}
+		}
+		for _, name := range t.Links {
+			if _, ok := slugs[name]; ok {
+				term.Links = append(term.Links, siteLink{Name: name, Href: href("../", name)})
+			}
+		}
+		data.Term = term
+		if err := write(filepath.Join(siteTermDir, slugs[t.Name]+".html"), "term.html", data); err != nil {
+			return pages, err
+		}
+	}
+	return pages, nil
+}
+
+// siteNav lists A to Z, whether or not any term starts with them, along
+// with any other letters terms do start with and the bucket for the rest,
+// in the order the markdown export uses.
+func siteNav(groups map[string][]termstore.Term) []siteLetter {
+	var letters []string
+	for r := 'A'; r <= 'Z'; r++ {
+		letters = append(letters, string(r))
+	}
+	for letter := range groups {
+		if !slices.Contains(letters, letter) {
+			letters = append(letters, letter)
+		}
+	}
+	slices.SortFunc(letters, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == termstore.OtherBucket:
+			return -1
+		case b == termstore.OtherBucket:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	nav := make([]siteLetter, len(letters))
+	for i, letter := range letters {
+		nav[i] = siteLetter{Letter: letter, Count: len(groups[letter])}
+		if nav[i].Count > 0 {
+			nav[i].File = letterFile(letter)
+		}
+	}
+	return nav
+}
+
+// letterFile names the page of a letter's terms.
+func letterFile(letter string) string {
+	return strings.ToLower(markdownHeading(letter)) + ".html"
+}
+
+// termSlugs names the page of each term, and the anchor of its entry on
+// its letter's page, after the term: lowercased, with each run of
+// anything but letters and digits turned into a hyphen. Names that come
+// out the same are told apart by a number, in alphabetical order so that
+// they don't change between runs.
+func termSlugs(terms []termstore.Term) map[string]string {
+	slugs := make(map[string]string, len(terms))
+	taken := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		base := slugify(t.Name)
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = base + "-" + strconv.Itoa(n)
+		}
+		taken[slug] = true
+		slugs[t.Name] = slug
+	}
+	return slugs
+}
+
+func slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slug := []rune(strings.Join(words, "-"))
+	if len(slug) > maxSlugRunes {
+		slug = slug[:maxSlugRunes]
+	}
+	if s := strings.Trim(string(slug), "-"); s != "" {
+		return s
+	}
+	return "term"
+}
+
+// copySiteAssets copies the files of the site that aren't templates into
+// dir.
+func copySiteAssets(dir string) error {
+	return fs.WalkDir(siteFiles, "site", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) == ".html" {
+			return err
+		}
+		data, err := siteFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return atomicfile.Write(filepath.Join(dir, strings.TrimPrefix(path, "site/")), func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateSite(t *testing.T) {
+	store := newTestStore(t, map[string]string{
+		"Cache":     "Fast memory holding data a CPU has used recently.",
+		"CPU":       "The processor that runs a program's instructions, reading them through a cache.",
+		"C++":       "A language extending C with classes & templates.",
+		"<script>":  `An HTML element; "<script>alert(1)</script>" must never run.`,
+		"2-3 tree":  "A search tree whose nodes have two or three children.",
+		"Éclair":    "A name starting with an accented letter.",
+		"Zero-copy": "Moving data without copying it between buffers.",
+	})
+
+	for _, tt := range []struct {
+		name string
+		opts SiteOptions
+	}{
+		{"letters", SiteOptions{}},
+		{"terms", SiteOptions{TermPages: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pages, err := GenerateSite(dir, store, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var htmlFiles int
+			err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				// The stylesheet is copied as it is
+				rel, _ := filepath.Rel(dir, path)
+				if filepath.Ext(rel) != ".html" {
+					return nil
+				}
+				htmlFiles++
+				got, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if bytes.Contains(got, []byte("<script>")) {
+					t.Errorf("%s has an unescaped <script>", rel)
+				}
+				checkGolden(t, filepath.Join("site", tt.name, rel), got)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if htmlFiles != pages {
+				t.Errorf("GenerateSite reported %d pages, wrote %d", pages, htmlFiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	termstore "scrape_cp/store"
+)
+
+// maxSnapshots is how many of the latest output files are kept in the
+// registry. Older ones stay on disk but can't be listed or compared.
+const maxSnapshots = 100
+
+// Snapshot is an output file written by a scrape.
+type Snapshot struct {
+	Timestamp string    `json:"timestamp"`
+	Time      time.Time `json:"time"`
+	File      string    `json:"file"`
+	Terms     int       `json:"terms"`
+}
+
+type SnapshotsResponse struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// SnapshotComparison is what changed between two snapshots.
+type SnapshotComparison struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Added   []string     `json:"added"`
+	Removed []string     `json:"removed"`
+	Changed []TermChange `json:"changed"`
+}
+
+var errCorruptSnapshot = errors.New("not a terms snapshot")
+
+// snapshotRegistry remembers the output files written so far, oldest
+// first, so listing them doesn't mean scanning the output directory.
+type snapshotRegistry struct {
+	mu        sync.Mutex
+	snapshots []Snapshot
+}
+
+var snapshots = &snapshotRegistry{}
+
+// record adds a file that was just written, dropping the oldest once there
+// are more than maxSnapshots.
+func (sr *snapshotRegistry) record(s Snapshot) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.snapshots = append(sr.snapshots, s)
+	if n := len(sr.snapshots) - maxSnapshots; n > 0 {
+		sr.snapshots = slices.Delete(sr.snapshots, 0, n)
+	}
+}
+
+// list returns the snapshots, newest first. It is never nil, so that no
+// snapshots are listed as [] rather than null.
+func (sr *snapshotRegistry) list() []Snapshot {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	list := append([]Snapshot{}, sr.snapshots...)
+	slices.Reverse(list)
+	return list
+}
+
+func (sr *snapshotRegistry) find(timestamp string) (Snapshot, bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for _, s := range sr.snapshots {
+		if s.Timestamp == timestamp {
+			return s, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// ScanSnapshots registers the snapshots earlier runs left in dir, so they
+// can be listed and compared.
+func ScanSnapshots(dir string) error {
+	return snapshots.scan(dir)
+}
+
+// scan registers the latest output files already in dir, for the files
+// written by earlier runs. Files that can't be read are skipped.
+func (sr *snapshotRegistry) scan(dir string) error {
+	found, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(found) > maxSnapshots {
+		found = found[len(found)-maxSnapshots:]
+	}
+
+	for _, s := range found {
+		terms, err := LoadSnapshot(s.File)
+		if err != nil {
+			slog.Warn("Skipping snapshot", "file", s.File, "err", err)
+			continue
+		}
+		s.Terms = len(terms)
+		sr.record(s)
+	}
+	return nil
+}
+
+// forget drops the snapshot with timestamp, once its file is deleted.
+func (sr *snapshotRegistry) forget(timestamp string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.snapshots = slices.DeleteFunc(sr.snapshots, func(s Snapshot) bool { return s.Timestamp == timestamp })
+}
+
+// latest returns the newest snapshot, if there is one.
+func (sr *snapshotRegistry) latest() (Snapshot, bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if len(sr.snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return sr.snapshots[len(sr.snapshots)-1], true
+}
+
+// LatestSnapshot returns the file of the newest snapshot, if there is one.
+func LatestSnapshot() (string, bool) {
+	s, ok := snapshots.latest()
+	return s.File, ok
+}
+
+// LoadSnapshot reads the terms from an output file, which is either a
+// list of Terms or, from before terms had more to them, a flat map of
+// names to definitions.
+func LoadSnapshot(file string) ([]termstore.Term, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	var terms []termstore.Term
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		if err := json.Unmarshal(data, &terms); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptSnapshot, err)
+		}
+		for _, t := range terms {
+			if t.Name == "" {
+				return nil, fmt.Errorf("%w: term without a name", errCorruptSnapshot)
+			}
+		}
+	case bytes.HasPrefix(data, []byte("{")):
+		var flat map[string]string
+		if err := json.Unmarshal(data, &flat); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptSnapshot, err)
+		}
+		for name, def := range flat {
+			terms = append(terms, termstore.Term{Name: name, Definition: def})
+		}
+	default:
+		return nil, errCorruptSnapshot
+	}
+	return terms, nil
+}
+
+// definitionsOf maps each term's name to its primary definition.
+func definitionsOf(terms []termstore.Term) map[string]string {
+	defs := make(map[string]string, len(terms))
+	for _, t := range terms {
+		defs[t.Name] = t.Definition
+	}
+	return defs
+}
+
+// LoadRecentSnapshot fills store from the newest snapshot if it was taken
+// within maxAge, or however old it is if maxAge is 0, returning its file.
+// A snapshot that can't be used is reported and left alone, so the caller
+// scrapes instead.
+func LoadRecentSnapshot(maxAge time.Duration, store termstore.TermStore) (string, bool) {
+	s, ok := snapshots.latest()
+	if !ok || maxAge > 0 && time.Since(s.Time) > maxAge {
+		return "", false
+	}
+
+	terms, err := LoadSnapshot(s.File)
+	if err == nil && len(terms) == 0 {
+		err = errors.New("it has no terms")
+	}
+	if err != nil {
+		slog.Warn("Can't load snapshot, scraping instead", "file", s.File, "err", err)
+		return "", false
+	}
+	if err := store.Upsert(terms); err != nil {
+		slog.Error("Failed to save the snapshot's terms", "file", s.File, "err", err)
+	}
+	return s.File, true
+}
+
+func getSnapshots(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, SnapshotsResponse{Snapshots: snapshots.list()})
+}
+
+// compareSnapshots reports the terms added, removed and changed between
+// the snapshots named by ?from and ?to.
+func compareSnapshots(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" || to == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, "from and to timestamps are required")
+		return
+	}
+
+	var defs [2]map[string]string
+	for i, timestamp := range []string{from, to} {
+		s, ok := snapshots.find(timestamp)
+		if !ok {
+			writeError(w, http.StatusNotFound, codeSnapshotNotFound, "no snapshot at "+timestamp)
+			return
+		}
+		terms, err := LoadSnapshot(s.File)
+		if errors.Is(err, fs.ErrNotExist) {
+			writeError(w, http.StatusNotFound, codeSnapshotDeleted, "snapshot "+timestamp+" has been deleted")
+			return
+		}
+		if err != nil {
+			logger(r.Context()).Error("Failed to load snapshot", "file", s.File, "err", err)
+			writeError(w, http.StatusUnprocessableEntity, codeSnapshotUnreadable, "snapshot "+timestamp+" can't be read")
+			return
+		}
+		defs[i] = definitionsOf(terms)
+	}
+
+	diff := diffTerms(defs[0], defs[1])
+	writeJSON(w, http.StatusOK, SnapshotComparison{
+		From:    from,
+		To:      to,
+		Added:   diff.Added,
+		Removed: diff.Removed,
+		Changed: diff.Changed,
+	})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSnapshots replaces the snapshot registry for the rest of the test
+// with one holding a snapshot of each file in dir, written with the
+// contents given, oldest first. A file given no contents is registered
+// but not written, as if it had since been deleted.
+func withSnapshots(t *testing.T, files ...[2]string) {
+	t.Helper()
+	saved := snapshots
+	t.Cleanup(func() { snapshots = saved })
+	snapshots = &snapshotRegistry{}
+
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, file := range files {
+		path := filepath.Join(dir, file[0]+".json")
+		if file[1] != "" {
+			if err := os.WriteFile(path, []byte(file[1]), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		snapshots.record(Snapshot{Timestamp: file[0], Time: start.Add(time.Duration(i) * time.Hour), File: path})
+	}
+}
+
+func TestListSnapshotsEmpty(t *testing.T) {
+	withSnapshots(t)
+	h := newTestServer(t, newTestStore(t, nil))
+
+	rec := serve(t, h, http.MethodGet, "/api/v1/snapshots", nil)
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"snapshots":[]}` {
+		t.Errorf("no snapshots listed as %s, want an empty list", got)
+	}
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	withSnapshots(t,
+		[2]string{"old", `[{"term":"Cache","definition":"Fast memory."},{"term":"Heap","definition":"A tree."}]`},
+		[2]string{"new", `{"Cache":"Small, fast memory.","Stack":"A LIFO list."}`},
+		[2]string{"truncated", `[{"term":"Cache","defin`},
+		[2]string{"nameless", `[{"definition":"A term without a name."}]`},
+		[2]string{"text", `Cache: Fast memory.`},
+		[2]string{"deleted", ``},
+	)
+	logs := captureLogs(t)
+	h := newTestServer(t, newTestStore(t, nil))
+
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"from=old&to=new", http.StatusOK,
+			`{"from":"old","to":"new","added":["Stack"],"removed":["Heap"],"changed":[{"term":"Cache","old":"Fast memory.","new":"Small, fast memory."}]}`},
+		{"from=old", http.StatusBadRequest,
+			`{"error":"from and to timestamps are required","code":"invalid_parameter","request_id":"test"}`},
+		{"from=old&to=never", http.StatusNotFound,
+			`{"error":"no snapshot at never","code":"snapshot_not_found","request_id":"test"}`},
+		{"from=deleted&to=new", http.StatusNotFound,
+			`{"error":"snapshot deleted has been deleted","code":"snapshot_deleted","request_id":"test"}`},
+		// A file that is there but isn't a snapshot is the client's to
+		// deal with, not a server error
+		{"from=old&to=truncated", http.StatusUnprocessableEntity,
+			`{"error":"snapshot truncated can't be read","code":"snapshot_unreadable","request_id":"test"}`},
+		{"from=nameless&to=new", http.StatusUnprocessableEntity,
+			`{"error":"snapshot nameless can't be read","code":"snapshot_unreadable","request_id":"test"}`},
+		{"from=text&to=new", http.StatusUnprocessableEntity,
+			`{"error":"snapshot text can't be read","code":"snapshot_unreadable","request_id":"test"}`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, "/api/v1/snapshots/compare?"+tt.query, nil, requestIDHeader, "test")
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.query, rec.Code, tt.status)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.body {
+			t.Errorf("%s: body\n%s\nwant\n%s", tt.query, got, tt.body)
+		}
+	}
+	// Why a snapshot couldn't be read is only in the logs
+	if n := strings.Count(logs.String(), `"err":"not a terms snapshot`); n != 3 {
+		t.Errorf("logged %d unreadable snapshots, want 3:\n%s", n, logs)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"scrape_cp/scraper"
+)
+
+// SourceInfo describes a source that is scraped, with the request
+// settings that apply to it after its own overrides. Terms counts the
+// terms whose definition it supplied.
+type SourceInfo struct {
+	Name     string  `json:"name"`
+	URL      string  `json:"url"`
+	Category string  `json:"category"`
+	Terms    int     `json:"terms"`
+	Timeout  float64 `json:"timeout_seconds"`
+	Retries  int     `json:"retries"`
+	Backoff  float64 `json:"backoff_seconds"`
+}
+
+type SourcesResponse struct {
+	Sources []SourceInfo `json:"sources"`
+}
+
+func getSources(w http.ResponseWriter, r *http.Request) {
+	counts := storeOf(r.Context()).SourceCounts()
+	response := SourcesResponse{Sources: make([]SourceInfo, len(scraper.Sources))}
+	for i, src := range scraper.Sources {
+		response.Sources[i] = SourceInfo{
+			Name:     src.Name,
+			URL:      src.URL,
+			Category: src.Category,
+			Terms:    counts[src.Name],
+			Timeout:  src.RequestTimeout().Seconds(),
+			Retries:  src.RetryLimit(),
+			Backoff:  src.RetryBackoff().Seconds(),
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// sourceParam returns the source a request filters by, if any, answering
+// 400 with the names it could be if it isn't a source that is scraped or
+// that supplied any of the terms. It reports whether the request should
+// go on.
+func sourceParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		return "", true
+	}
+
+	var names []string
+	for _, src := range scraper.Sources {
+		names = append(names, src.Name)
+	}
+	for name := range storeOf(r.Context()).SourceCounts() {
+		if name != "" && !slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) }) {
+			names = append(names, name)
+		}
+	}
+	if slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, source) }) {
+		return source, true
+	}
+	slices.Sort(names)
+	writeErrorDetails(w, http.StatusBadRequest, codeUnknownSource,
+		fmt.Sprintf("unknown source %q; source must be one of %s", source, strings.Join(names, ", ")),
+		map[string]any{"sources": names})
+	return "", false
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// Outcomes of a source's latest scrape.
+const (
+	outcomeOK      = "ok"
+	outcomeFailed  = "failed"
+	outcomeBlocked = "blocked"
+	// outcomeUnchanged means the source's page hadn't changed and its
+	// previous terms were kept
+	outcomeUnchanged = "unchanged"
+	// outcomeEmpty means the page came back fine but yielded no terms,
+	// which almost always means its layout changed under the scraper
+	outcomeEmpty = "empty"
+)
+
+// SourceStats describes the latest scrape of one source.
+type SourceStats struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+	Attempts int    `json:"attempts"`
+	// Terms is the number of terms parsed, when the scrape succeeded
+	Terms int `json:"terms"`
+	// Found counts the entries on the source's pages, including the
+	// Rejected ones that were turned down as invalid. Of the Terms,
+	// Added were new, Updated changed a definition and Collided had
+	// already come from another source, so the merge strategy settled
+	// their definitions.
+	Found    int `json:"found"`
+	Rejected int `json:"rejected"`
+	Added    int `json:"added"`
+	Updated  int `json:"updated"`
+	Collided int `json:"collided"`
+	// Duration is how long the scrape took, of which FetchSeconds went on
+	// downloading pages and ParseSeconds on extracting terms; merging them
+	// into the store took MergeSeconds on top
+	Duration     float64   `json:"duration_seconds"`
+	FetchSeconds float64   `json:"fetch_seconds"`
+	ParseSeconds float64   `json:"parse_seconds"`
+	MergeSeconds float64   `json:"merge_seconds"`
+	Finished     time.Time `json:"finished"`
+	// Pages lists the pages fetched for a source that spans several
+	Pages []string `json:"pages,omitempty"`
+}
+
+type StatsResponse struct {
+	Terms int `json:"terms"`
+	// Origin says where the terms came from: "scraped", or "loaded from
+	// store <file>" or "loaded from snapshot <file>" when startup reused
+	// recent terms
+	Origin  string        `json:"origin"`
+	Sources []SourceStats `json:"sources"`
+	// HostRequests counts the requests made to each host while scraping
+	HostRequests map[string]int `json:"host_requests"`
+	Refresh      RefreshStats   `json:"refresh"`
+	Retention    RetentionStats `json:"retention"`
+	// Store names where the terms are kept beyond memory, if anywhere,
+	// with any caveat about it
+	Store     string `json:"store,omitempty"`
+	StoreNote string `json:"store_note,omitempty"`
+}
+
+// RefreshStats describes the periodic refresh. Interval and NextRun are
+// only set when it is on; LastRun is the run_finished event of the latest
+// scrape, periodic or not.
+type RefreshStats struct {
+	Interval string       `json:"interval,omitempty"`
+	NextRun  *time.Time   `json:"next_run,omitempty"`
+	LastRun  *ScrapeEvent `json:"last_run,omitempty"`
+}
+
+// Values of StatsResponse.Origin.
+const (
+	originScraped  = "scraped"
+	OriginSnapshot = "loaded from snapshot "
+	OriginStore    = "loaded from store "
+)
+
+// scrapeStats keeps the latest SourceStats for each source, and where the
+// terms came from.
+type scrapeStats struct {
+	mu      sync.Mutex
+	sources map[string]SourceStats
+	origin  string
+}
+
+var stats = &scrapeStats{sources: make(map[string]SourceStats), origin: originScraped}
+
+// StoreName is the backend chosen with --store, reported in /stats.
+var StoreName = termstore.BackendMemory
+
+// SetOrigin records where the terms being served came from, for when
+// they were loaded rather than scraped: OriginStore or OriginSnapshot
+// followed by the file. The server is ready from then on.
+func SetOrigin(origin string) {
+	stats.setOrigin(origin)
+	loaded.Store(true)
+}
+
+func (st *scrapeStats) setOrigin(origin string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.origin = origin
+}
+
+func (st *scrapeStats) dataOrigin() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.origin
+}
+
+func (st *scrapeStats) record(s SourceStats) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sources[s.Name] = s
+}
+
+// list returns the stats of every source scraped so far, by name.
+func (st *scrapeStats) list() []SourceStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	list := make([]SourceStats, 0, len(st.sources))
+	for _, s := range st.sources {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func getStats(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	response := StatsResponse{
+		Terms:        store.Len(),
+		Origin:       stats.dataOrigin(),
+		Sources:      stats.list(),
+		HostRequests: scraper.HostRequests(),
+		Refresh:      scrapes.refreshStats(),
+		Retention:    retentionStats(OutputDir),
+	}
+	if b := store.Persistent(); b != nil {
+		response.Store = StoreName
+		if n, ok := b.(termstore.Noter); ok {
+			response.StoreNote = n.Note()
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// getDefinitionStats serves the shape of the definitions, which shows a
+// source whose definitions have started coming through truncated.
+func getDefinitionStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, storeOf(r.Context()).DefinitionStats())
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	termstore "scrape_cp/store"
+)
+
+// downBackend is a backend whose every read fails.
+type downBackend struct{ termstore.Backend }
+
+func (downBackend) Count() (int, error) { return 0, errors.New("connection refused") }
+
+func (downBackend) Get(string) (termstore.Term, bool, error) {
+	return termstore.Term{}, false, errors.New("connection refused")
+}
+
+func (downBackend) Search(string, bool, int) ([]termstore.Term, error) {
+	return nil, errors.New("connection refused")
+}
+
+// readOnlyBackend is an empty backend that can't be written to, with a
+// note for /stats.
+type readOnlyBackend struct{ termstore.Backend }
+
+func (readOnlyBackend) List(int, int) ([]termstore.Term, error) { return nil, nil }
+func (readOnlyBackend) Upsert([]termstore.Term) error           { return errors.New("READONLY") }
+func (readOnlyBackend) Note() string                            { return "writes are refused" }
+
+// fakeStore serves a MemoryStore's terms, but reports the backend it is
+// given and records the searches made.
+type fakeStore struct {
+	termstore.TermStore
+	backend  termstore.Backend
+	searched []string
+}
+
+func (s *fakeStore) Persistent() termstore.Backend { return s.backend }
+
+func (s *fakeStore) Search(ctx context.Context, query string, opts termstore.SearchOptions) ([]termstore.TermResponse, error) {
+	s.searched = append(s.searched, query)
+	return s.TermStore.Search(ctx, query, opts)
+}
+
+func TestHandlersServeAnyTermStore(t *testing.T) {
+	store := &fakeStore{TermStore: newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+	})}
+	h := newTestServer(t, store)
+
+	rec := serve(t, h, http.MethodGet, "/api/v1/terms/search?q=storage", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"term":"Cache"`) {
+		t.Errorf("search = %d %s", rec.Code, rec.Body)
+	}
+	if len(store.searched) != 1 || store.searched[0] != "storage" {
+		t.Errorf("store searched for %q, want storage", store.searched)
+	}
+
+	captureLogs(t)
+	store.backend = downBackend{}
+	want := `{"error":"term store unavailable","code":"store_unavailable","request_id":"req-1"}`
+	for _, target := range []string{
+		"/api/v1/terms",
+		"/api/v1/terms/Cache",
+		"/api/v1/terms/suggest?q=ca",
+		"/api/v1/terms/search?q=cache&fields=term",
+	} {
+		rec = serve(t, h, http.MethodGet, target, nil, requestIDHeader, "req-1")
+		if rec.Code != http.StatusServiceUnavailable || strings.TrimSpace(rec.Body.String()) != want {
+			t.Errorf("GET %s with the backend down = %d %s, want 503 %s", target, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestLookupsAndSearchesGoToTheBackend(t *testing.T) {
+	b, err := termstore.Open(termstore.BackendSQLite, filepath.Join(t.TempDir(), termstore.DefaultDBFile), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	store := termstore.NewMemoryStore()
+	if err := store.Attach(b); err != nil {
+		t.Fatal(err)
+	}
+	store.Upsert([]termstore.Term{{Name: "Cache", Definition: "Fast storage close to where it is used."}})
+	// Terms only the database holds can only be found by asking it
+	if err := b.Upsert([]termstore.Term{{Name: "Cache line", Definition: "The unit a cache holds."}}); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(t, store)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/v1/terms/cache%20LINE", `"term":"Cache line"`},
+		{"/api/v1/terms/suggest?q=cache", `"suggestions":["Cache","Cache line"]`},
+		{"/api/v1/terms/search?q=line&fields=term", `"term":"Cache line"`},
+	}
+	for _, tt := range tests {
+		rec := serve(t, h, http.MethodGet, tt.target, nil)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d %s, want %s", tt.target, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	// Searching definitions reads the in-memory index
+	rec := serve(t, h, http.MethodGet, "/api/v1/terms/search?q=unit", nil)
+	if strings.Contains(rec.Body.String(), "Cache line") {
+		t.Errorf("a definition search found a term only the database holds: %s", rec.Body)
+	}
+}
+
+func TestFailedWritesAreUnavailable(t *testing.T) {
+	saved := WriteAPIKey
+	t.Cleanup(func() { WriteAPIKey = saved })
+	WriteAPIKey = "secret"
+	logs := captureLogs(t)
+
+	store := termstore.NewMemoryStore()
+	if err := store.Attach(readOnlyBackend{}); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(t, store)
+
+	rec := serve(t, h, http.MethodPost, "/api/v1/import", strings.NewReader(`{"Cache":"Fast storage close to where it is used."}`),
+		"Content-Type", "application/json", "Authorization", "Bearer secret", requestIDHeader, "req-1")
+	want := `{"error":"term store unavailable","code":"store_unavailable","request_id":"req-1"}`
+	if rec.Code != http.StatusServiceUnavailable || strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("import with the backend refusing writes = %d %s, want 503 %s", rec.Code, rec.Body, want)
+	}
+	if !strings.Contains(logs.String(), "READONLY") {
+		t.Errorf("the failed write wasn't logged: %s", logs)
+	}
+
+	// A dry run doesn't write, so it doesn't fail
+	rec = serve(t, h, http.MethodPost, "/api/v1/import?dry_run=true", strings.NewReader(`{"Heap":"Memory allocated at run time."}`),
+		"Content-Type", "application/json", "Authorization", "Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Errorf("dry run import = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
+
+func TestStatsNoteTheStore(t *testing.T) {
+	saved := StoreName
+	t.Cleanup(func() { StoreName = saved })
+	StoreName = "readonly"
+
+	store := termstore.NewMemoryStore()
+	if err := store.Attach(readOnlyBackend{}); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(t, newTestServer(t, store), http.MethodGet, "/api/v1/stats", nil)
+	var stats StatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Store != "readonly" || stats.StoreNote != "writes are refused" {
+		t.Errorf("stats report store %q with note %q", stats.Store, stats.StoreNote)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamWriteWait bounds how long a single message may take to send
+	streamWriteWait = 10 * time.Second
+	// streamPongWait is how long a client may go without answering a ping
+	streamPongWait = 60 * time.Second
+	// streamPingPeriod must be shorter than streamPongWait
+	streamPingPeriod = streamPongWait * 9 / 10
+)
+
+var streamUpgrader = websocket.Upgrader{
+	// The stream carries the same public data as the rest of the API, so
+	// dashboards on other origins may connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// streamTerms upgrades to a WebSocket and sends a TermEvent as JSON for
+// every change to the store until the client goes away, falls too far
+// behind, or the server shuts down.
+func streamTerms(w http.ResponseWriter, r *http.Request) {
+	store := storeOf(r.Context())
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error status
+		return
+	}
+	defer conn.Close()
+
+	sub := store.Events().Subscribe()
+	defer store.Events().Unsubscribe(sub)
+
+	// The client only ever sends control frames, but reading is what
+	// answers its pings and notices its pongs and close frames.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				code, reason := websocket.CloseGoingAway, "server shutting down"
+				if sub.Dropped {
+					code, reason = websocket.ClosePolicyViolation, "client too slow"
+					logger(r.Context()).Warn("Dropped term stream client for falling behind", "remote", r.RemoteAddr)
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(code, reason), time.Now().Add(streamWriteWait))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
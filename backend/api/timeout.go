@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestTimeout is how long a request may take by default.
+const DefaultRequestTimeout = 15 * time.Second
+
+// RequestTimeout, set with --request-timeout, is how long a request to
+// any route but a streaming one may take before it is answered with 503
+// and its context is cancelled. Zero leaves requests unbounded.
+var RequestTimeout = DefaultRequestTimeout
+
+// withDeadline answers a request next hasn't finished within
+// RequestTimeout with a 503 error body. The response is buffered until
+// next returns, so it is only for routes that don't stream.
+func withDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if RequestTimeout <= 0 {
+			next(w, r)
+			return
+		}
+
+		// TimeoutHandler hands next a fresh set of headers, so the ones
+		// already set, such as the request ID, are copied over
+		set := w.Header().Clone()
+		handler := http.HandlerFunc(func(tw http.ResponseWriter, r *http.Request) {
+			maps.Copy(tw.Header(), set)
+			next(tw, r)
+		})
+
+		body, _ := json.Marshal(newErrorResponse(w, codeTimeout,
+			fmt.Sprintf("the request took longer than %s", RequestTimeout)))
+		// Only the timeout body relies on this; next's own Content-Type
+		// replaces it
+		w.Header().Set("Content-Type", "application/json")
+		http.TimeoutHandler(handler, RequestTimeout, string(body)).ServeHTTP(w, r)
+	}
+}
+
+// withoutWriteDeadline lifts the server's write timeout for a streaming
+// route, which may rightly keep writing for much longer.
+func withoutWriteDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Fails only where there is no deadline to lift
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		next(w, r)
+	}
+}
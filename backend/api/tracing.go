@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// tracer starts the spans of refreshes and merges. Its spans are no-ops
+// unless the binary set up a tracer provider.
+var tracer = otel.Tracer("scrape_cp/api")
+
+// Attributes of the API's spans, besides the ones otelhttp adds.
+const (
+	attrRoute     = attribute.Key("http.route")
+	attrTermCount = attribute.Key("terms.count")
+	attrSource    = attribute.Key("scrape.source")
+	attrSources   = attribute.Key("scrape.sources")
+	attrFailed    = attribute.Key("scrape.failed")
+	attrForce     = attribute.Key("scrape.force")
+	attrTerms     = attribute.Key("scrape.terms")
+	attrAdded     = attribute.Key("scrape.added")
+	attrUpdated   = attribute.Key("scrape.updated")
+	attrCollided  = attribute.Key("scrape.collided")
+)
+
+// traceMiddleware traces each request as a span named after its method and
+// route template, carrying on any trace the client sent. otelhttp records
+// the status; the store's size at the time is added alongside the route.
+// It is only used when tracing is on, so that it costs nothing otherwise.
+func traceMiddleware(next http.Handler) http.Handler {
+	annotate := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attrRoute.String(routeOf(r)),
+			attrTermCount.Int(storeOf(r.Context()).Len()),
+		)
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(annotate, "http.request",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + routeOf(r)
+		}))
+}
+
+// startRefreshSpan starts the span a scrape of every source runs under,
+// each source's span being its child.
+func startRefreshSpan(ctx context.Context, force bool) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "refresh", trace.WithAttributes(
+		attrSources.Int(len(scraper.Sources)),
+		attrForce.Bool(force),
+	))
+}
+
+// endRefreshSpan records the outcome of a scrape on its span and ends it.
+func endRefreshSpan(span trace.Span, summary ScrapeEvent, failed scraper.Errors) {
+	span.SetAttributes(
+		attrAdded.Int(summary.Added),
+		attrUpdated.Int(summary.Updated),
+		attrFailed.Int(len(failed)),
+		attrTermCount.Int(summary.Total),
+	)
+	if len(failed) > 0 {
+		span.SetStatus(codes.Error, "some sources failed")
+	}
+	span.End()
+}
+
+// traceMerge merges what src supplied into store under a span of its own,
+// a child of the source's span in ctx, returning what the merge did.
+func traceMerge(ctx context.Context, store termstore.TermStore, src scraper.Source, terms map[string][]string) (termstore.MergeCounts, error) {
+	_, span := tracer.Start(ctx, "merge", trace.WithAttributes(
+		attrSource.String(src.Name),
+		attrTerms.Int(len(terms)),
+	))
+	defer span.End()
+	counts, err := store.Merge(terms, src)
+	span.SetAttributes(attrAdded.Int(counts.Added), attrUpdated.Int(counts.Updated), attrCollided.Int(counts.Collided))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return counts, err
+}
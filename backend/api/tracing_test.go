@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"scrape_cp/scraper"
+	termstore "scrape_cp/store"
+)
+
+// spanRecorder records every span the process ends from here on. The
+// tracers the packages hold delegate to the first provider set, so it is
+// only set once.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+// recordSpans returns a function listing the spans ended after it was
+// called, as "parent > span" with "-" for a root span.
+func recordSpans(t *testing.T) func() ([]string, []sdktrace.ReadOnlySpan) {
+	recorder := spanRecorder()
+	before := len(recorder.Ended())
+	return func() ([]string, []sdktrace.ReadOnlySpan) {
+		spans := recorder.Ended()[before:]
+		names := make(map[[8]byte]string)
+		for _, s := range spans {
+			names[s.SpanContext().SpanID()] = s.Name()
+		}
+		var tree []string
+		for _, s := range spans {
+			parent := "-"
+			if s.Parent().IsValid() {
+				parent = names[s.Parent().SpanID()]
+			}
+			tree = append(tree, parent+" > "+s.Name())
+		}
+		slices.Sort(tree)
+		return tree, spans
+	}
+}
+
+// attr returns the value of the attribute key on span.
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTraceRequest(t *testing.T) {
+	spans := recordSpans(t)
+	markLoaded(t)
+	captureLogs(t)
+	h := newHandler(ServerConfig{Trace: true}, newTestStore(t, map[string]string{
+		"Cache": "Fast storage close to where it is used.",
+		"CPU":   "The processor that runs a program.",
+	}))
+	if rec := serve(t, h, http.MethodGet, "/api/v1/terms/Cache", nil); rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/terms/Cache = %d", rec.Code)
+	}
+
+	tree, ended := spans()
+	if want := []string{"- > GET /api/v1/terms/{term}"}; !slices.Equal(tree, want) {
+		t.Fatalf("spans = %q, want %q", tree, want)
+	}
+	span := ended[0]
+	if got := attr(span, attrRoute).AsString(); got != "/api/v1/terms/{term}" {
+		t.Errorf("route = %q", got)
+	}
+	if got := attr(span, attrTermCount).AsInt64(); got != 2 {
+		t.Errorf("term count = %d, want 2", got)
+	}
+	if got := attr(span, "http.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("status = %d, want 200", got)
+	}
+}
+
+func TestTraceScrape(t *testing.T) {
+	savedSources, savedDelay, savedDir := scraper.Sources, scraper.HostDelay, OutputDir
+	t.Cleanup(func() { scraper.Sources, scraper.HostDelay, OutputDir = savedSources, savedDelay, savedDir })
+	markLoaded(t)
+	captureLogs(t)
+	OutputDir = t.TempDir()
+	scraper.SetStateDir(OutputDir)
+	scraper.HostDelay = 0
+
+	glossary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<dl><dt>Cache</dt><dd>Fast memory.</dd><dt>CPU</dt><dd>The processor.</dd></dl>`)
+	}))
+	defer glossary.Close()
+	scraper.Sources = []scraper.Source{{Name: "Glossary", URL: glossary.URL + "/glossary", ScrapeFunc: scrapeDefinitions}}
+
+	spans := recordSpans(t)
+	if _, err := Scrape(context.Background(), termstore.NewMemoryStore()); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, ended := spans()
+	want := []string{
+		"- > refresh",
+		"refresh > scrape source",
+		"scrape source > fetch",
+		"scrape source > merge",
+		"scrape source > parse",
+	}
+	if !slices.Equal(tree, want) {
+		t.Fatalf("spans =\n%s\nwant\n%s", strings.Join(tree, "\n"), strings.Join(want, "\n"))
+	}
+	for _, span := range ended {
+		switch span.Name() {
+		case "refresh":
+			if got := attr(span, attrTermCount).AsInt64(); got != 2 {
+				t.Errorf("refresh term count = %d, want 2", got)
+			}
+		case "scrape source":
+			if attr(span, "scrape.attempts").AsInt64() != 1 || attr(span, "scrape.terms").AsInt64() != 2 {
+				t.Errorf("source span attributes = %v, want 1 attempt and 2 terms", span.Attributes())
+			}
+		case "merge":
+			if got := attr(span, attrAdded).AsInt64(); got != 2 {
+				t.Errorf("merge added = %d, want 2", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// webhookAttempts is how many times a delivery is tried in total
+	webhookAttempts = 4
+	// webhookBackoff is the wait before the first retry, doubling after
+	// each one
+	webhookBackoff = time.Second
+	// webhookSignatureHeader carries the hex HMAC-SHA256 of the body when
+	// a secret is configured
+	webhookSignatureHeader = "X-Scrape-CP-Signature"
+)
+
+// WebhookPayload is POSTed to every webhook after a scrape finishes.
+type WebhookPayload struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Sources maps each source that succeeded to its term count
+	Sources map[string]int `json:"sources"`
+	Total   int            `json:"total"`
+	Added   int            `json:"added"`
+	Updated int            `json:"updated"`
+	// Output is the file the terms were saved to, if anything changed
+	Output string `json:"output,omitempty"`
+}
+
+// webhookNotifier tells downstream services that a scrape has finished.
+type webhookNotifier struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+}
+
+var webhooks = &webhookNotifier{client: &http.Client{Timeout: 10 * time.Second}}
+
+// SetWebhooks sets the URLs told about every finished scrape, and the key
+// their payloads are signed with; nil leaves them unsigned.
+func SetWebhooks(urls []string, secret []byte) {
+	webhooks.urls = urls
+	webhooks.secret = secret
+}
+
+// NotifyWebhooks tells the webhooks about a finished scrape whose terms
+// were saved to output.
+func NotifyWebhooks(summary ScrapeEvent, output string) {
+	webhooks.notify(summary, output)
+}
+
+// notify delivers a summary of a finished scrape to every webhook in the
+// background. Failed deliveries are logged and never affect the scrape.
+func (n *webhookNotifier) notify(summary ScrapeEvent, output string) {
+	if len(n.urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Timestamp: summary.Time,
+		Sources:   summary.Sources,
+		Total:     summary.Total,
+		Added:     summary.Added,
+		Updated:   summary.Updated,
+		Output:    output,
+	})
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "err", err)
+		return
+	}
+
+	var signature string
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	for _, url := range n.urls {
+		go n.deliver(url, body, signature)
+	}
+}
+
+// deliver POSTs body to url, retrying with exponential backoff while the
+// request fails or gets a non-2xx response.
+func (n *webhookNotifier) deliver(url string, body []byte, signature string) {
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err := n.post(url, body, signature)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			slog.Error("Giving up on webhook", "url", url, "attempts", attempt, "err", err)
+			return
+		}
+		slog.Warn("Webhook failed, retrying", "url", url, "attempt", attempt, "wait", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (n *webhookNotifier) post(url string, body []byte, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(webhookSignatureHeader, signature)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
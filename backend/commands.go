@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"scrape_cp/api"
+	"scrape_cp/scraper"
+	"scrape_cp/store"
+)
+
+// runAll loads the terms kept from a recent run or scrapes them, saves
+// them and serves them until interrupted. The server starts right away
+// and a scrape runs in the background.
+func runAll(args []string) error {
+	fs := flag.CommandLine
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(fs.Output(), "\nFlags without a command:")
+		fs.PrintDefaults()
+	}
+	common := addCommonFlags(fs)
+	scraping := addScrapeFlags(fs)
+	output := addOutputFlags(fs)
+	storage := addStoreFlags(fs)
+	serving := addServeFlags(fs)
+	startup := addStartupFlags(fs)
+	if _, err := common.parseFlags(fs, args); err != nil {
+		return err
+	}
+	common.logSettings(fs)
+
+	cfg, err := setUp(common, scraping, output, serving)
+	if err != nil {
+		return err
+	}
+	stopTracing, tracing, err := startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	cfg.Trace = tracing
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignals(cancel)
+
+	if err := common.openOutput(); err != nil {
+		return err
+	}
+	terms := store.NewMemoryStore()
+	backend, err := storage.attach(terms)
+	if err != nil {
+		return err
+	}
+	if backend != nil {
+		defer backend.Close()
+	}
+
+	// Serve what the store kept from the last run, or a recent snapshot,
+	// rather than scraping every source again
+	var origin string
+	if !startup.forceScrape && startup.maxSnapshotAge > 0 {
+		origin = loadSaved(terms, backend, storage, startup.maxSnapshotAge)
+	}
+	if origin == "" {
+		return serve(ctx, terms, cfg, serving, scrapeOrLoad(terms, backend, storage, output))
+	}
+	serveSaved(terms, origin)
+	if err := output.writeExtras(time.Now().Format(api.TimestampLayout), terms); err != nil {
+		return err
+	}
+	return serve(ctx, terms, cfg, serving, nil)
+}
+
+// runScrape scrapes every source and saves the terms. It fails if any
+// source did, after saving what the others supplied, so a scheduled run
+// can tell a partial scrape from a complete one.
+func runScrape(args []string) error {
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	scraping := addScrapeFlags(fs)
+	output := addOutputFlags(fs)
+	storage := addStoreFlags(fs)
+	if _, err := common.parseFlags(fs, args); err != nil {
+		return err
+	}
+	common.logSettings(fs)
+
+	if _, err := setUp(common, scraping, output, nil); err != nil {
+		return err
+	}
+	stopTracing, _, err := startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignals(cancel)
+
+	if err := common.openOutput(); err != nil {
+		return err
+	}
+	terms := store.NewMemoryStore()
+	backend, err := storage.attach(terms)
+	if err != nil {
+		return err
+	}
+	if backend != nil {
+		defer backend.Close()
+	}
+
+	timestamp := time.Now().Format(api.TimestampLayout)
+	failed, err := scrapeAndSave(ctx, terms, timestamp)
+	if errors.Is(err, context.Canceled) {
+		return errors.New("scrape interrupted")
+	} else if err != nil {
+		return err
+	}
+	if err := output.writeExtras(timestamp, terms); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d sources failed", len(failed), len(scraper.Sources))
+	}
+	return nil
+}
+
+// runServe serves the terms kept by --store or the newest snapshot,
+// however old, until interrupted. With --scrape it scrapes them instead,
+// serving as soon as it starts.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	scraping := addScrapeFlags(fs)
+	output := addOutputFlags(fs)
+	storage := addStoreFlags(fs)
+	serving := addServeFlags(fs)
+	scrapeFirst := fs.Bool("scrape", false, "scrape every source at startup instead of serving the saved terms")
+	if _, err := common.parseFlags(fs, args); err != nil {
+		return err
+	}
+	common.logSettings(fs)
+
+	cfg, err := setUp(common, scraping, output, serving)
+	if err != nil {
+		return err
+	}
+	stopTracing, tracing, err := startTracing()
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+	cfg.Trace = tracing
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignals(cancel)
+
+	if err := common.openOutput(); err != nil {
+		return err
+	}
+	terms := store.NewMemoryStore()
+	backend, err := storage.attach(terms)
+	if err != nil {
+		return err
+	}
+	if backend != nil {
+		defer backend.Close()
+	}
+
+	if *scrapeFirst {
+		return serve(ctx, terms, cfg, serving, scrapeOrLoad(terms, backend, storage, output))
+	}
+	origin := loadSaved(terms, backend, storage, 0)
+	if origin == "" {
+		return fmt.Errorf("no saved terms to serve in %s; run the scrape command first or pass --scrape", common.outputDir)
+	}
+	serveSaved(terms, origin)
+	return serve(ctx, terms, cfg, serving, nil)
+}
+
+// runExport converts a saved snapshot to one of the export formats,
+// without touching the network.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	format := fs.String("format", "csv", "format to convert to: "+strings.Join(api.ExportFormatNames, ", "))
+	in := fs.String("in", "", "snapshot to convert; the newest in the output directory if empty")
+	out := fs.String("out", "", "file to write; standard output if empty")
+	if _, err := common.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if !slices.Contains(api.ExportFormatNames, *format) {
+		return fmt.Errorf("--format must be one of %s", strings.Join(api.ExportFormatNames, ", "))
+	}
+	terms, file, err := loadTerms(common.outputDir, *in)
+	if err != nil {
+		return err
+	}
+	api.SetOrigin(api.OriginSnapshot + file)
+
+	if *out == "" {
+		return api.Export(os.Stdout, *format, terms)
+	}
+	if err := api.ExportFile(*out, *format, terms); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d terms from %s to %s\n", terms.Len(), file, *out)
+	return nil
+}
+
+// runGenerateSite renders a saved snapshot as static HTML pages that can
+// be published without running the server.
+func runGenerateSite(args []string) error {
+	fs := flag.NewFlagSet("generate-site", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	in := fs.String("in", "", "snapshot to render; the newest in the output directory if empty")
+	out := fs.String("out", "site", "directory to write the site to")
+	termPages := fs.Bool("term-pages", false, "also write a page for every term, linking to the terms its definition mentions")
+	if _, err := common.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	terms, file, err := loadTerms(common.outputDir, *in)
+	if err != nil {
+		return err
+	}
+	pages, err := api.GenerateSite(*out, terms, api.SiteOptions{TermPages: *termPages})
+	if err != nil {
+		return fmt.Errorf("generating site: %w", err)
+	}
+	fmt.Printf("Wrote %d pages for %d terms from %s to %s\n", pages, terms.Len(), file, *out)
+	return nil
+}
+
+// setUp checks and applies the flags a command registered; serving is nil
+// for commands that don't serve.
+func setUp(common *commonFlags, scraping *scrapeFlags, output *outputFlags, serving *serveFlags) (api.ServerConfig, error) {
+	if err := scraping.apply(); err != nil {
+		return api.ServerConfig{}, err
+	}
+	if err := output.check(); err != nil {
+		return api.ServerConfig{}, err
+	}
+	if err := common.loadSources(); err != nil {
+		return api.ServerConfig{}, err
+	}
+	if serving == nil {
+		return api.ServerConfig{}, nil
+	}
+	return serving.config()
+}
+
+// loadSaved fills terms from what the backend kept, or else the newest
+// snapshot, if they are younger than maxAge, or however old they are if
+// maxAge is 0. It returns where the terms came from, or "" if nothing was
+// loaded.
+func loadSaved(terms *store.MemoryStore, backend store.Backend, storage *storeFlags, maxAge time.Duration) string {
+	if backend != nil && terms.Len() > 0 && (maxAge == 0 || store.ScrapedWithin(backend, maxAge)) {
+		return storage.origin()
+	}
+	if snapshot, ok := api.LoadRecentSnapshot(maxAge, terms); ok {
+		return api.OriginSnapshot + snapshot
+	}
+	return ""
+}
+
+// serveSaved prepares terms loaded by loadSaved for serving.
+func serveSaved(terms *store.MemoryStore, origin string) {
+	if err := terms.Link(); err != nil {
+		slog.Error("Failed to save linked terms", "err", err)
+	}
+	api.SetOrigin(origin)
+	slog.Info("Serving saved terms instead of scraping", "terms", terms.Len(), "origin", origin)
+}
+
+// scrapeAndSave scrapes every source into terms and saves them under
+// timestamp, returning the sources that failed. It fails if nothing could
+// be scraped, or with ctx's error if ctx was cancelled first.
+func scrapeAndSave(ctx context.Context, terms *store.MemoryStore, timestamp string) (scraper.Errors, error) {
+	summary, err := api.Scrape(ctx, terms)
+	var failed scraper.Errors
+	if err != nil && !errors.As(err, &failed) {
+		return nil, err
+	}
+
+	if terms.Len() == 0 {
+		if len(failed) > 0 {
+			return failed, fmt.Errorf("no terms were scraped; %d of %d sources failed:\n%v", len(failed), len(scraper.Sources), failed)
+		}
+		return nil, fmt.Errorf("no valid terms were found in any of the %d sources", len(scraper.Sources))
+	}
+	if len(failed) > 0 {
+		slog.Warn("Some sources failed, carrying on with the rest", "failed", len(failed), "sources", len(scraper.Sources), "err", failed)
+	}
+
+	files, err := api.SaveOutput(timestamp, terms)
+	if err != nil {
+		return failed, fmt.Errorf("saving terms: %w", err)
+	}
+
+	slog.Info("Scraped and saved terms", "terms", terms.Len(), "files", strings.Join(files, ", "))
+	api.NotifyWebhooks(summary, files[0])
+	return failed, nil
+}
+
+// scrapeOrLoad returns a function for serve to load terms with: it
+// scrapes every source into terms and saves them, falling back to the
+// saved terms however old if nothing could be scraped.
+func scrapeOrLoad(terms *store.MemoryStore, backend store.Backend, storage *storeFlags, output *outputFlags) func(context.Context) error {
+	return func(ctx context.Context) error {
+		timestamp := time.Now().Format(api.TimestampLayout)
+		if _, err := scrapeAndSave(ctx, terms, timestamp); errors.Is(err, context.Canceled) {
+			slog.Info("Scrape interrupted, exiting")
+			return err
+		} else if err != nil {
+			origin := loadSaved(terms, backend, storage, 0)
+			if origin == "" {
+				return err
+			}
+			slog.Error("Scrape failed, serving saved terms instead", "err", err)
+			serveSaved(terms, origin)
+		}
+		return output.writeExtras(timestamp, terms)
+	}
+}
+
+// serve runs the API servers over terms until ctx is done or one of them
+// fails, then saves the terms if they changed since they were last saved.
+// load, if not nil, fills terms in the background while the servers
+// start answering; the servers stop if it fails, and its terms count as
+// saved once it returns.
+func serve(ctx context.Context, terms *store.MemoryStore, cfg api.ServerConfig, serving *serveFlags, load func(context.Context) error) error {
+	ctx, fail := context.WithCancelCause(ctx)
+	defer fail(nil)
+
+	server, err := api.StartServer(cfg, terms, fail)
+	if err != nil {
+		return err
+	}
+
+	var grpcServer *grpc.Server
+	if serving.grpcAddr != "" {
+		if grpcServer, err = api.StartGRPCServer(serving.grpcAddr, terms, fail); err != nil {
+			return err
+		}
+	}
+
+	saved := terms.DataVersion()
+	loading := make(chan error, 1)
+	if load == nil {
+		loading <- nil
+	} else {
+		go func() {
+			err := load(ctx)
+			if err != nil {
+				fail(err)
+			}
+			saved = terms.DataVersion()
+			loading <- err
+		}()
+	}
+
+	if api.RefreshInterval > 0 {
+		go api.ScheduleRefresh(ctx, terms)
+		slog.Info("Refreshing periodically", "interval", api.RefreshInterval)
+	}
+
+	<-ctx.Done()
+	if grpcServer != nil {
+		api.StopGRPCServer(grpcServer, serving.shutdownGrace)
+	}
+	if err := api.StopServer(server, serving.shutdownGrace); err != nil {
+		slog.Error("Failed to stop API server", "err", err)
+	}
+
+	// Flush anything that changed while serving before exiting, unless the
+	// terms never finished loading
+	if err := <-loading; err == nil && terms.DataVersion() != saved {
+		if files, err := api.SaveOutput(time.Now().Format(api.TimestampLayout), terms); err != nil {
+			slog.Error("Failed to save final snapshot", "err", err)
+		} else {
+			slog.Info("Saved final snapshot", "files", strings.Join(files, ", "))
+		}
+	}
+	if err := context.Cause(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
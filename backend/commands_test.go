@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"scrape_cp/api"
+	"scrape_cp/scraper"
+	"scrape_cp/store"
+)
+
+// scrapeDefinitionList extracts each dt and the dd after it.
+func scrapeDefinitionList(doc *goquery.Document) scraper.Extracted {
+	extracted := scraper.Extracted{Terms: make(map[string][]string)}
+	doc.Find("dt").Each(func(i int, dt *goquery.Selection) {
+		extracted.Found++
+		term, definition := scraper.CleanText(dt.Text()), scraper.CleanText(dt.Next().Text())
+		if !scraper.IsValidTerm(term, definition) {
+			extracted.Rejected++
+			return
+		}
+		extracted.Terms[term] = append(extracted.Terms[term], definition)
+	})
+	return extracted
+}
+
+// withSources scrapes pages, saved to disk under their source's name,
+// instead of the usual sources for the rest of the test, saving what is
+// scraped in a directory of its own. A nil page is a source whose page is
+// missing, so fetching it fails.
+func withSources(t *testing.T, pages map[string]*string) {
+	t.Helper()
+	dir := t.TempDir()
+	savedSources, savedOutput := scraper.Sources, api.OutputDir
+	t.Cleanup(func() { scraper.Sources, api.OutputDir = savedSources, savedOutput })
+	api.OutputDir = t.TempDir()
+	scraper.SetStateDir(api.OutputDir)
+
+	scraper.Sources = nil
+	for _, name := range slices.Sorted(maps.Keys(pages)) {
+		path := filepath.Join(dir, name+".html")
+		if page := pages[name]; page != nil {
+			if err := os.WriteFile(path, []byte(*page), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		scraper.Sources = append(scraper.Sources, scraper.Source{
+			Name:       name,
+			URL:        (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(),
+			ScrapeFunc: scrapeDefinitionList,
+		})
+	}
+}
+
+func page(html string) *string { return &html }
+
+const (
+	validPage = `<dl><dt>Cache</dt><dd>Fast memory holding copies of data.</dd>
+<dt>Heap</dt><dd>Memory allocated at run time.</dd></dl>`
+	// Every entry is too short to keep
+	invalidPage = `<dl><dt>X</dt><dd>Too short.</dd><dt>Stack</dt><dd>Stack.</dd></dl>`
+)
+
+func TestScrapeAndSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   map[string]*string
+		failed  []string
+		terms   int
+		wantErr string
+	}{
+		{
+			name:    "all sources fail",
+			pages:   map[string]*string{"A": nil, "B": nil},
+			failed:  []string{"A", "B"},
+			wantErr: "no terms were scraped; 2 of 2 sources failed",
+		},
+		{
+			name:   "some sources fail",
+			pages:  map[string]*string{"A": nil, "B": page(validPage)},
+			failed: []string{"A"},
+			terms:  2,
+		},
+		{
+			name:    "no valid terms",
+			pages:   map[string]*string{"A": page(invalidPage), "B": page("<p>Nothing here.</p>")},
+			wantErr: "no valid terms were found in any of the 2 sources",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSources(t, tt.pages)
+			terms := store.NewMemoryStore()
+			failed, err := scrapeAndSave(context.Background(), terms, "2026-01-02_03-04-05")
+
+			if got := slices.Sorted(maps.Keys(failed)); !slices.Equal(got, tt.failed) {
+				t.Errorf("failed sources = %q, want %q", got, tt.failed)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("scrapeAndSave failed: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Fatalf("scrapeAndSave returned %v, want an error starting %q", err, tt.wantErr)
+			}
+			if terms.Len() != tt.terms {
+				t.Errorf("scraped %d terms, want %d", terms.Len(), tt.terms)
+			}
+
+			// Terms are only saved if there are any
+			saved, _ := filepath.Glob(filepath.Join(api.OutputDir, "cs_terms_*"))
+			if (len(saved) > 0) != (tt.terms > 0) {
+				t.Errorf("saved %q with %d terms scraped", saved, tt.terms)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is what a --config file can set, laid out as in
+// config.example.yaml. Each setting stands in for the flag named by its
+// flag tag, and can also be given in the environment variable named by
+// its env tag, or else after the flag. A flag given on the command line
+// wins over the environment, the environment over the file, and the file
+// over the flag's default. Settings left out of the file are nil.
+type Config struct {
+	Server   ServerSection   `yaml:"server,omitempty"`
+	Scraper  ScraperSection  `yaml:"scraper,omitempty"`
+	Sources  SourcesSection  `yaml:"sources,omitempty"`
+	Output   OutputSection   `yaml:"output,omitempty"`
+	Store    StoreSection    `yaml:"store,omitempty"`
+	Webhooks WebhooksSection `yaml:"webhooks,omitempty"`
+	Log      LogSection      `yaml:"log,omitempty"`
+}
+
+type ServerSection struct {
+	Addr              *string        `yaml:"addr,omitempty" flag:"addr"`
+	GRPCAddr          *string        `yaml:"grpc_addr,omitempty" flag:"grpc-addr"`
+	CORSOrigins       *[]string      `yaml:"cors_origins,omitempty" flag:"cors-origins"`
+	RateLimit         *float64       `yaml:"rate_limit,omitempty" flag:"rate-limit"`
+	RateBurst         *int           `yaml:"rate_burst,omitempty" flag:"rate-burst"`
+	TrustProxy        *bool          `yaml:"trust_proxy,omitempty" flag:"trust-proxy"`
+	TLSCert           *string        `yaml:"tls_cert,omitempty" flag:"tls-cert"`
+	TLSKey            *string        `yaml:"tls_key,omitempty" flag:"tls-key"`
+	TLSSelfSigned     *bool          `yaml:"tls_self_signed,omitempty" flag:"tls-self-signed"`
+	HTTPRedirectAddr  *string        `yaml:"http_redirect_addr,omitempty" flag:"http-redirect-addr"`
+	ShutdownGrace     *time.Duration `yaml:"shutdown_grace,omitempty" flag:"shutdown-grace"`
+	ReadHeaderTimeout *time.Duration `yaml:"read_header_timeout,omitempty" flag:"read-header-timeout"`
+	ReadTimeout       *time.Duration `yaml:"read_timeout,omitempty" flag:"read-timeout"`
+	WriteTimeout      *time.Duration `yaml:"write_timeout,omitempty" flag:"write-timeout"`
+	IdleTimeout       *time.Duration `yaml:"idle_timeout,omitempty" flag:"idle-timeout"`
+	RequestTimeout    *time.Duration `yaml:"request_timeout,omitempty" flag:"request-timeout"`
+	WriteAPIKey       *string        `yaml:"write_api_key,omitempty" flag:"write-api-key" secret:"true"`
+	RefreshInterval   *time.Duration `yaml:"refresh_interval,omitempty" flag:"refresh-interval"`
+	MaxSnapshotAge    *time.Duration `yaml:"max_snapshot_age,omitempty" flag:"max-snapshot-age"`
+	ForceScrape       *bool          `yaml:"force_scrape,omitempty" flag:"force-scrape"`
+	EnrichTimeout     *time.Duration `yaml:"enrich_timeout,omitempty" flag:"enrich-timeout"`
+	SearchStem        *bool          `yaml:"search_stem,omitempty" flag:"search-stem"`
+	QuizSecret        *string        `yaml:"quiz_secret,omitempty" flag:"quiz-secret" secret:"true"`
+}
+
+type ScraperSection struct {
+	Workers          *int           `yaml:"workers,omitempty" flag:"workers"`
+	HostDelay        *time.Duration `yaml:"host_delay,omitempty" flag:"host-delay"`
+	Retries          *int           `yaml:"retries,omitempty" flag:"scrape-retries"`
+	Timeout          *time.Duration `yaml:"timeout,omitempty" flag:"scrape-timeout"`
+	Backoff          *time.Duration `yaml:"backoff,omitempty" flag:"scrape-backoff"`
+	GlossaryDepth    *int           `yaml:"glossary_depth,omitempty" flag:"glossary-depth"`
+	MaxPages         *int           `yaml:"max_pages,omitempty" flag:"max-pages"`
+	MaxPageSize      *int           `yaml:"max_page_size,omitempty" flag:"max-page-size"`
+	RobotsStrict     *bool          `yaml:"robots_strict,omitempty" flag:"robots-strict"`
+	ASCIIPunctuation *bool          `yaml:"ascii_punctuation,omitempty" flag:"ascii-punctuation"`
+	MergeStrategy    *string        `yaml:"merge_strategy,omitempty" flag:"merge-strategy"`
+	SourcePriority   *[]string      `yaml:"source_priority,omitempty" flag:"source-priority"`
+	Proxy            *string        `yaml:"proxy,omitempty" flag:"proxy" secret:"url"`
+	UserAgent        *string        `yaml:"user_agent,omitempty" flag:"user-agent"`
+}
+
+type SourcesSection struct {
+	File    *string `yaml:"file,omitempty" flag:"sources" env:"SCRAPE_CP_SOURCES_FILE"`
+	Dir     *string `yaml:"dir,omitempty" flag:"sources-dir"`
+	Offline *bool   `yaml:"offline,omitempty" flag:"offline"`
+}
+
+type OutputSection struct {
+	Dir                  *string        `yaml:"dir,omitempty" flag:"output-dir"`
+	Formats              *[]string      `yaml:"formats,omitempty" flag:"output-format"`
+	Legacy               *bool          `yaml:"legacy,omitempty" flag:"legacy-output"`
+	KeepSnapshots        *int           `yaml:"keep_snapshots,omitempty" flag:"keep-snapshots"`
+	DeleteSnapshotsAfter *time.Duration `yaml:"delete_snapshots_after,omitempty" flag:"delete-snapshots-after"`
+	Markdown             *bool          `yaml:"markdown,omitempty" flag:"markdown"`
+	Anki                 *bool          `yaml:"anki,omitempty" flag:"anki"`
+}
+
+type StoreSection struct {
+	Backend  *string `yaml:"backend,omitempty" flag:"store"`
+	DB       *string `yaml:"db,omitempty" flag:"db"`
+	RedisURL *string `yaml:"redis_url,omitempty" flag:"redis-url" secret:"url"`
+}
+
+type WebhooksSection struct {
+	URLs   *[]string `yaml:"urls,omitempty" flag:"webhook-urls"`
+	Secret *string   `yaml:"secret,omitempty" flag:"webhook-secret" secret:"true"`
+}
+
+type LogSection struct {
+	Level  *string `yaml:"level,omitempty" flag:"log-level"`
+	Format *string `yaml:"format,omitempty" flag:"log-format"`
+}
+
+// setting is one field of a Config.
+type setting struct {
+	// key is where the setting goes in the file, such as server.addr
+	key  string
+	flag string
+	env  string
+	// secret is "true" for settings that must not be shown, and "url" for
+	// URLs whose password must not be
+	secret string
+	value  reflect.Value
+}
+
+// settings lists every setting in c, section by section.
+func (c *Config) settings() []setting {
+	var all []setting
+	sections := reflect.ValueOf(c).Elem()
+	for i := range sections.NumField() {
+		section := sections.Field(i)
+		prefix := yamlName(sections.Type().Field(i))
+		for j := range section.NumField() {
+			field := section.Type().Field(j)
+			all = append(all, setting{
+				key:    prefix + "." + yamlName(field),
+				flag:   field.Tag.Get("flag"),
+				env:    cmp.Or(field.Tag.Get("env"), envName(field.Tag.Get("flag"))),
+				secret: field.Tag.Get("secret"),
+				value:  section.Field(j),
+			})
+		}
+	}
+	return all
+}
+
+// envName is the environment variable for a flag: its name in capitals,
+// with underscores for dashes, after SCRAPE_CP_.
+func envName(flag string) string {
+	return "SCRAPE_CP_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// loadConfig reads a config file. Keys it doesn't know are an error, so a
+// misspelt setting isn't silently ignored.
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c Config
+	var doc yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&doc); errors.Is(err, io.EOF) {
+		return &c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := c.checkKeys(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := doc.Decode(&c); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// checkKeys fails on the first key in doc that isn't a section or a
+// setting within one.
+func (c *Config) checkKeys(doc *yaml.Node) error {
+	known := make(map[string]bool)
+	for _, s := range c.settings() {
+		section, _, _ := strings.Cut(s.key, ".")
+		known[section] = true
+		known[s.key] = true
+	}
+
+	var check func(n *yaml.Node, prefix string, depth int) error
+	check = func(n *yaml.Node, prefix string, depth int) error {
+		if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+			return check(n.Content[0], prefix, depth)
+		}
+		if n.Kind != yaml.MappingNode || depth > 1 {
+			return nil
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := prefix + n.Content[i].Value
+			if !known[key] {
+				return fmt.Errorf("line %d: unknown setting %s", n.Content[i].Line, key)
+			}
+			if err := check(n.Content[i+1], key+".", depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(doc, "", 0)
+}
+
+// apply sets each of fs's flags that c has a value for, unless origins
+// already has it, and records file as its origin. Settings for flags fs
+// doesn't have are left alone, as they belong to other commands.
+func (c *Config) apply(fs *flag.FlagSet, origins map[string]string, file string) error {
+	for _, s := range c.settings() {
+		if s.value.IsNil() || origins[s.flag] != "" || fs.Lookup(s.flag) == nil {
+			continue
+		}
+		if err := fs.Set(s.flag, formatSetting(s.value.Elem().Interface())); err != nil {
+			return fmt.Errorf("%s: %w", s.key, err)
+		}
+		origins[s.flag] = file
+	}
+	return nil
+}
+
+// applyEnv sets each of fs's flags whose environment variable is set,
+// unless origins already has it, and records the variable as its origin.
+// Lists are separated by commas and durations written as for flags, such
+// as 24h.
+func applyEnv(fs *flag.FlagSet, origins map[string]string) error {
+	for _, s := range (&Config{}).settings() {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || origins[s.flag] != "" || fs.Lookup(s.flag) == nil {
+			continue
+		}
+		if err := fs.Set(s.flag, value); err != nil {
+			return fmt.Errorf("%s=%q: %w", s.env, value, err)
+		}
+		origins[s.flag] = s.env
+	}
+	return nil
+}
+
+// formatSetting turns a setting's value into the form its flag parses.
+func formatSetting(v any) string {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// effectiveConfig reads back the value every setting ended up with from
+// fs's flags, with secrets blanked out.
+func effectiveConfig(fs *flag.FlagSet) (*Config, error) {
+	var c Config
+	for _, s := range c.settings() {
+		f := fs.Lookup(s.flag)
+		if f == nil {
+			continue
+		}
+		raw := redact(f.Value.String(), s.secret)
+		v, err := parseSetting(s.value.Type().Elem(), raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.key, err)
+		}
+		ptr := reflect.New(s.value.Type().Elem())
+		ptr.Elem().Set(reflect.ValueOf(v))
+		s.value.Set(ptr)
+	}
+	return &c, nil
+}
+
+// redact hides a secret setting's value, or only the password in it if
+// it's a URL.
+func redact(value, secret string) string {
+	switch {
+	case value == "" || secret == "":
+		return value
+	case secret == "url":
+		if u, err := url.Parse(value); err == nil {
+			return u.Redacted()
+		}
+	}
+	return "redacted"
+}
+
+// parseSetting parses a flag's value as the type of its setting.
+func parseSetting(t reflect.Type, raw string) (any, error) {
+	switch t {
+	case reflect.TypeFor[time.Duration]():
+		return time.ParseDuration(raw)
+	case reflect.TypeFor[[]string]():
+		return splitList(raw), nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int:
+		return strconv.Atoi(raw)
+	case reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	}
+	return raw, nil
+}
+
+// runConfig runs config validate, which checks the settings every flag
+// and --config give when running without a command, and prints them as a
+// config file, without running anything.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return errors.New("usage: config validate [flags]")
+	}
+
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	scraping := addScrapeFlags(fs)
+	output := addOutputFlags(fs)
+	addStoreFlags(fs)
+	serving := addServeFlags(fs)
+	addStartupFlags(fs)
+	if _, err := common.parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if err := scraping.apply(); err != nil {
+		return err
+	}
+	if err := output.check(); err != nil {
+		return err
+	}
+	if _, err := serving.config(); err != nil {
+		return err
+	}
+	if _, _, err := common.readSources(); err != nil {
+		return err
+	}
+
+	c, err := effectiveConfig(fs)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	return enc.Encode(c)
+}
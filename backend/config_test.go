@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseServeFlags parses args as runServe would, with config, if not
+// empty, written to a file given with --config.
+func parseServeFlags(t *testing.T, config string, args ...string) (*commonFlags, *serveFlags, error) {
+	t.Helper()
+	saved := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(saved) })
+
+	if config != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"--config=" + path}, args...)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	common := addCommonFlags(fs)
+	serving := addServeFlags(fs)
+	_, err := common.parseFlags(fs, args)
+	return common, serving, err
+}
+
+func TestSettingPrecedence(t *testing.T) {
+	const config = `
+server:
+  addr: ":7000"
+  rate_limit: 5
+  cors_origins: [https://a.example, https://b.example]
+  shutdown_grace: 3s
+`
+	tests := []struct {
+		name   string
+		config string
+		env    string
+		args   []string
+		want   string
+		origin string
+	}{
+		{"default", "", "", nil, ":8080", ""},
+		{"file over default", config, "", nil, ":7000", "config.yaml"},
+		{"environment over file", config, ":7100", nil, ":7100", "SCRAPE_CP_ADDR"},
+		{"flag over environment", config, ":7100", []string{"--addr=:7200"}, ":7200", "flag"},
+		{"flag over file", config, "", []string{"--addr", ":7200"}, ":7200", "flag"},
+		{"flag set to its default over file", config, "", []string{"--addr=:8080"}, ":8080", "flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("SCRAPE_CP_ADDR", tt.env)
+			}
+			common, serving, err := parseServeFlags(t, tt.config, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serving.addr != tt.want {
+				t.Errorf("addr = %q, want %q", serving.addr, tt.want)
+			}
+			// The file is recorded by its path
+			origin := common.origins["addr"]
+			if strings.HasSuffix(origin, ".yaml") {
+				origin = filepath.Base(origin)
+			}
+			if origin != tt.origin {
+				t.Errorf("addr came from %q, want %q", common.origins["addr"], tt.origin)
+			}
+
+			// The file's other settings apply whatever overrides addr
+			if tt.config == "" {
+				return
+			}
+			if serving.rateLimit != 5 || serving.corsOrigins != "https://a.example,https://b.example" || serving.shutdownGrace != 3*time.Second {
+				t.Errorf("rate limit %v, CORS origins %q and shutdown grace %v aren't the file's",
+					serving.rateLimit, serving.corsOrigins, serving.shutdownGrace)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  addr: \":7000\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SCRAPE_CP_CONFIG", path)
+
+	_, serving, err := parseServeFlags(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serving.addr != ":7000" {
+		t.Errorf("addr = %q, want :7000 from the file SCRAPE_CP_CONFIG names", serving.addr)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"server:\n  adr: \":7000\"\n", "line 2: unknown setting server.adr"},
+		{"servers:\n  addr: \":7000\"\n", "line 1: unknown setting servers"},
+		{"server:\n  rate_limit: fast\n", "line 2: cannot unmarshal"},
+		{"server:\n  shutdown_grace: soon\n", "line 2: cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseServeFlags(t, tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want it to mention %q", tt.config, err, tt.want)
+		}
+	}
+}
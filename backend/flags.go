@@ -0,0 +1,461 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"scrape_cp/api"
+	"scrape_cp/scraper"
+	"scrape_cp/store"
+)
+
+// The flags are grouped by what they configure, so each command only
+// registers the groups it uses.
+
+// commonFlags are accepted by every command.
+type commonFlags struct {
+	configFile  string
+	outputDir   string
+	sourcesFile string
+	sourcesDir  string
+	logLevel    slog.Level
+	logFormat   string
+
+	// origins says where each flag that was set got its value: "flag",
+	// an environment variable or the config file
+	origins map[string]string
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	c := &commonFlags{}
+	fs.StringVar(&c.configFile, "config", os.Getenv("SCRAPE_CP_CONFIG"), "YAML file of settings, laid out as in config.example.yaml; flags and environment variables take precedence over it")
+	fs.StringVar(&c.outputDir, "output-dir", api.OutputDir, "directory to save terms in and look for earlier snapshots in")
+	fs.StringVar(&c.sourcesFile, "sources", scraper.DefaultSourcesFile, "YAML file listing the sources to scrape; the built-in list is used if it doesn't exist")
+	fs.StringVar(&c.sourcesDir, "sources-dir", "", "directory of saved pages with a "+scraper.DefaultSourcesFile+" naming the scraper for each, used instead of --sources")
+	fs.BoolVar(&scraper.Offline, "offline", false, "scrape only saved pages, never the network; reads them from --sources-dir, "+scraper.DefaultPagesDir+" unless set")
+	fs.TextVar(&c.logLevel, "log-level", slog.LevelInfo, "least severe messages to log: debug, info, warn or error")
+	fs.StringVar(&c.logFormat, "log-format", "text", "how to write log messages: text, or json for log collectors")
+	return c
+}
+
+// parseArgs parses fs's flags wherever they come among args, so they can
+// follow the query, and returns the arguments that aren't flags.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseFlags parses args into fs, then fills in the flags they didn't set
+// from the environment, and the flags still unset from --config, if
+// given. It returns the arguments that aren't flags.
+func (c *commonFlags) parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := parseArgs(fs, args)
+	c.origins = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { c.origins[f.Name] = "flag" })
+
+	if err := applyEnv(fs, c.origins); err != nil {
+		return nil, err
+	}
+	if c.configFile != "" {
+		cfg, err := loadConfig(c.configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.apply(fs, c.origins, c.configFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.configFile, err)
+		}
+	}
+	if err := c.setUpLogging(); err != nil {
+		return nil, err
+	}
+	return positional, nil
+}
+
+// setUpLogging sends everything logged, through slog or the log package,
+// to standard error at --log-level and above, in --log-format.
+func (c *commonFlags) setUpLogging() error {
+	opts := &slog.HandlerOptions{Level: c.logLevel}
+	var handler slog.Handler
+	switch c.logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("--log-format must be text or json, not %q", c.logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logSettings logs every setting that was set and where, with secrets
+// hidden, so what took effect can be checked.
+func (c *commonFlags) logSettings(fs *flag.FlagSet) {
+	var set []any
+	for _, s := range (&Config{}).settings() {
+		f := fs.Lookup(s.flag)
+		origin, ok := c.origins[s.flag]
+		if f == nil || !ok {
+			continue
+		}
+		set = append(set, slog.String(s.key, fmt.Sprintf("%s (%s)", redact(f.Value.String(), s.secret), origin)))
+	}
+	if len(set) == 0 {
+		slog.Info("Settings are all defaults")
+		return
+	}
+	slog.Info("Settings", set...)
+}
+
+// openOutput creates the output directory and registers the snapshots
+// earlier runs left in it.
+func (c *commonFlags) openOutput() error {
+	if err := os.MkdirAll(c.outputDir, 0755); err != nil {
+		return err
+	}
+	api.OutputDir = c.outputDir
+	scraper.SetStateDir(c.outputDir)
+	if err := api.ScanSnapshots(c.outputDir); err != nil {
+		slog.Warn("Failed to list earlier snapshots", "dir", c.outputDir, "err", err)
+	}
+	return nil
+}
+
+// loadSources replaces the built-in sources with those listed in
+// --sources or --sources-dir, if there are any.
+func (c *commonFlags) loadSources() error {
+	loaded, ok, err := c.readSources()
+	if ok {
+		scraper.Sources = loaded
+		slog.Info("Loaded sources", "sources", len(scraper.Sources), "file", c.sourcesFile)
+	}
+	return err
+}
+
+// readSources reads the sources listed in --sources or --sources-dir,
+// reporting whether there is a list.
+func (c *commonFlags) readSources() ([]scraper.Source, bool, error) {
+	if scraper.Offline && c.sourcesDir == "" {
+		c.sourcesDir = scraper.DefaultPagesDir
+	}
+	if c.sourcesDir != "" {
+		c.sourcesFile = filepath.Join(c.sourcesDir, scraper.DefaultSourcesFile)
+	}
+	loaded, ok, err := scraper.LoadSources(c.sourcesFile)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case !ok && c.sourcesDir != "":
+		return nil, false, fmt.Errorf("%s has no %s listing its pages", c.sourcesDir, scraper.DefaultSourcesFile)
+	}
+	return loaded, ok, nil
+}
+
+// scrapeFlags configure how sources are scraped and merged, and who is
+// told when a scrape finishes.
+type scrapeFlags struct {
+	mergeName      string
+	sourcePriority string
+	webhookURLs    string
+	webhookSecret  string
+	proxy          string
+}
+
+func addScrapeFlags(fs *flag.FlagSet) *scrapeFlags {
+	s := &scrapeFlags{}
+	fs.StringVar(&s.webhookURLs, "webhook-urls", "",
+		"comma-separated URLs to POST a summary to after each scrape")
+	fs.StringVar(&s.webhookSecret, "webhook-secret", "",
+		"key to sign webhook payloads with; unsigned when empty")
+	fs.IntVar(&scraper.Retries, "scrape-retries", scraper.DefaultRetries, "times to retry fetching a source after a transient failure")
+	fs.DurationVar(&scraper.Timeout, "scrape-timeout", scraper.DefaultTimeout, "how long each request for a source may take")
+	fs.DurationVar(&scraper.Backoff, "scrape-backoff", scraper.DefaultBackoff, "wait before the first retry of a failed request, doubling after each one")
+	fs.StringVar(&s.mergeName, "merge-strategy", "", "how to settle definitions of the same term: "+strings.Join(store.MergeStrategies, ", ")+
+		" (default "+store.MergeKeepBoth+", or "+store.MergeLongest+" with --store=redis)")
+	fs.StringVar(&s.sourcePriority, "source-priority", "", "comma-separated source names, most trusted first, for the prefer-source merge strategy")
+	fs.BoolVar(&scraper.ASCIIPunctuation, "ascii-punctuation", false, "replace curly quotes, dashes and ellipses in scraped text with ASCII")
+	fs.IntVar(&scraper.GlossaryDepth, "glossary-depth", scraper.DefaultGlossaryDepth, "how many \"See also\" hops to follow between Wikipedia glossaries")
+	fs.IntVar(&scraper.MaxCrawlPages, "max-pages", scraper.DefaultMaxCrawlPages, "most pages to fetch from a source that spans several pages")
+	fs.IntVar(&scraper.MaxPageSize, "max-page-size", scraper.DefaultMaxPageSize, "most bytes to read from a page; a source with a larger one fails")
+	fs.IntVar(&scraper.Workers, "workers", scraper.DefaultWorkers, "number of sources to scrape at once")
+	fs.DurationVar(&scraper.HostDelay, "host-delay", scraper.DefaultHostDelay, "minimum time between requests to the same host while scraping")
+	fs.BoolVar(&scraper.RobotsStrict, "robots-strict", false, "skip sources whose robots.txt can't be fetched instead of assuming they allow scraping")
+	fs.StringVar(&s.proxy, "proxy", "", "proxy to scrape through, such as http://proxy:3128, instead of the one HTTP_PROXY, HTTPS_PROXY and NO_PROXY choose")
+	fs.StringVar(&scraper.UserAgent, "user-agent", scraper.DefaultUserAgent, "User-Agent header to send while scraping")
+	return s
+}
+
+// apply checks the scraper settings and sets up the proxy, merging and
+// webhooks.
+func (s *scrapeFlags) apply() error {
+	if scraper.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if scraper.Retries < 0 {
+		return errors.New("--scrape-retries must not be negative")
+	}
+	if scraper.Timeout <= 0 {
+		return errors.New("--scrape-timeout must be positive")
+	}
+	if scraper.Backoff <= 0 {
+		return errors.New("--scrape-backoff must be positive")
+	}
+	if scraper.GlossaryDepth < 0 {
+		return errors.New("--glossary-depth must not be negative")
+	}
+	if scraper.MaxCrawlPages < 1 {
+		return errors.New("--max-pages must be at least 1")
+	}
+	if scraper.MaxPageSize < 1 {
+		return errors.New("--max-page-size must be at least 1")
+	}
+	if scraper.UserAgent == "" {
+		scraper.UserAgent = scraper.DefaultUserAgent
+	}
+	if s.proxy != "" {
+		proxy, err := url.Parse(s.proxy)
+		if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+			return fmt.Errorf("--proxy must be a URL such as http://proxy:3128, not %q", s.proxy)
+		}
+		scraper.Proxy = proxy
+	}
+	// Redis settles definitions written by several replicas at once by
+	// keeping the longest, so no other strategy would hold
+	mergeName := s.mergeName
+	if api.StoreName == store.BackendRedis {
+		if mergeName != "" && mergeName != store.MergeLongest {
+			return fmt.Errorf("--store=%s only supports --merge-strategy=%s", store.BackendRedis, store.MergeLongest)
+		}
+		mergeName = store.MergeLongest
+	}
+	strategy, err := store.MergeStrategyNamed(cmp.Or(mergeName, store.MergeKeepBoth), splitList(s.sourcePriority))
+	if err != nil {
+		return err
+	}
+	store.MergeStrategy = strategy
+	api.SetWebhooks(splitList(s.webhookURLs), []byte(s.webhookSecret))
+	return nil
+}
+
+// outputFlags configure the files terms are saved to.
+type outputFlags struct {
+	markdown bool
+	anki     bool
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{}
+	fs.Var(outputFormatsValue{}, "output-format", "comma-separated formats to save terms in after each scrape: "+strings.Join(api.OutputFormatNames, ", ")+
+		"; snapshots and cs_terms_latest.json need json (default json)")
+	fs.BoolVar(&api.LegacyOutput, "legacy-output", false, "also save terms in the old flat term-to-definition JSON format")
+	fs.IntVar(&api.KeepSnapshots, "keep-snapshots", api.KeepSnapshots, "delete all but this many of the newest snapshots in the output directory after each save; 0 keeps them all")
+	fs.DurationVar(&api.DeleteSnapshotsAfter, "delete-snapshots-after", 0, "delete snapshots in the output directory older than this after each save; 0 keeps them however old")
+	fs.BoolVar(&o.markdown, "markdown", false, "also write a Markdown glossary next to the JSON output")
+	fs.BoolVar(&o.anki, "anki", false, "also write an Anki flashcard deck next to the JSON output")
+	return o
+}
+
+// outputFormatsValue is the --output-format flag, which sets the formats
+// as soon as it's parsed.
+type outputFormatsValue struct{}
+
+func (outputFormatsValue) String() string {
+	return strings.Join(api.OutputFormats(), ",")
+}
+
+func (outputFormatsValue) Set(value string) error {
+	return api.SetOutputFormats(splitList(value))
+}
+
+func (o *outputFlags) check() error {
+	if api.KeepSnapshots < 0 {
+		return errors.New("--keep-snapshots must not be negative")
+	}
+	if api.DeleteSnapshotsAfter < 0 {
+		return errors.New("--delete-snapshots-after must not be negative")
+	}
+	return nil
+}
+
+// writeExtras writes the Markdown glossary and Anki deck, if they were
+// asked for, under timestamp.
+func (o *outputFlags) writeExtras(timestamp string, terms *store.MemoryStore) error {
+	if o.markdown {
+		mdFilename := filepath.Join(api.OutputDir, fmt.Sprintf("cs_terms_%s.md", timestamp))
+		if err := api.ExportFile(mdFilename, "markdown", terms); err != nil {
+			return fmt.Errorf("writing Markdown glossary: %w", err)
+		}
+		slog.Info("Saved Markdown glossary", "file", mdFilename)
+	}
+
+	if o.anki {
+		ankiFilename := filepath.Join(api.OutputDir, fmt.Sprintf("cs_terms_%s_anki.txt", timestamp))
+		if err := api.ExportFile(ankiFilename, "anki", terms); err != nil {
+			return fmt.Errorf("writing Anki deck: %w", err)
+		}
+		slog.Info("Saved Anki deck", "file", ankiFilename)
+	}
+	return nil
+}
+
+// storeFlags choose where the terms are kept between runs.
+type storeFlags struct {
+	dbFile   string
+	redisURL string
+}
+
+func addStoreFlags(fs *flag.FlagSet) *storeFlags {
+	s := &storeFlags{}
+	fs.StringVar(&api.StoreName, "store", api.StoreName, "where to keep the terms between runs: "+strings.Join(store.Backends, ", "))
+	fs.StringVar(&s.dbFile, "db", store.DefaultDBFile, "database file for --store=sqlite or bolt")
+	fs.StringVar(&s.redisURL, "redis-url", store.DefaultRedisURL, "Redis server for --store=redis")
+	return s
+}
+
+// attach opens the chosen backend and loads what it kept into terms. It
+// returns nil when the terms are only kept in memory.
+func (s *storeFlags) attach(terms *store.MemoryStore) (store.Backend, error) {
+	backend, err := store.Open(api.StoreName, s.dbFile, s.redisURL)
+	if err != nil || backend == nil {
+		return nil, err
+	}
+	if err := terms.Attach(backend); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// origin describes the backend for SetOrigin.
+func (s *storeFlags) origin() string {
+	if api.StoreName == store.BackendRedis {
+		return api.OriginStore + "Redis"
+	}
+	return api.OriginStore + s.dbFile
+}
+
+// startupFlags choose between scraping and serving saved terms when
+// running without a command.
+type startupFlags struct {
+	maxSnapshotAge time.Duration
+	forceScrape    bool
+}
+
+func addStartupFlags(fs *flag.FlagSet) *startupFlags {
+	s := &startupFlags{}
+	fs.DurationVar(&s.maxSnapshotAge, "max-snapshot-age", 24*time.Hour, "serve the terms kept by --store, or else the newest saved snapshot, at startup instead of scraping if they are younger than this; 0 always scrapes")
+	fs.BoolVar(&s.forceScrape, "force-scrape", false, "scrape at startup even if a recent snapshot could be loaded")
+	return s
+}
+
+// serveFlags configure the HTTP and gRPC servers.
+type serveFlags struct {
+	addr          string
+	grpcAddr      string
+	corsOrigins   string
+	rateLimit     float64
+	rateBurst     int
+	trustProxy    bool
+	tlsCert       string
+	tlsKey        string
+	tlsSelfSigned bool
+	redirectAddr  string
+	shutdownGrace time.Duration
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+}
+
+func addServeFlags(fs *flag.FlagSet) *serveFlags {
+	s := &serveFlags{}
+	fs.StringVar(&s.addr, "addr", ":8080", "address for the API server to listen on")
+	fs.DurationVar(&api.RefreshInterval, "refresh-interval", 0, "how often to scrape every source again while serving, such as 24h; 0 scrapes once at startup")
+	fs.StringVar(&s.grpcAddr, "grpc-addr", "",
+		"address to serve the gRPC API on; not served when empty")
+	fs.StringVar(&api.WriteAPIKey, "write-api-key", "",
+		"key clients must send as a bearer token to import terms or start a refresh; both are disabled without one")
+	fs.StringVar(&s.corsOrigins, "cors-origins", "",
+		"comma-separated origins allowed to call the API from a browser, or * for any")
+	fs.Float64Var(&s.rateLimit, "rate-limit", 10, "requests per second allowed per client IP, 0 to disable")
+	fs.IntVar(&s.rateBurst, "rate-burst", 20, "requests a client IP may burst above the rate limit")
+	fs.BoolVar(&s.trustProxy, "trust-proxy", false, "take client IPs from X-Forwarded-For when behind a reverse proxy")
+	fs.StringVar(&s.tlsCert, "tls-cert", "", "certificate file to serve HTTPS with; requires --tls-key")
+	fs.StringVar(&s.tlsKey, "tls-key", "", "private key file for --tls-cert")
+	fs.BoolVar(&s.tlsSelfSigned, "tls-self-signed", false, "serve HTTPS with a generated self-signed certificate, for local development")
+	fs.StringVar(&s.redirectAddr, "http-redirect-addr", "", "when serving HTTPS, also listen here and redirect plain HTTP to it")
+	fs.DurationVar(&s.shutdownGrace, "shutdown-grace", 10*time.Second, "how long to wait for in-flight requests when shutting down")
+	fs.DurationVar(&s.readHeaderTimeout, "read-header-timeout", 10*time.Second, "how long a client may take to send a request's headers; 0 for no limit")
+	fs.DurationVar(&s.readTimeout, "read-timeout", 30*time.Second, "how long a client may take to send a whole request; 0 for no limit")
+	fs.DurationVar(&s.writeTimeout, "write-timeout", 60*time.Second, "how long answering a request may take, streams and exports aside; 0 for no limit")
+	fs.DurationVar(&s.idleTimeout, "idle-timeout", 120*time.Second, "how long an idle keep-alive connection is kept open; 0 for no limit")
+	fs.DurationVar(&api.RequestTimeout, "request-timeout", api.DefaultRequestTimeout, "how long a request may take before it is cut off with a 503, streams and exports aside; 0 for no limit")
+	fs.DurationVar(&scraper.EnrichTimeout, "enrich-timeout", scraper.DefaultEnrichTimeout, "how long each request to Wikipedia's summary API for ?enrich=true may take")
+	fs.BoolVar(&api.StemSearches, "search-stem", false, "match search words by their stem unless a request sets ?stem")
+	fs.StringVar(&api.QuizSecret, "quiz-secret", "",
+		"key quiz answer tokens are signed with, so they keep working across restarts and instances; a random one is used when empty")
+	return s
+}
+
+// config checks the server settings and returns them for StartServer.
+func (s *serveFlags) config() (api.ServerConfig, error) {
+	if api.RefreshInterval < 0 {
+		return api.ServerConfig{}, errors.New("--refresh-interval must not be negative")
+	}
+	if scraper.EnrichTimeout <= 0 {
+		return api.ServerConfig{}, errors.New("--enrich-timeout must be positive")
+	}
+	timeouts := []struct {
+		flag string
+		d    time.Duration
+	}{
+		{"read-header-timeout", s.readHeaderTimeout},
+		{"read-timeout", s.readTimeout},
+		{"write-timeout", s.writeTimeout},
+		{"idle-timeout", s.idleTimeout},
+		{"request-timeout", api.RequestTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			return api.ServerConfig{}, fmt.Errorf("--%s must not be negative", t.flag)
+		}
+	}
+	// The timeout's 503 has to be written before the connection's write
+	// deadline passes
+	if s.writeTimeout > 0 && (api.RequestTimeout == 0 || api.RequestTimeout >= s.writeTimeout) {
+		return api.ServerConfig{}, errors.New("--request-timeout must be set and shorter than --write-timeout unless --write-timeout is 0")
+	}
+	cfg := api.ServerConfig{
+		Addr:          s.addr,
+		CORSOrigins:   splitList(s.corsOrigins),
+		RateLimit:     s.rateLimit,
+		RateBurst:     s.rateBurst,
+		TrustProxy:    s.trustProxy,
+		TLSCert:       s.tlsCert,
+		TLSKey:        s.tlsKey,
+		TLSSelfSigned: s.tlsSelfSigned,
+		RedirectAddr:  s.redirectAddr,
+
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		ReadTimeout:       s.readTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+	}
+	return cfg, cfg.CheckTLS()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"scrape_cp/api"
+	"scrape_cp/scraper"
+	"scrape_cp/store"
+)
+
+func TestRedisMergeStrategy(t *testing.T) {
+	savedStore, savedStrategy := api.StoreName, store.MergeStrategy
+	t.Cleanup(func() { api.StoreName, store.MergeStrategy = savedStore, savedStrategy })
+
+	longest, _ := store.MergeStrategyNamed(store.MergeLongest, nil)
+	keepBoth, _ := store.MergeStrategyNamed(store.MergeKeepBoth, nil)
+	tests := []struct {
+		args    []string
+		want    store.MergeFunc
+		wantErr string
+	}{
+		{nil, keepBoth, ""},
+		{[]string{"--store=redis"}, longest, ""},
+		{[]string{"--store=redis", "--merge-strategy=longest"}, longest, ""},
+		{[]string{"--store=redis", "--merge-strategy=keep-both"}, nil, "--store=redis only supports --merge-strategy=longest"},
+		{[]string{"--store=redis", "--merge-strategy=first-wins"}, nil, "--store=redis only supports --merge-strategy=longest"},
+		{[]string{"--store=sqlite", "--merge-strategy=first-wins"}, nil, ""},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		scraping := addScrapeFlags(fs)
+		addStoreFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := scraping.apply()
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want %q", tt.args, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.args, err)
+		case tt.want != nil && reflect.ValueOf(store.MergeStrategy).Pointer() != reflect.ValueOf(tt.want).Pointer():
+			t.Errorf("%q chose the wrong merge strategy", tt.args)
+		}
+	}
+}
+
+func TestProxyAndUserAgentFlags(t *testing.T) {
+	savedProxy, savedAgent, savedStrategy := scraper.Proxy, scraper.UserAgent, store.MergeStrategy
+	t.Cleanup(func() { scraper.Proxy, scraper.UserAgent, store.MergeStrategy = savedProxy, savedAgent, savedStrategy })
+
+	tests := []struct {
+		args      []string
+		wantProxy string
+		wantAgent string
+		wantErr   string
+	}{
+		{nil, "", scraper.DefaultUserAgent, ""},
+		{[]string{"--proxy=http://proxy.internal:3128", "--user-agent=glossary-bot/2"}, "http://proxy.internal:3128", "glossary-bot/2", ""},
+		{[]string{"--user-agent="}, "", scraper.DefaultUserAgent, ""},
+		{[]string{"--proxy=proxy.internal:3128"}, "", "", "--proxy must be a URL"},
+		{[]string{"--proxy=http://"}, "", "", "--proxy must be a URL"},
+	}
+	for _, tt := range tests {
+		scraper.Proxy = nil
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		scraping := addScrapeFlags(fs)
+		addStoreFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := scraping.apply()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		var proxy string
+		if scraper.Proxy != nil {
+			proxy = scraper.Proxy.String()
+		}
+		if proxy != tt.wantProxy || scraper.UserAgent != tt.wantAgent {
+			t.Errorf("%q: proxy %q and User-Agent %q, want %q and %q", tt.args, proxy, scraper.UserAgent, tt.wantProxy, tt.wantAgent)
+		}
+	}
+	if !strings.HasPrefix(scraper.DefaultUserAgent, "scrape_cp/") {
+		t.Errorf("default User-Agent %q doesn't name the scraper", scraper.DefaultUserAgent)
+	}
+}
@@ -0,0 +1,89 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// files returns the names of the files in dir.
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "terms.json")
+	err := Write(filename, func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"Cache":"Fast memory."}`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil || string(data) != `{"Cache":"Fast memory."}` {
+		t.Errorf("read back %q, %v", data, err)
+	}
+	if got := files(t, dir); len(got) != 1 {
+		t.Errorf("files left behind: %q", got)
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	errDiskFull := errors.New("no space left on device")
+	failHalfway := func(w io.Writer) error {
+		io.WriteString(w, `{"Cache":"Fast`)
+		return errDiskFull
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "terms.json")
+		if err := Write(filename, failHalfway); !errors.Is(err, errDiskFull) {
+			t.Fatalf("Write returned %v, want the write's error", err)
+		}
+		if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("a partial file exists under the final name: %v", err)
+		}
+		if got := files(t, dir); len(got) != 0 {
+			t.Errorf("files left behind: %q", got)
+		}
+	})
+
+	t.Run("existing file", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "terms.json")
+		if err := os.WriteFile(filename, []byte(`{"Heap":"A tree."}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(filename, failHalfway); !errors.Is(err, errDiskFull) {
+			t.Fatalf("Write returned %v, want the write's error", err)
+		}
+		if data, _ := os.ReadFile(filename); string(data) != `{"Heap":"A tree."}` {
+			t.Errorf("the old file was replaced by %q", data)
+		}
+		if got := files(t, dir); len(got) != 1 {
+			t.Errorf("files left behind: %q", got)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "missing", "terms.json")
+		if err := Write(filename, failHalfway); err == nil {
+			t.Fatal("Write succeeded without a directory to write to")
+		}
+	})
+}
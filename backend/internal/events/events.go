@@ -0,0 +1,85 @@
+// Package events fans events out to any number of subscribers.
+package events
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+// before it is dropped. A source's terms are merged in one go, so this has
+// to absorb a whole source's worth.
+const subscriberBuffer = 4096
+
+// Subscriber receives events on C until it unsubscribes or is dropped, at
+// which point C is closed.
+type Subscriber[T any] struct {
+	C chan T
+
+	// Dropped is set when the subscriber was closed for falling behind
+	// rather than by unsubscribing or shutdown. Read it after C is closed.
+	Dropped bool
+}
+
+// Hub fans events out to subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is dropped so that a slow client can't
+// hold up the scraper.
+type Hub[T any] struct {
+	mu     sync.Mutex
+	subs   map[*Subscriber[T]]struct{}
+	closed bool
+}
+
+// NewHub returns a hub with no subscribers.
+func NewHub[T any]() *Hub[T] {
+	return &Hub[T]{subs: make(map[*Subscriber[T]]struct{})}
+}
+
+// Subscribe registers a new subscriber. Once the hub is closed the
+// subscriber comes back with C already closed.
+func (h *Hub[T]) Subscribe() *Subscriber[T] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscriber[T]{C: make(chan T, subscriberBuffer)}
+	if h.closed {
+		close(sub.C)
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub[T]) Unsubscribe(sub *Subscriber[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.C)
+	}
+}
+
+func (h *Hub[T]) Publish(event T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.C <- event:
+		default:
+			sub.Dropped = true
+			delete(h.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// Close ends every subscription and turns away new ones, for shutdown.
+func (h *Hub[T]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.C)
+	}
+}
@@ -1,297 +1,89 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
-	"sync"
-	"time"
-	"unicode"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/gorilla/mux"
-)
-
-var (
-	globalTerms = make(map[string]string)
-	mutex       sync.Mutex
+	"syscall"
 )
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-type TermResponse struct {
-	Term       string `json:"term"`
-	Definition string `json:"definition"`
+// command is a subcommand of the binary. run is given the arguments after
+// the command's name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
 }
 
-type SearchResponse struct {
-	Terms    []TermResponse `json:"terms"`
-	Count    int            `json:"count"`
-	Query    string         `json:"query,omitempty"`
-	TimeTook string         `json:"time_took"`
+// commands run one part of what the binary does. Without one it does all
+// of them, the way it did before it had commands.
+var commands = []command{
+	{"scrape", "scrape every source, save the terms and exit", runScrape},
+	{"serve", "serve the saved terms, scraping first only if asked to", runServe},
+	{"export", "convert a saved snapshot to another format without scraping", runExport},
+	{"generate-site", "render a saved snapshot as a static HTML glossary site", runGenerateSite},
+	{"lookup", "print a term from the newest saved snapshot", runLookup},
+	{"search", "print the terms in the newest saved snapshot matching a query", runSearch},
+	{"config", "check the settings from flags and --config and print them, with config validate", runConfig},
 }
 
-var sources = []struct {
-	URL        string
-	Name       string
-	ScrapeFunc func(*goquery.Document) map[string]string
-}{
-	{
-		URL:        "https://www.coursera.org/collections/computer-science-terms",
-		Name:       "Coursera",
-		ScrapeFunc: scrapeCourseraTerms,
-	},
-	{
-		URL:        "https://en.wikipedia.org/wiki/Glossary_of_computer_science",
-		Name:       "Wikipedia",
-		ScrapeFunc: scrapeWikipediaTerms,
-	},
-}
-
-func cleanText(text string) string {
-	text = strings.Join(strings.Fields(text), " ")
-	return strings.Map(func(r rune) rune {
-		if unicode.IsPrint(r) {
-			return r
+func main() {
+	run := runAll
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		i := slices.IndexFunc(commands, func(c command) bool { return c.name == args[0] })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+			usage()
+			os.Exit(2)
 		}
-		return -1
-	}, text)
-}
-
-func isValidTerm(term, definition string) bool {
-	if len(term) < 2 || len(definition) < 10 {
-		return false
-	}
-
-	termForComparison := term
-	if i := strings.Index(term, " ("); i != -1 {
-		termForComparison = term[:i]
+		run, args = commands[i].run, args[1:]
 	}
 
-	if strings.Contains(strings.ToLower(definition), strings.ToLower(termForComparison)) &&
-		len(definition) < len(termForComparison)+20 {
-		return false
+	if err := run(args); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
 	}
-
-	return true
-}
-
-// funtions to scrape terms from different sources
-func scrapeWikipediaTerms(doc *goquery.Document) map[string]string {
-	terms := make(map[string]string)
-
-	doc.Find("dl.glossary").Each(func(i int, dlElement *goquery.Selection) {
-		var currentTerm string
-
-		dlElement.Children().Each(func(j int, element *goquery.Selection) {
-			if element.Is("dt") {
-				currentTerm = cleanText(element.Text())
-				currentTerm = strings.Split(currentTerm, "[")[0]
-				currentTerm = strings.TrimSpace(currentTerm)
-			} else if element.Is("dd") && currentTerm != "" {
-				definition := cleanText(element.Text())
-
-				definition = strings.Map(func(r rune) rune {
-					if r == '[' || r == ']' {
-						return -1
-					}
-					return r
-				}, definition)
-
-				definition = strings.Split(definition, "[")[0]
-				definition = strings.TrimSpace(definition)
-
-				if isValidTerm(currentTerm, definition) {
-					terms[currentTerm] = definition
-				}
-			}
-		})
-	})
-
-	return terms
 }
 
-func scrapeCourseraTerms(doc *goquery.Document) map[string]string {
-	terms := make(map[string]string)
-
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		if strong := s.Find("strong"); strong.Length() > 0 {
-			term := cleanText(strong.Text())
-			if nextP := s.Next(); nextP.Length() > 0 {
-				definition := cleanText(nextP.Text())
-				if isValidTerm(term, definition) {
-					terms[term] = definition
-				}
-			}
-		}
-	})
-
-	return terms
-}
-
-// URL scraping function with error handling and retries
-func scrapeURL(url string, scrapeFunc func(*goquery.Document) map[string]string, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Error creating request for %s: %v", url, err)
-		return
-	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to fetch %s: %v", url, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Bad status code %d from %s", resp.StatusCode, url)
-		return
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		log.Printf("Failed to parse HTML from %s: %v", url, err)
-		return
+// usage lists the commands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-13s %s\n", c.name, c.summary)
 	}
-
-	terms := scrapeFunc(doc)
-
-	mutex.Lock()
-	for term, def := range terms {
-		if existing, exists := globalTerms[term]; !exists ||
-			len(def) > len(existing) {
-			globalTerms[term] = def
-		}
-	}
-	mutex.Unlock()
+	fmt.Fprintln(out, "\nWithout a command, terms are scraped or loaded, saved and served. Run a\ncommand with -h for its flags.")
 }
 
-func getAllTerms(w http.ResponseWriter, r *http.Request) {
-	mutex.Lock()
-	terms := make(map[string]string)
-	// Create a copy of the map to avoid holding the lock while encoding
-	for k, v := range globalTerms {
-		terms[k] = v
-	}
-	mutex.Unlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(terms)
-}
-
-func getTerm(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	term := vars["term"]
-
-	mutex.Lock()
-	definition, exists := globalTerms[term]
-	mutex.Unlock()
+// handleSignals cancels the running scrape and server on the first SIGINT
+// or SIGTERM, and exits straight away on a second one in case a graceful
+// shutdown hangs.
+func handleSignals(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	if !exists {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "term not found"})
-		return
-	}
+	sig := <-signals
+	slog.Info("Shutting down", "signal", sig.String())
+	cancel()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{term: definition})
+	<-signals
+	slog.Warn("Received second signal, exiting immediately")
+	os.Exit(1)
 }
 
-func searchTerms(w http.ResponseWriter, r *http.Request) {
-	query := strings.ToLower(r.URL.Query().Get("q"))
-
-	if query == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "search query is required"})
-		return
-	}
-
-	mutex.Lock()
-	results := make(map[string]string)
-	for term, def := range globalTerms {
-		if strings.Contains(strings.ToLower(term), query) ||
-			strings.Contains(strings.ToLower(def), query) {
-			results[term] = def
+// splitList parses a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	mutex.Unlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
-}
-
-func startAPIServer() {
-	router := mux.NewRouter()
-
-	// API endpoints with /api prefix for better organization
-	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/terms", getAllTerms).Methods("GET")
-	api.HandleFunc("/terms/search", searchTerms).Methods("GET")
-	api.HandleFunc("/terms/{term}", getTerm).Methods("GET")
-
-	// Add simple request logging
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
-		})
-	})
-
-	fmt.Println("API server is running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
-}
-
-func main() {
-	var wg sync.WaitGroup
-
-	// Create output directory
-	os.MkdirAll("output", 0755)
-
-	// Scrape data from sources
-	for _, source := range sources {
-		wg.Add(1)
-		go scrapeURL(source.URL, source.ScrapeFunc, &wg)
-	}
-
-	wg.Wait()
-
-	if len(globalTerms) == 0 {
-		log.Fatal("No terms were found from any source")
-	}
-
-	// Save to JSON file
-	jsonData, err := json.MarshalIndent(globalTerms, "", "    ")
-	if err != nil {
-		log.Fatal("Failed to convert to JSON:", err)
-	}
-
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("output/cs_terms_%s.json", timestamp)
-
-	err = os.WriteFile(filename, jsonData, 0644)
-	if err != nil {
-		log.Fatal("Failed to write file:", err)
-	}
-
-	fmt.Printf("Successfully scraped %d unique terms and saved to %s\n", len(globalTerms), filename)
-
-	// Start the API server
-	startAPIServer()
+	return items
 }
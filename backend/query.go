@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"scrape_cp/api"
+	"scrape_cp/store"
+)
+
+// queryFlags choose the snapshot the query commands read and how they
+// print what they find.
+type queryFlags struct {
+	in     string
+	asJSON bool
+}
+
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	q := &queryFlags{}
+	fs.StringVar(&q.in, "in", "", "snapshot to read; the newest in the output directory if empty")
+	fs.BoolVar(&q.asJSON, "json", false, "print JSON instead of text")
+	return q
+}
+
+// loadTerms reads the snapshot in file, or the newest one in dir if file
+// is empty, into a store. It returns the store and the file read.
+func loadTerms(dir, file string) (*store.MemoryStore, string, error) {
+	if file == "" {
+		if err := api.ScanSnapshots(dir); err != nil {
+			return nil, "", fmt.Errorf("listing snapshots: %w", err)
+		}
+		latest, ok := api.LatestSnapshot()
+		if !ok {
+			return nil, "", fmt.Errorf("no snapshots in %s; give one with --in", dir)
+		}
+		file = latest
+	}
+
+	loaded, err := api.LoadSnapshot(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", file, err)
+	}
+	terms := store.NewMemoryStore()
+	terms.Upsert(loaded)
+	return terms, file, nil
+}
+
+// runLookup prints a term from a saved snapshot, failing if there is no
+// such term.
+func runLookup(args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	query := addQueryFlags(fs)
+	positional, err := common.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	name := strings.Join(positional, " ")
+	if name == "" {
+		return errors.New("usage: lookup [flags] <term>")
+	}
+
+	terms, _, err := loadTerms(common.outputDir, query.in)
+	if err != nil {
+		return err
+	}
+	t, ok := terms.Get(name)
+	if !ok {
+		return fmt.Errorf("no term %q", name)
+	}
+
+	term := t.Detail()
+	if query.asJSON {
+		return printJSON(os.Stdout, term)
+	}
+	printTerm(os.Stdout, term)
+	return nil
+}
+
+// runSearch prints the terms in a saved snapshot matching a query, ranked
+// the same way as GET /api/v1/terms/search.
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	query := addQueryFlags(fs)
+	fieldNames := fs.String("fields", "", "fields to search: name, definition or both (default both)")
+	exact := fs.Bool("exact", false, "match whole words instead of any substring")
+	opName := fs.String("op", "and", "and to match terms containing every word or quoted phrase of the query, or to match any of them")
+	stem := fs.Bool("stem", false, "also match words with the same stem as a word of the query")
+	limit := fs.Int("limit", 10, "most results to print")
+	positional, err := common.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	q := strings.Join(positional, " ")
+	if q == "" {
+		return errors.New("usage: search [flags] <query>")
+	}
+	if *limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+	fields, err := store.ParseSearchFields(*fieldNames)
+	if err != nil {
+		return err
+	}
+	op, err := store.ParseSearchOp(*opName)
+	if err != nil {
+		return err
+	}
+	opts := store.SearchOptions{Fields: fields, Exact: *exact, Op: op, Stem: *stem}
+	if _, err := store.NewMatcher(q, opts); err != nil {
+		return err
+	}
+
+	terms, _, err := loadTerms(common.outputDir, query.in)
+	if err != nil {
+		return err
+	}
+	results, err := terms.Search(context.Background(), q, opts)
+	if err != nil {
+		return err
+	}
+	results = results[:min(*limit, len(results))]
+
+	if query.asJSON {
+		return printJSON(os.Stdout, results)
+	}
+	if len(results) == 0 {
+		fmt.Printf("No terms match %q\n", q)
+		return nil
+	}
+	for i, t := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		printTerm(os.Stdout, t)
+	}
+	return nil
+}
+
+// printTerm writes a term as text: its name, then each definition with
+// where it came from.
+func printTerm(w io.Writer, t store.TermResponse) {
+	fmt.Fprintln(w, t.Term)
+	if len(t.Aliases) > 0 {
+		fmt.Fprintf(w, "  also: %s\n", strings.Join(t.Aliases, ", "))
+	}
+	senses := t.Definitions
+	if len(senses) == 0 {
+		senses = []store.Sense{{Text: t.Definition, Source: t.Source}}
+	}
+	for _, sense := range senses {
+		fmt.Fprintf(w, "  %s", sense.Text)
+		if sense.Source != "" {
+			fmt.Fprintf(w, " (%s)", sense.Source)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,173 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultMaxCrawlPages = 50
+
+// MaxCrawlPages caps how many pages one multi-page source may fetch.
+var MaxCrawlPages = DefaultMaxCrawlPages
+
+// crawlFunc parses one page of a multi-page source, returning the terms on
+// it and the further pages to fetch. base is the page's own URL, for
+// resolving links.
+type crawlFunc func(doc *goquery.Document, base *url.URL) crawlResult
+
+type crawlResult struct {
+	terms  Extracted
+	follow []crawlLink
+}
+
+// crawlLink is a page still to be fetched and how to parse it.
+type crawlLink struct {
+	url   string
+	parse crawlFunc
+}
+
+// crawledPage is the outcome of fetching and parsing one crawlLink, and
+// how long each took.
+type crawledPage struct {
+	link     crawlLink
+	bytes    int
+	result   crawlResult
+	err      error
+	fetching time.Duration
+	parsing  time.Duration
+}
+
+// crawlSource scrapes a source that spans several pages, starting from its
+// URL and following the links each page yields, until there are none left,
+// MaxCrawlPages have been fetched or ctx is done. Pages of any of sources
+// are left to them, so their terms keep that source's name and category.
+// Each round of links is fetched side by side, each page in one of slots,
+// which the other sources being scraped share; requests still go through
+// the robots, per-host delay and retry handling of fetchPage. It returns every term found, or the error if the first page
+// failed; later pages that fail are skipped. The total size of the pages
+// is passed to fetchedBytes once they are all fetched.
+func crawlSource(ctx context.Context, src Source, sources []Source, slots fetchSlots, fetchedBytes func(int)) (Result, error) {
+	result := Result{Terms: make(map[string][]string)}
+	visited := map[string]bool{src.URL: true}
+	for _, other := range sources {
+		visited[other.URL] = true
+	}
+	queue := []crawlLink{{url: src.URL, parse: src.Crawl}}
+	total := 0
+
+	for len(queue) > 0 && result.Attempts < MaxCrawlPages && ctx.Err() == nil {
+		batch := queue[:min(len(queue), MaxCrawlPages-result.Attempts)]
+		queue = queue[len(batch):]
+
+		for _, page := range crawlBatch(ctx, src, batch, slots) {
+			result.Attempts++
+			if page.err != nil {
+				if page.link.url == src.URL {
+					slog.Error("Failed to fetch source", "source", src.Name, "url", src.URL, "err", page.err)
+					return result, page.err
+				}
+				slog.Warn("Skipping page", "source", src.Name, "url", page.link.url, "err", page.err)
+				continue
+			}
+
+			total += page.bytes
+			result.Pages = append(result.Pages, page.link.url)
+			result.Fetching += page.fetching
+			result.Parsing += page.parsing
+			result.Found += page.result.terms.Found
+			result.Rejected += page.result.terms.Rejected
+			for term, defs := range page.result.terms.Terms {
+				result.Terms[term] = append(result.Terms[term], defs...)
+			}
+			for _, next := range page.result.follow {
+				if !visited[next.url] {
+					visited[next.url] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	fetchedBytes(total)
+	return result, nil
+}
+
+// crawlBatch fetches and parses links of src concurrently, as many at once
+// as there are free slots, returning the pages in the order the links were
+// given.
+func crawlBatch(ctx context.Context, src Source, links []crawlLink, slots fetchSlots) []crawledPage {
+	pages := make([]crawledPage, len(links))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(cap(slots), len(links)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				pages[i] = crawlPage(ctx, src, links[i], slots)
+			}
+		}()
+	}
+	for i := range links {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return pages
+}
+
+func crawlPage(ctx context.Context, src Source, link crawlLink, slots fetchSlots) crawledPage {
+	page := crawledPage{link: link}
+	if err := slots.acquire(ctx); err != nil {
+		page.err = err
+		return page
+	}
+	defer slots.release()
+
+	base, err := url.Parse(link.url)
+	if err != nil {
+		page.err = err
+		return page
+	}
+
+	start := time.Now()
+	fetched, err := fetchPage(ctx, src, link.url, validators{})
+	page.fetching = time.Since(start)
+	if err != nil {
+		page.err = err
+		return page
+	}
+	page.bytes = len(fetched.body)
+
+	start = time.Now()
+	_, span := tracer.Start(ctx, "parse", trace.WithAttributes(attrURL.String(link.url)))
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(fetched.body))
+	if err != nil {
+		endSpan(span, err)
+		page.err = err
+		return page
+	}
+	page.result = link.parse(doc, base)
+	page.parsing = time.Since(start)
+	span.SetAttributes(attrTerms.Int(len(page.result.terms.Terms)))
+	span.End()
+	return page
+}
+
+// resolveLink turns an href found on base into an absolute URL without a
+// fragment, or "" if it doesn't parse.
+func resolveLink(base *url.URL, href string) string {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	u := base.ResolveReference(ref)
+	u.Fragment = ""
+	return u.String()
+}
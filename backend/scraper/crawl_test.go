@@ -0,0 +1,77 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// crawlLinks is a crawlFunc that follows every link on a page and finds
+// no terms.
+func crawlLinks(doc *goquery.Document, base *url.URL) crawlResult {
+	result := crawlResult{terms: newExtracted()}
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		result.follow = append(result.follow, crawlLink{url: resolveLink(base, href), parse: crawlLinks})
+	})
+	return result
+}
+
+func TestCrawlsShareFetchSlots(t *testing.T) {
+	savedWorkers, savedDelay := Workers, HostDelay
+	t.Cleanup(func() { Workers, HostDelay = savedWorkers, savedDelay })
+	Workers, HostDelay = 2, 0
+
+	var mu sync.Mutex
+	inFlight, most := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		inFlight++
+		most = max(most, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		// Each index links to ten pages of its own
+		if page := strings.TrimPrefix(r.URL.Path, "/"); !strings.Contains(page, "-") {
+			for i := range 10 {
+				fmt.Fprintf(w, `<a href="/%s-%d">%d</a>`, page, i, i)
+			}
+		}
+	}))
+	defer server.Close()
+
+	var sources []Source
+	for _, name := range []string{"a", "b", "c"} {
+		sources = append(sources, Source{Name: name, URL: server.URL + "/" + name, Crawl: crawlLinks})
+	}
+	results, failed := ScrapeAll(context.Background(), sources)
+	if len(failed) > 0 {
+		t.Fatalf("scrape failed: %v", failed)
+	}
+	for _, src := range sources {
+		if n := len(results[src.Name].Pages); n != 11 {
+			t.Errorf("%s crawled %d pages, want 11", src.Name, n)
+		}
+	}
+	if most > Workers {
+		t.Errorf("%d pages were fetched at once, want at most %d", most, Workers)
+	}
+}
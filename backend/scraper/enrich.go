@@ -0,0 +1,160 @@
+package scraper
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// WikipediaAPISource names the definitions fetched on demand from
+// Wikipedia's summary API, as opposed to those scraped from its glossary.
+const WikipediaAPISource = "wikipedia-api"
+
+const DefaultEnrichTimeout = 5 * time.Second
+
+// EnrichTimeout is how long each request FetchSummary makes may take. A
+// client is waiting on it, so it is much shorter than Timeout.
+var EnrichTimeout = DefaultEnrichTimeout
+
+// enrichRetries is how many times a summary request is retried; only
+// once, since a client is waiting.
+var enrichRetries = 1
+
+// WikipediaBaseURL is the Wikipedia FetchSummary asks.
+var WikipediaBaseURL = "https://en.wikipedia.org"
+
+// maxCandidates is the most articles taken from a disambiguation page.
+const maxCandidates = 20
+
+// ErrNoArticle is a title Wikipedia has no article with a summary for.
+var ErrNoArticle = errors.New("no Wikipedia article")
+
+// Summary is what Wikipedia says about a title: the first sentence of its
+// article, or the articles it might mean if it names a disambiguation
+// page.
+type Summary struct {
+	Title      string
+	Definition string
+	URL        string
+	// Candidates are the titles a disambiguation page lists; Definition
+	// is empty then
+	Candidates []string
+}
+
+// Source is the source a summary's definition is merged from.
+func (s Summary) Source() Source {
+	return Source{Name: WikipediaAPISource, URL: s.URL, Category: CategoryGeneral}
+}
+
+// summaryResponse is the part of the REST API's page summary FetchSummary
+// reads.
+type summaryResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Titles struct {
+		Normalized string `json:"normalized"`
+	} `json:"titles"`
+	Extract     string `json:"extract"`
+	ContentURLs struct {
+		Desktop struct {
+			Page string `json:"page"`
+		} `json:"desktop"`
+	} `json:"content_urls"`
+}
+
+// FetchSummary asks Wikipedia's REST API for the summary of the article
+// named title, following redirects to it. Requests share the scraper's
+// host delay and proxy, and are retried like any other fetch. It fails
+// with ErrNoArticle if there is no such article or it has no usable
+// summary.
+func FetchSummary(ctx context.Context, title string) (Summary, error) {
+	src := Source{Name: WikipediaAPISource, Timeout: EnrichTimeout, Retries: &enrichRetries}
+	path := url.PathEscape(strings.ReplaceAll(strings.TrimSpace(title), " ", "_"))
+	page, err := fetchAPI(ctx, src, WikipediaBaseURL+"/api/rest_v1/page/summary/"+path)
+	var se statusError
+	if errors.As(err, &se) && se.code == http.StatusNotFound {
+		return Summary{}, ErrNoArticle
+	} else if err != nil {
+		return Summary{}, err
+	}
+
+	var resp summaryResponse
+	if err := json.Unmarshal(page.body, &resp); err != nil {
+		return Summary{}, err
+	}
+	summary := Summary{
+		Title: strings.ReplaceAll(cmp.Or(resp.Titles.Normalized, resp.Title), "_", " "),
+		URL:   resp.ContentURLs.Desktop.Page,
+	}
+
+	switch resp.Type {
+	case "disambiguation":
+		summary.Candidates, err = disambiguationCandidates(ctx, src, summary.URL)
+		return summary, err
+	case "standard":
+		summary.Definition = firstSentence(CleanText(resp.Extract))
+		if summary.Title == "" || !IsValidTerm(summary.Title, summary.Definition) {
+			return Summary{}, ErrNoArticle
+		}
+		return summary, nil
+	default:
+		return Summary{}, ErrNoArticle
+	}
+}
+
+// sentenceEnd matches the end of a sentence followed by the start of
+// another.
+var sentenceEnd = regexp.MustCompile(`[.!?]\s+\p{Lu}`)
+
+// firstSentence returns the first sentence of text, or all of it if it
+// is one sentence.
+func firstSentence(text string) string {
+	if loc := sentenceEnd.FindStringIndex(text); loc != nil {
+		return text[:loc[0]+1]
+	}
+	return text
+}
+
+// disambiguationCandidates lists the articles the disambiguation page at
+// pageURL links to, one per entry, leaving out navigation boxes and the
+// table of contents.
+func disambiguationCandidates(ctx context.Context, src Source, pageURL string) ([]string, error) {
+	if pageURL == "" {
+		return nil, ErrNoArticle
+	}
+	page, err := fetchPage(ctx, src, pageURL, validators{})
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.body))
+	if err != nil {
+		return nil, parseError{err}
+	}
+
+	candidates := []string{}
+	seen := map[string]bool{}
+	doc.Find(".mw-parser-output li").EachWithBreak(func(i int, li *goquery.Selection) bool {
+		if li.Closest(".navbox, .toc, #toc, .sidebar, .metadata").Length() > 0 {
+			return true
+		}
+		// Red links are to articles that don't exist yet
+		a := li.Find("a[title]").First()
+		title := CleanText(a.AttrOr("title", ""))
+		if title == "" || a.HasClass("new") || strings.Contains(title, ":") || seen[title] {
+			return true
+		}
+		seen[title] = true
+		candidates = append(candidates, title)
+		return len(candidates) < maxCandidates
+	})
+	return candidates, nil
+}
@@ -0,0 +1,338 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultRetries = 3
+	// DefaultMaxPageSize is 10 MiB, far more than any glossary page needs
+	DefaultMaxPageSize = 10 << 20
+	// maxRedirects is how many redirects a fetch follows before giving up
+	maxRedirects   = 5
+	DefaultTimeout = 30 * time.Second
+	DefaultBackoff = time.Second
+)
+
+// Retries is how many times a failed fetch is retried, Timeout how long
+// each attempt may take and Backoff the wait before the first retry,
+// doubling after each one. A source can override each of them.
+var (
+	Retries = DefaultRetries
+	Timeout = DefaultTimeout
+	Backoff = DefaultBackoff
+)
+
+// MaxPageSize is the most bytes read from a page; a larger one fails its
+// source rather than being parsed.
+var MaxPageSize = DefaultMaxPageSize
+
+// DefaultUserAgent names the scraper, its version and where to read about
+// it, so site owners can tell what is fetching their pages.
+var DefaultUserAgent = "scrape_cp/" + version() + " (+https://github.com/AbrahamAlgorithm/scrape_cp)"
+
+// UserAgent is sent with every request the scraper makes.
+var UserAgent = DefaultUserAgent
+
+// Proxy, when set, is the proxy every request goes through, instead of
+// the one HTTP_PROXY, HTTPS_PROXY and NO_PROXY choose.
+var Proxy *url.URL
+
+// scrapeClient makes every request the scraper does, for every source, so
+// they all share the same proxy, redirect rules and connection pool. Each
+// request's context carries its timeout.
+var scrapeClient = newScrapeClient()
+
+func newScrapeClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if Proxy != nil {
+			return Proxy, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return &http.Client{Transport: transport, CheckRedirect: checkRedirect}
+}
+
+// checkRedirect follows at most maxRedirects redirects, and only to other
+// http and https URLs.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return redirectError(fmt.Sprintf("stopped after %d redirects", maxRedirects))
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return redirectError("refusing to follow a redirect to " + req.URL.Redacted())
+	}
+	return nil
+}
+
+// redirectError is a redirect checkRedirect wouldn't follow.
+type redirectError string
+
+func (e redirectError) Error() string { return string(e) }
+
+// version is the module version the binary was built from, or "dev".
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" {
+		return "dev"
+	}
+	return info.Main.Version
+}
+
+// statusError is a response with a status other than 200 OK.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("bad status code %d", e.code)
+}
+
+// errTooLarge is a page bigger than MaxPageSize.
+var errTooLarge = errors.New("page is larger than the maximum page size")
+
+// contentTypeError is a response that isn't the kind of document asked
+// for.
+type contentTypeError struct {
+	contentType string
+	want        mediaKind
+}
+
+func (e contentTypeError) Error() string {
+	return fmt.Sprintf("content type %q is not %s", e.contentType, e.want)
+}
+
+// mediaKind is the kind of document a fetch expects back.
+type mediaKind string
+
+const (
+	mediaHTML mediaKind = "HTML"
+	mediaJSON mediaKind = "JSON"
+)
+
+// matches reports whether a Content-Type header names a document of kind
+// k. Responses without one are given the benefit of the doubt.
+func (k mediaKind) matches(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if k == mediaJSON {
+		return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// retryable reports whether a failed fetch is worth another attempt:
+// network errors, server errors and rate limiting are, other client
+// errors are not.
+func retryable(err error) bool {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	var ce contentTypeError
+	var re redirectError
+	return !errors.Is(err, errNotModified) && !errors.Is(err, errTooLarge) && !errors.As(err, &ce) && !errors.As(err, &re) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Offline refuses every fetch that would go over the network, leaving
+// only saved pages to scrape.
+var Offline bool
+
+var errOffline = errors.New("not fetching over the network while offline")
+
+// errNotModified is returned by a conditional fetch of a page that hasn't
+// changed.
+var errNotModified = errors.New("not modified")
+
+// validators are the response headers a later fetch can send back to ask
+// whether a page has changed.
+type validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// fetched is a downloaded page.
+type fetched struct {
+	body       []byte
+	validators validators
+	// attempts is how many requests it took
+	attempts int
+}
+
+// fetchPage downloads rawURL for src, retrying transient failures with
+// exponential backoff and jitter as src's settings say, and gives up as
+// soon as ctx is done. Pages the host's
+// robots.txt disallows aren't fetched at all. When prev holds validators
+// from an earlier fetch the request is conditional, and errNotModified is
+// returned if the page hasn't changed. file:// URLs are read from disk.
+func fetchPage(ctx context.Context, src Source, rawURL string, prev validators) (fetched, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fetched{}, err
+	}
+	if target.Scheme == "file" {
+		return readSavedPage(target)
+	}
+	if Offline {
+		return fetched{}, errOffline
+	}
+
+	allowed, err := robots.allowed(ctx, target)
+	if err != nil {
+		slog.Warn("Could not check robots.txt", "url", rawURL, "err", err)
+	}
+	if !allowed {
+		return fetched{}, ErrBlockedByRobots
+	}
+	return fetchWithRetries(ctx, src, rawURL, prev, mediaHTML)
+}
+
+// fetchAPI downloads a JSON document from an API for src, retrying like
+// fetchPage. APIs are called on behalf of a client rather than crawled,
+// so robots.txt, which often disallows their paths to crawlers, isn't
+// consulted.
+func fetchAPI(ctx context.Context, src Source, rawURL string) (fetched, error) {
+	if Offline {
+		return fetched{}, errOffline
+	}
+	return fetchWithRetries(ctx, src, rawURL, validators{}, mediaJSON)
+}
+
+// fetchWithRetries fetches rawURL, expecting a document of kind want,
+// until it succeeds, fails for good or src's retries run out. The
+// attempts are traced together as one span.
+func fetchWithRetries(ctx context.Context, src Source, rawURL string, prev validators, want mediaKind) (page fetched, err error) {
+	ctx, span := tracer.Start(ctx, "fetch", trace.WithAttributes(attrURL.String(rawURL)))
+	defer func() {
+		span.SetAttributes(attrAttempts.Int(page.attempts))
+		endSpan(span, err)
+	}()
+
+	backoff := src.RetryBackoff()
+	for attempt := 1; ; attempt++ {
+		page, err = fetchOnce(ctx, rawURL, prev, want, src.RequestTimeout())
+		page.attempts = attempt
+		if err == nil || attempt > src.RetryLimit() || !retryable(err) || ctx.Err() != nil {
+			return page, err
+		}
+
+		// Wait somewhere between half and one and a half times the backoff
+		// so that retries from several sources don't line up
+		wait := backoff/2 + rand.N(backoff)
+		slog.Warn("Fetch failed, retrying", "url", rawURL, "attempt", attempt, "wait", wait.Round(time.Millisecond), "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fetched{attempts: attempt}, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// readSavedPage reads a page saved to disk, named by a file:// URL.
+func readSavedPage(target *url.URL) (fetched, error) {
+	f, err := os.Open(filepath.FromSlash(target.Path))
+	if err != nil {
+		return fetched{attempts: 1}, err
+	}
+	defer f.Close()
+
+	body, err := readLimited(f)
+	return fetched{body: body, attempts: 1}, err
+}
+
+// readLimited reads r to the end, failing with errTooLarge once it has
+// gone past MaxPageSize rather than holding any more of it in memory.
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, int64(MaxPageSize)+1))
+	if err == nil && len(body) > MaxPageSize {
+		return nil, errTooLarge
+	}
+	return body, err
+}
+
+// fetchOnce makes a single request for rawURL, giving up on it after
+// timeout, and refuses a response that isn't a document of kind want.
+func fetchOnce(ctx context.Context, rawURL string, prev validators, want mediaKind, timeout time.Duration) (page fetched, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return fetched{}, err
+	}
+	if err := hosts.wait(ctx, req.URL.Hostname()); err != nil {
+		return fetched{}, err
+	}
+
+	// The timeout starts once the host is ready for the request
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req = req.WithContext(attemptCtx)
+	// Running out of time on one attempt is worth a retry, unlike ctx
+	// itself being done
+	defer func() {
+		if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil {
+			err = fmt.Errorf("no response within %v", timeout)
+		}
+	}()
+
+	req.Header.Set("User-Agent", UserAgent)
+	if want == mediaJSON {
+		req.Header.Set("Accept", "application/json")
+	}
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+
+	resp, err := scrapeClient.Do(req)
+	if err != nil {
+		return fetched{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return fetched{validators: prev}, errNotModified
+	default:
+		return fetched{}, statusError{code: resp.StatusCode}
+	}
+	if contentType := resp.Header.Get("Content-Type"); !want.matches(contentType) {
+		return fetched{}, contentTypeError{contentType, want}
+	}
+	if resp.ContentLength > int64(MaxPageSize) {
+		return fetched{}, errTooLarge
+	}
+
+	body, err := readLimited(resp.Body)
+	return fetched{
+		body: body,
+		validators: validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, err
+}
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// scrapeDefinitionList extracts the entries of a page's definition list.
+func scrapeDefinitionList(doc *goquery.Document) Extracted {
+	e := newExtracted()
+	doc.Find("dt").Each(func(_ int, dt *goquery.Selection) {
+		e.add(dt.Text(), dt.Next().Text())
+	})
+	return e
+}
+
+func TestScrapeThroughProxy(t *testing.T) {
+	savedProxy, savedAgent, savedDelay := Proxy, UserAgent, HostDelay
+	t.Cleanup(func() { Proxy, UserAgent, HostDelay = savedProxy, savedAgent, savedDelay })
+	SetStateDir(t.TempDir())
+
+	// The proxy answers for a host that doesn't resolve, so the scrape can
+	// only succeed through it
+	var mu sync.Mutex
+	var requested, agents []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.String())
+		agents = append(agents, r.UserAgent())
+		mu.Unlock()
+		switch r.URL.String() {
+		case "http://glossary.invalid/robots.txt":
+			http.NotFound(w, r)
+		case "http://glossary.invalid/glossary":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<dl><dt>Cache</dt><dd>Fast memory holding recently used data.</dd></dl>`)
+		default:
+			http.Error(w, "not proxied", http.StatusBadGateway)
+		}
+	}))
+	defer proxy.Close()
+
+	Proxy, _ = url.Parse(proxy.URL)
+	UserAgent = "glossary-test/1.0"
+	HostDelay = 0
+	src := Source{Name: "Glossary", URL: "http://glossary.invalid/glossary", ScrapeFunc: scrapeDefinitionList}
+	results, failed := ScrapeAll(context.Background(), []Source{src})
+	if len(failed) > 0 {
+		t.Fatalf("scrape failed: %v", failed)
+	}
+	if _, ok := results[src.Name].Terms["Cache"]; !ok {
+		t.Errorf("scraped %v, want Cache", results[src.Name].Terms)
+	}
+
+	want := []string{"http://glossary.invalid/robots.txt", "http://glossary.invalid/glossary"}
+	if !slices.Equal(requested, want) {
+		t.Errorf("proxy was asked for %q, want %q", requested, want)
+	}
+	for i, agent := range agents {
+		if agent != UserAgent {
+			t.Errorf("request for %s sent User-Agent %q, want %q", requested[i], agent, UserAgent)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultHostDelay = time.Second
+
+// HostDelay is the least time between requests to the same host.
+var HostDelay = DefaultHostDelay
+
+// hostLimiter spaces out requests to the same host by at least HostDelay
+// while letting requests to different hosts go ahead concurrently.
+type hostLimiter struct {
+	mu sync.Mutex
+	// next is the earliest time the next request to each host may start
+	next map[string]time.Time
+	// requests counts the requests made to each host
+	requests map[string]int
+}
+
+var hosts = &hostLimiter{
+	next:     make(map[string]time.Time),
+	requests: make(map[string]int),
+}
+
+// wait blocks until a request to host may be made, or ctx is done.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(HostDelay)
+	l.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	l.requests[host]++
+	l.mu.Unlock()
+	return nil
+}
+
+// HostRequests returns how many requests have been made to each host.
+func HostRequests() map[string]int {
+	return hosts.counts()
+}
+
+// counts returns a copy of the per-host request counts.
+func (l *hostLimiter) counts() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int, len(l.requests))
+	for host, n := range l.requests {
+		counts[host] = n
+	}
+	return counts
+}
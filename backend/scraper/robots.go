@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/temoto/robotstxt"
+)
+
+// robotsAgent is the name looked for in robots.txt user-agent lines. Rules
+// for * apply when there is no group for it.
+const robotsAgent = "scrape_cp"
+
+// ErrBlockedByRobots is returned for pages a host's robots.txt disallows.
+var ErrBlockedByRobots = errors.New("blocked by robots.txt")
+
+// RobotsStrict treats a robots.txt that can't be fetched as disallowing
+// everything, rather than allowing everything.
+var RobotsStrict bool
+
+// robotsCache keeps each host's parsed robots.txt for the life of the
+// process. Fetch failures aren't cached, so the next scrape tries again.
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotstxt.Group
+	// fetching serialises lookups per host so sources on the same host
+	// don't each fetch its robots.txt
+	fetching map[string]*sync.Mutex
+}
+
+var robots = &robotsCache{
+	hosts:    make(map[string]*robotstxt.Group),
+	fetching: make(map[string]*sync.Mutex),
+}
+
+// allowed reports whether the robots.txt of target's host lets us fetch it.
+func (c *robotsCache) allowed(ctx context.Context, target *url.URL) (bool, error) {
+	key := target.Scheme + "://" + target.Host
+
+	c.mu.Lock()
+	hostMu, ok := c.fetching[key]
+	if !ok {
+		hostMu = new(sync.Mutex)
+		c.fetching[key] = hostMu
+	}
+	c.mu.Unlock()
+
+	hostMu.Lock()
+	defer hostMu.Unlock()
+
+	c.mu.Lock()
+	group, cached := c.hosts[key]
+	c.mu.Unlock()
+
+	if !cached {
+		var err error
+		group, err = fetchRobots(ctx, key)
+		if err != nil {
+			return !RobotsStrict, err
+		}
+		c.mu.Lock()
+		c.hosts[key] = group
+		c.mu.Unlock()
+	}
+	return group.Test(target.EscapedPath()), nil
+}
+
+func fetchRobots(ctx context.Context, origin string) (*robotstxt.Group, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := hosts.wait(ctx, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	// robots.txt is shared by every source on the host, so it gets the
+	// global timeout rather than any one source's
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := scrapeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// A missing robots.txt allows everything, but a server error says
+	// nothing either way
+	if resp.StatusCode >= 500 {
+		return nil, statusError{code: resp.StatusCode}
+	}
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s/robots.txt: %w", origin, err)
+	}
+	return data.FindGroup(robotsAgent), nil
+}
@@ -0,0 +1,310 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultWorkers = 4
+
+// Workers is how many sources are scraped at once, and how many pages
+// are fetched at once between them.
+var Workers = DefaultWorkers
+
+// fetchSlots bounds how many pages are fetched and parsed at once across
+// every source of a scrape, including the pages of a crawl fetched side by
+// side. A slot is only held for one page, never while waiting on another,
+// so sources can't starve one another of them.
+type fetchSlots chan struct{}
+
+func newFetchSlots(n int) fetchSlots {
+	return make(fetchSlots, n)
+}
+
+// acquire waits for a free slot, failing with ctx's error if it is done
+// first.
+func (f fetchSlots) acquire(ctx context.Context) error {
+	select {
+	case f <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f fetchSlots) release() {
+	<-f
+}
+
+// Result is what scraping one source found.
+type Result struct {
+	// Terms maps each term found to its definitions
+	Terms map[string][]string
+	// Unchanged is set when the source's page hadn't changed since it was
+	// last scraped, so Terms are the ones it supplied then
+	Unchanged bool
+	// Skipped is set when the scrape was cancelled before the source was
+	// started
+	Skipped bool
+	// Attempts is how many requests were made, counting retries and every
+	// page of a source that spans several
+	Attempts int
+	// Pages lists the pages fetched for a source that spans several
+	Pages []string
+	// Found counts the entries on the source's pages, including the
+	// Rejected ones IsValidTerm turned down, which aren't in Terms
+	Found    int
+	Rejected int
+	// Duration is how long the whole scrape took, of which Fetching was
+	// spent downloading pages and Parsing extracting their terms, each
+	// summed over the pages of a source that spans several
+	Duration time.Duration
+	Fetching time.Duration
+	Parsing  time.Duration
+}
+
+// Errors maps the sources that failed to scrape to why.
+type Errors map[string]error
+
+// Error lists the failures one per line, in order of source name.
+func (e Errors) Error() string {
+	lines := make([]string, 0, len(e))
+	for name, err := range e {
+		lines = append(lines, fmt.Sprintf("  %s: %v", name, err))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
+
+// Scraper scrapes sources, telling its hooks how each one goes. Every
+// field is optional; the zero Scraper just scrapes.
+type Scraper struct {
+	// Force downloads pages even if they say they haven't changed
+	Force bool
+	// Previous returns the terms a source supplied the last time it was
+	// scraped, if it has been. Its page is only downloaded again if it
+	// has changed since, while those terms are still around to be kept.
+	Previous func(source string) (map[string][]string, bool)
+	// Known looks up the definition of a term found before, for sources
+	// that resolve what they find against other terms
+	Known func(term string) (string, bool)
+
+	// Started is called as each source is started, Fetched once its pages
+	// are downloaded with their total size, and Done with the outcome as
+	// soon as it is known, given the context of the source's span. They
+	// are called from the goroutine scraping the source, so several may
+	// run at once.
+	Started func(src Source)
+	Fetched func(src Source, bytes int)
+	Done    func(ctx context.Context, src Source, result Result, err error)
+}
+
+// ScrapeAll scrapes every source, Workers at a time, returning what was
+// found for each source that succeeded, by name, and why each of the
+// others failed.
+func ScrapeAll(ctx context.Context, sources []Source) (map[string]Result, Errors) {
+	var s Scraper
+	return s.ScrapeAll(ctx, sources)
+}
+
+// ScrapeAll scrapes every source, Workers at a time, returning what was
+// found for each source that succeeded, by name, and why each of the
+// others failed. Sources not yet started when ctx is done fail with its
+// error. Each source started is traced as a span of its own, under
+// whichever span ctx carries.
+func (s *Scraper) ScrapeAll(ctx context.Context, sources []Source) (map[string]Result, Errors) {
+	var mu sync.Mutex
+	results := make(map[string]Result)
+	failed := make(Errors)
+	done := func(ctx context.Context, src Source, result Result, err error) {
+		if s.Done != nil {
+			s.Done(ctx, src, result, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed[src.Name] = err
+		} else {
+			results[src.Name] = result
+		}
+	}
+
+	// A fixed pool of workers keeps the number of sources in progress
+	// bounded however many there are, and the fetch slots they share keep
+	// the number of pages in memory and connections open bounded however
+	// many pages each crawl fetches at once
+	slots := newFetchSlots(Workers)
+	jobs := make(chan Source)
+	var wg sync.WaitGroup
+	for range min(Workers, len(sources)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range jobs {
+				srcCtx, span := tracer.Start(ctx, "scrape source",
+					trace.WithAttributes(attrSource.String(src.Name), attrURL.String(src.URL)))
+				result, err := s.scrape(srcCtx, src, sources, slots)
+				done(srcCtx, src, result, err)
+				span.SetAttributes(attrAttempts.Int(result.Attempts), attrTerms.Int(len(result.Terms)),
+					attrPages.Int(len(result.Pages)), attrUnchanged.Bool(result.Unchanged))
+				endSpan(span, err)
+			}
+		}()
+	}
+
+queue:
+	for i, src := range sources {
+		select {
+		case jobs <- src:
+		case <-ctx.Done():
+			for _, skipped := range sources[i:] {
+				done(ctx, skipped, Result{Skipped: true}, ctx.Err())
+			}
+			break queue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results, failed
+}
+
+// scrape scrapes src whichever way it needs, fetching each page in one of
+// slots. sources are all the sources being scraped, whose pages a crawl
+// leaves to them.
+func (s *Scraper) scrape(ctx context.Context, src Source, sources []Source, slots fetchSlots) (Result, error) {
+	start := time.Now()
+	slog.Debug("Scraping source", "source", src.Name, "url", src.URL, "timeout", src.RequestTimeout(),
+		"retries", src.RetryLimit(), "backoff", src.RetryBackoff())
+	if s.Started != nil {
+		s.Started(src)
+	}
+	fetchedBytes := func(n int) {
+		if s.Fetched != nil {
+			s.Fetched(src, n)
+		}
+	}
+
+	var result Result
+	var err error
+	if src.Crawl != nil {
+		result, err = crawlSource(ctx, src, sources, slots, fetchedBytes)
+		if err == nil && src.Resolve != nil {
+			src.Resolve(result.Terms, s.known)
+		}
+	} else {
+		result, err = s.scrapePage(ctx, src, slots, fetchedBytes)
+	}
+	result.Duration = time.Since(start)
+	return result, err
+}
+
+// known looks term up with Known, if it is set.
+func (s *Scraper) known(term string) (string, bool) {
+	if s.Known == nil {
+		return "", false
+	}
+	return s.Known(term)
+}
+
+// scrapePage scrapes a source that is a single page, with error handling
+// and retries. Unless s.Force is set, the page is only downloaded and
+// parsed again if it has changed since the source was last scraped. The
+// page is fetched and parsed in one of slots.
+func (s *Scraper) scrapePage(ctx context.Context, src Source, slots fetchSlots, fetchedBytes func(int)) (Result, error) {
+	url, name := src.URL, src.Name
+
+	// Validators are only worth sending while the terms they vouch for
+	// are still around to be kept
+	var prev validators
+	var previous map[string][]string
+	var scraped bool
+	if s.Previous != nil {
+		previous, scraped = s.Previous(name)
+	}
+	if scraped && !s.Force {
+		prev = sourceState.get(name)
+	}
+
+	if err := slots.acquire(ctx); err != nil {
+		return Result{}, err
+	}
+	defer slots.release()
+
+	start := time.Now()
+	page, err := fetchPage(ctx, src, url, prev)
+	result := Result{Attempts: page.attempts, Fetching: time.Since(start)}
+	var se statusError
+	var ce contentTypeError
+	switch {
+	case errors.Is(err, errNotModified):
+		slog.Info("Source has not changed since it was last scraped", "source", name, "url", url)
+		result.Terms, result.Unchanged = previous, true
+		return result, nil
+	case errors.Is(err, ErrBlockedByRobots), errors.Is(err, errTooLarge), errors.As(err, &ce):
+		slog.Warn("Skipping source", "source", name, "url", url, "err", err)
+		return result, err
+	case errors.As(err, &se):
+		slog.Error("Bad status code", "source", name, "url", url, "status", se.code)
+		return result, err
+	case err != nil:
+		slog.Error("Failed to fetch source", "source", name, "url", url, "err", err)
+		return result, err
+	}
+	fetchedBytes(len(page.body))
+
+	start = time.Now()
+	_, span := tracer.Start(ctx, "parse", trace.WithAttributes(attrURL.String(url)))
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.body))
+	if err != nil {
+		endSpan(span, err)
+		slog.Error("Failed to parse HTML", "source", name, "url", url, "err", err)
+		return result, parseError{err}
+	}
+
+	extracted := src.ScrapeFunc(doc)
+	result.Terms, result.Found, result.Rejected = extracted.Terms, extracted.Found, extracted.Rejected
+	result.Parsing = time.Since(start)
+	span.SetAttributes(attrTerms.Int(len(result.Terms)))
+	span.End()
+	sourceState.set(name, page.validators)
+	return result, nil
+}
+
+// parseError is a page that was fetched but couldn't be parsed.
+type parseError struct {
+	err error
+}
+
+func (e parseError) Error() string { return e.err.Error() }
+func (e parseError) Unwrap() error { return e.err }
+
+// FailureReason names the stage a scrape failed at, for metrics: robots,
+// status, content_type, too_large, parse or fetch.
+func FailureReason(err error) string {
+	var se statusError
+	var ce contentTypeError
+	var pe parseError
+	switch {
+	case errors.Is(err, ErrBlockedByRobots):
+		return "robots"
+	case errors.As(err, &se):
+		return "status"
+	case errors.As(err, &ce):
+		return "content_type"
+	case errors.Is(err, errTooLarge):
+		return "too_large"
+	case errors.As(err, &pe):
+		return "parse"
+	}
+	return "fetch"
+}
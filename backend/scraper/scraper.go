@@ -0,0 +1,301 @@
+// Package scraper fetches computer science glossaries and extracts their
+// terms. Pages are fetched politely: robots.txt is honoured, requests to
+// the same host are spaced out and transient failures are retried.
+package scraper
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Source is a page to scrape terms from and the function that extracts
+// them. Sources that span several pages set Crawl instead of ScrapeFunc,
+// and may set Resolve to tidy up everything the crawl found before it is
+// merged, given a way to look up the definitions of terms already known.
+// Every term a source supplies is tagged with its Category.
+type Source struct {
+	URL        string
+	Name       string
+	Category   string
+	ScrapeFunc func(*goquery.Document) Extracted
+	Crawl      crawlFunc
+	Resolve    func(terms map[string][]string, known func(term string) (string, bool))
+
+	// Timeout, Retries and Backoff override the global Timeout, Retries
+	// and Backoff for this source when set
+	Timeout time.Duration
+	Retries *int
+	Backoff time.Duration
+}
+
+// RequestTimeout is how long each request for s may take.
+func (s Source) RequestTimeout() time.Duration {
+	return cmp.Or(s.Timeout, Timeout)
+}
+
+// RetryLimit is how many times a failed request for s is retried.
+func (s Source) RetryLimit() int {
+	if s.Retries != nil {
+		return *s.Retries
+	}
+	return Retries
+}
+
+// RetryBackoff is how long to wait before retrying a request for s the
+// first time.
+func (s Source) RetryBackoff() time.Duration {
+	return cmp.Or(s.Backoff, Backoff)
+}
+
+// Sources are the sources scraped by default, or those a sources file
+// lists once LoadSources has read it.
+var Sources = []Source{
+	{
+		URL:        "https://www.coursera.org/collections/computer-science-terms",
+		Name:       "Coursera",
+		Category:   CategoryGeneral,
+		ScrapeFunc: scrapeCourseraTerms,
+	},
+	{
+		URL:      "https://en.wikipedia.org/wiki/Glossary_of_computer_science",
+		Name:     "Wikipedia",
+		Category: CategoryGeneral,
+		Crawl:    crawlWikipediaGlossaries(0),
+		// The glossary is one very large page
+		Timeout: time.Minute,
+	},
+	{
+		URL:        "https://en.wikipedia.org/wiki/Glossary_of_artificial_intelligence",
+		Name:       "Wikipedia AI",
+		Category:   "ai",
+		ScrapeFunc: scrapeWikipediaTerms,
+	},
+	{
+		URL:        "https://en.wikipedia.org/wiki/Glossary_of_software_engineering",
+		Name:       "Wikipedia Software Engineering",
+		Category:   "software-engineering",
+		ScrapeFunc: scrapeWikipediaTerms,
+	},
+	{
+		URL:        "https://en.wikipedia.org/wiki/Glossary_of_computer_hardware_terms",
+		Name:       "Wikipedia Hardware",
+		Category:   "hardware",
+		ScrapeFunc: scrapeWikipediaTerms,
+	},
+	{
+		URL:        "https://www.geeksforgeeks.org/computer-science-glossary/",
+		Name:       "GeeksforGeeks",
+		Category:   CategoryGeneral,
+		ScrapeFunc: scrapeGeeksForGeeksTerms,
+	},
+	{
+		URL:      "https://en.wiktionary.org/wiki/Category:en:Computing",
+		Name:     "Wiktionary",
+		Category: CategoryGeneral,
+		Crawl:    crawlWiktionaryCategory,
+		Resolve:  resolveWiktionaryAbbreviations,
+	},
+	{
+		URL:      "https://techterms.com/list/a",
+		Name:     "TechTerms",
+		Category: CategoryGeneral,
+		Crawl:    crawlTechTermsList,
+	},
+}
+
+// invisibleSpaces replaces the non-breaking spaces pages use for layout
+// with plain ones and drops zero-width spaces, which would otherwise stop
+// words from matching in search.
+var invisibleSpaces = strings.NewReplacer(
+	"\u00a0", " ", "\u2007", " ", "\u202f", " ",
+	"\u200b", "", "\u2060", "", "\ufeff", "", "\u00ad", "",
+)
+
+// typographicPunctuation maps curly quotes, dashes and ellipses to ASCII.
+var typographicPunctuation = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u2032", "'",
+	"\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u2033", `"`,
+	"\u2013", "-", "\u2014", "-", "\u2212", "-", "\u2026", "...",
+)
+
+// ASCIIPunctuation makes CleanText replace typographic punctuation with
+// ASCII.
+var ASCIIPunctuation bool
+
+// CleanText tidies text taken from a page: it decodes entities left
+// escaped, folds whitespace, normalizes to NFC so composed and decomposed
+// accents compare equal, and drops control characters.
+func CleanText(text string) string {
+	text = html.UnescapeString(text)
+	text = invisibleSpaces.Replace(text)
+	text = norm.NFC.String(text)
+	if ASCIIPunctuation {
+		text = typographicPunctuation.Replace(text)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.Map(func(r rune) rune {
+		// Zero-width joiners are formatting characters, but some scripts
+		// need them to spell words correctly
+		if unicode.IsPrint(r) || r == '\u200c' || r == '\u200d' {
+			return r
+		}
+		return -1
+	}, text)
+}
+
+// MinDefinitionLength is the shortest definition, in bytes, IsValidTerm
+// keeps.
+const MinDefinitionLength = 10
+
+// IsValidTerm reports whether a term and its definition are worth
+// keeping, logging why not at debug level.
+func IsValidTerm(term, definition string) bool {
+	reason := RejectReason(term, definition)
+	if reason != "" {
+		slog.Debug("Skipping term", "term", term, "reason", reason)
+	}
+	return reason == ""
+}
+
+// Extracted is what a page yielded: its terms, each with its definitions,
+// and how many entries were found on it, counting the ones IsValidTerm
+// turned down.
+type Extracted struct {
+	Terms    map[string][]string
+	Found    int
+	Rejected int
+}
+
+func newExtracted() Extracted {
+	return Extracted{Terms: make(map[string][]string)}
+}
+
+// add keeps an entry found on the page if IsValidTerm does, counting it
+// either way.
+func (e *Extracted) add(term, definition string) {
+	e.Found++
+	if !IsValidTerm(term, definition) {
+		e.Rejected++
+		return
+	}
+	e.Terms[term] = append(e.Terms[term], definition)
+}
+
+// RejectReason explains why IsValidTerm turns a term down, or is empty if
+// it doesn't.
+func RejectReason(term, definition string) string {
+	switch {
+	case len(term) < 2:
+		return "term must be at least 2 characters"
+	case len(definition) < MinDefinitionLength:
+		return fmt.Sprintf("definition must be at least %d characters", MinDefinitionLength)
+	}
+
+	termForComparison := term
+	if i := strings.Index(term, " ("); i != -1 {
+		termForComparison = term[:i]
+	}
+
+	if strings.Contains(strings.ToLower(definition), strings.ToLower(termForComparison)) &&
+		len(definition) < len(termForComparison)+20 {
+		return "definition only restates the term"
+	}
+	return ""
+}
+
+// wikipediaReferences matches the footnote, citation-needed and edit
+// markers Wikipedia puts in running text.
+const wikipediaReferences = "sup.reference, sup.noprint, .Inline-Template, .mw-editsection, .mw-ref"
+
+// referenceMarker matches footnote markers that survive as text, like
+// "[1]", "[note 2]" or "[citation needed]", along with the space before
+// them. Lettered notes like "[a]" are only removed as elements, since as
+// text they can't be told apart from code such as "a[i]".
+var referenceMarker = regexp.MustCompile(`\s*\[(?:\d+|(?:note|nb|n) \d+|citation needed|clarification needed|when\?|who\?|by whom\?|according to whom\?|failed verification|dubious[^\]]*)\]`)
+
+// stripReferenceMarkers removes footnote markers left in text, keeping any
+// other bracketed words.
+func stripReferenceMarkers(text string) string {
+	return strings.TrimSpace(referenceMarker.ReplaceAllString(text, ""))
+}
+
+// funtions to scrape terms from different sources
+func scrapeWikipediaTerms(doc *goquery.Document) Extracted {
+	terms := newExtracted()
+
+	doc.Find("dl.glossary").Each(func(i int, dlElement *goquery.Selection) {
+		var currentTerm string
+
+		dlElement.Children().Each(func(j int, element *goquery.Selection) {
+			element.Find(wikipediaReferences).Remove()
+			if element.Is("dt") {
+				currentTerm = stripReferenceMarkers(CleanText(element.Text()))
+			} else if element.Is("dd") && currentTerm != "" {
+				definition := stripReferenceMarkers(CleanText(element.Text()))
+				terms.add(currentTerm, definition)
+			}
+		})
+	})
+
+	return terms
+}
+
+func scrapeCourseraTerms(doc *goquery.Document) Extracted {
+	terms := newExtracted()
+
+	doc.Find("p").Each(func(i int, s *goquery.Selection) {
+		if strong := s.Find("strong"); strong.Length() > 0 {
+			term := CleanText(strong.Text())
+			if nextP := s.Next(); nextP.Length() > 0 {
+				definition := CleanText(nextP.Text())
+				terms.add(term, definition)
+			}
+		}
+	})
+
+	return terms
+}
+
+// geeksForGeeksBoilerplate matches the related-content blocks GeeksforGeeks
+// appends to articles.
+const geeksForGeeksBoilerplate = ".recommended-articles, .article--recommended, .more-articles, .improved, .article-meta"
+
+// headingNumber matches list numbering in front of a heading, as in "12. ".
+var headingNumber = regexp.MustCompile(`^\d+[.)]\s*`)
+
+func scrapeGeeksForGeeksTerms(doc *goquery.Document) Extracted {
+	terms := newExtracted()
+
+	doc.Find(geeksForGeeksBoilerplate).Remove()
+
+	doc.Find("article h2, article h3").EachWithBreak(func(i int, heading *goquery.Selection) bool {
+		term := CleanText(heading.Text())
+		if strings.HasPrefix(strings.ToLower(term), "recommended articles") {
+			return false
+		}
+		term = headingNumber.ReplaceAllString(term, "")
+		term = strings.TrimSpace(strings.TrimSuffix(term, ":"))
+
+		var paragraphs []string
+		heading.NextUntil("h1, h2, h3").Filter("p").Each(func(j int, p *goquery.Selection) {
+			if text := CleanText(p.Text()); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		})
+
+		definition := strings.Join(paragraphs, " ")
+		terms.add(term, definition)
+		return true
+	})
+
+	return terms
+}
@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"cmp"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// loadFixture parses the saved page testdata/name.
+func loadFixture(t *testing.T, name string) *goquery.Document {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+	return doc
+}
+
+// checkExtracted compares what a scraper extracted with the terms it
+// should have, and how many entries it should have found and rejected.
+func checkExtracted(t *testing.T, got Extracted, want map[string][]string, found, rejected int) {
+	t.Helper()
+	for _, term := range slices.Sorted(maps.Keys(want)) {
+		if !slices.Equal(got.Terms[term], want[term]) {
+			t.Errorf("%s = %q, want %q", term, got.Terms[term], want[term])
+		}
+	}
+	for term := range got.Terms {
+		if _, ok := want[term]; !ok {
+			t.Errorf("unexpected term %q: %q", term, got.Terms[term])
+		}
+	}
+	if got.Found != found || got.Rejected != rejected {
+		t.Errorf("found %d and rejected %d, want %d and %d", got.Found, got.Rejected, found, rejected)
+	}
+}
+
+func TestScrapeGeeksForGeeksTerms(t *testing.T) {
+	got := scrapeGeeksForGeeksTerms(loadFixture(t, "geeksforgeeks.html"))
+	checkExtracted(t, got, map[string][]string{
+		// Numbering and a trailing colon come off the heading, and the
+		// paragraphs up to the next heading make up the definition
+		"Algorithm": {"A finite sequence of well-defined steps that solves a problem. " +
+			"Algorithms are judged by their time and space complexity."},
+		"Compiler": {"A program that translates source code into machine code before it runs."},
+		// Only paragraphs count, not lists
+		"Deadlock": {"A state in which each process waits for a resource another one holds."},
+		// Nothing from "Recommended Articles" on, nor from the related
+		// articles box, is a term
+	}, 4, 1)
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		ascii string // the output with ASCIIPunctuation, if it differs
+	}{
+		{
+			name: "escaped entities from Wikipedia",
+			in:   "Compare-and-swap (CAS) &amp; load-link/store-conditional (LL/SC)",
+			want: "Compare-and-swap (CAS) & load-link/store-conditional (LL/SC)",
+		},
+		{
+			name: "non-breaking spaces from Coursera",
+			in:   "A byte is 8\u00a0bits; a nibble is half\u202fthat.",
+			want: "A byte is 8 bits; a nibble is half that.",
+		},
+		{
+			name: "zero-width spaces and soft hyphens",
+			in:   "hash\u200btable\u00ad lookups\ufeff",
+			want: "hashtable lookups",
+		},
+		{
+			name: "layout whitespace",
+			in:   "\n\t  Binary   search\n  tree\t",
+			want: "Binary search tree",
+		},
+		{
+			name: "control characters",
+			in:   "Bell\a character\x00",
+			want: "Bell character",
+		},
+		{
+			name: "decomposed accents",
+			in:   "Cache\u0301 and nai\u0308ve Bayes",
+			want: "Caché and naïve Bayes",
+		},
+		{
+			name:  "Wikipedia's typographic punctuation",
+			in:    "Knuth’s “premature optimization” – a quote…",
+			want:  "Knuth’s “premature optimization” – a quote…",
+			ascii: `Knuth's "premature optimization" - a quote...`,
+		},
+		{
+			name:  "French loanwords with an em dash",
+			in:    "Façade — a déjà vu pattern",
+			want:  "Façade — a déjà vu pattern",
+			ascii: "Façade - a déjà vu pattern",
+		},
+		{
+			name:  "German loanwords",
+			in:    "Schönhage–Strassen and the Entscheidungsproblem",
+			want:  "Schönhage–Strassen and the Entscheidungsproblem",
+			ascii: "Schönhage-Strassen and the Entscheidungsproblem",
+		},
+		{
+			name: "Devanagari vowel signs",
+			in:   "संगणक (computer)",
+			want: "संगणक (computer)",
+		},
+		{
+			name: "Persian zero-width non-joiner",
+			in:   "رایانه\u200cها",
+			want: "رایانه\u200cها",
+		},
+		{
+			name: "ideographic space",
+			in:   "キャッシュ\u3000メモリ",
+			want: "キャッシュ メモリ",
+		},
+	}
+	t.Cleanup(func() { ASCIIPunctuation = false })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ASCIIPunctuation = false
+			if got := CleanText(tt.in); got != tt.want {
+				t.Errorf("CleanText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			ascii := cmp.Or(tt.ascii, tt.want)
+			ASCIIPunctuation = true
+			if got := CleanText(tt.in); got != ascii {
+				t.Errorf("with ASCII punctuation, CleanText(%q) = %q, want %q", tt.in, got, ascii)
+			}
+		})
+	}
+}
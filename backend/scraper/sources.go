@@ -0,0 +1,245 @@
+package scraper
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultSourcesFile = "sources.yaml"
+
+// DefaultPagesDir is where --offline looks for saved pages.
+const DefaultPagesDir = "pages"
+
+// CategoryGeneral is the category of sources that cover computing as a
+// whole, and of those in a sources file that don't name one.
+const CategoryGeneral = "general"
+
+// builtinScrapers are the scrape functions a sources file can name.
+var builtinScrapers = map[string]func(*goquery.Document) Extracted{
+	"coursera":      scrapeCourseraTerms,
+	"geeksforgeeks": scrapeGeeksForGeeksTerms,
+	"wikipedia":     scrapeWikipediaTerms,
+}
+
+// builtinCrawlers are the multi-page scrapers a sources file can name,
+// along with the clean-up each one needs.
+var builtinCrawlers = map[string]Source{
+	"techterms":            {Crawl: crawlTechTermsList},
+	"wikipedia-glossaries": {Crawl: crawlWikipediaGlossaries(0)},
+	"wiktionary":           {Crawl: crawlWiktionaryCategory, Resolve: resolveWiktionaryAbbreviations},
+}
+
+// Layouts a selectorSpec can describe.
+const (
+	// patternList pairs each term element with the next sibling matching
+	// the definition selector, as in a dt/dd definition list
+	patternList = "list"
+	// patternParagraph takes the term from an element inside a block,
+	// such as a strong in a p, and the definition from the block's next
+	// sibling matching the definition selector
+	patternParagraph = "paragraph"
+)
+
+var selectorPatterns = []string{patternList, patternParagraph}
+
+// selectorSpec tells scrapeWithSelectors where a page keeps its terms and
+// definitions.
+type selectorSpec struct {
+	Pattern    string `yaml:"pattern"`
+	Term       string `yaml:"term"`
+	Definition string `yaml:"definition"`
+}
+
+// sourceConfig is one entry of a sources file. It names either a built-in
+// scraper or the selectors for the generic one.
+type sourceConfig struct {
+	Name      string        `yaml:"name"`
+	URL       string        `yaml:"url"`
+	Category  string        `yaml:"category"`
+	Scraper   string        `yaml:"scraper"`
+	Selectors *selectorSpec `yaml:"selectors"`
+
+	// Timeout, Retries and Backoff override the global settings
+	Timeout time.Duration `yaml:"timeout"`
+	Retries *int          `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// LoadSources reads a sources file. ok is false when there is no such
+// file; any problem with its contents is an error naming the line.
+func LoadSources(path string) (loaded []Source, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var doc struct {
+		Sources []yaml.Node `yaml:"sources"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, true, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Sources) == 0 {
+		return nil, true, fmt.Errorf("%s: no sources listed", path)
+	}
+
+	seen := make(map[string]bool)
+	for _, node := range doc.Sources {
+		src, err := parseSourceConfig(&node, filepath.Dir(path))
+		if err == nil && seen[src.Name] {
+			err = fmt.Errorf("duplicate source name %q", src.Name)
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("%s:%d: %w", path, node.Line, err)
+		}
+		seen[src.Name] = true
+		loaded = append(loaded, src)
+	}
+	return loaded, true, nil
+}
+
+// parseSourceConfig decodes and checks one sources file entry.
+func parseSourceConfig(node *yaml.Node, dir string) (Source, error) {
+	var cfg sourceConfig
+	if err := node.Decode(&cfg); err != nil {
+		return Source{}, err
+	}
+
+	if cfg.Name == "" {
+		return Source{}, errors.New("source needs a name")
+	}
+	target, ok := sourceURL(cfg.URL, dir)
+	if !ok {
+		return Source{}, fmt.Errorf("source %q needs an http or https url, or the path of a saved page", cfg.Name)
+	}
+
+	switch {
+	case cfg.Timeout < 0:
+		return Source{}, fmt.Errorf("source %q: timeout must not be negative", cfg.Name)
+	case cfg.Retries != nil && *cfg.Retries < 0:
+		return Source{}, fmt.Errorf("source %q: retries must not be negative", cfg.Name)
+	case cfg.Backoff < 0:
+		return Source{}, fmt.Errorf("source %q: backoff must not be negative", cfg.Name)
+	}
+
+	src := Source{
+		URL:      target,
+		Name:     cfg.Name,
+		Category: cmp.Or(cfg.Category, CategoryGeneral),
+		Timeout:  cfg.Timeout,
+		Retries:  cfg.Retries,
+		Backoff:  cfg.Backoff,
+	}
+	switch {
+	case cfg.Scraper != "" && cfg.Selectors != nil:
+		return Source{}, fmt.Errorf("source %q has both a scraper and selectors", cfg.Name)
+	case cfg.Scraper != "":
+		if crawler, ok := builtinCrawlers[cfg.Scraper]; ok {
+			src.Crawl, src.Resolve = crawler.Crawl, crawler.Resolve
+			break
+		}
+		scrape, ok := builtinScrapers[cfg.Scraper]
+		if !ok {
+			return Source{}, fmt.Errorf("source %q: unknown scraper %q", cfg.Name, cfg.Scraper)
+		}
+		src.ScrapeFunc = scrape
+	case cfg.Selectors != nil:
+		spec := *cfg.Selectors
+		if err := spec.check(); err != nil {
+			return Source{}, fmt.Errorf("source %q: %w", cfg.Name, err)
+		}
+		src.ScrapeFunc = func(doc *goquery.Document) Extracted {
+			return scrapeWithSelectors(doc, spec)
+		}
+	default:
+		return Source{}, fmt.Errorf("source %q needs a scraper or selectors", cfg.Name)
+	}
+	return src, nil
+}
+
+// check makes sure the pattern is known and both selectors compile.
+func (spec *selectorSpec) check() error {
+	if spec.Pattern == "" {
+		spec.Pattern = patternList
+	}
+	if !slices.Contains(selectorPatterns, spec.Pattern) {
+		return fmt.Errorf("pattern must be one of %s", strings.Join(selectorPatterns, ", "))
+	}
+	for _, sel := range []struct{ field, value string }{
+		{"term", spec.Term},
+		{"definition", spec.Definition},
+	} {
+		if sel.value == "" {
+			return fmt.Errorf("%s selector is required", sel.field)
+		}
+		if _, err := cascadia.Compile(sel.value); err != nil {
+			return fmt.Errorf("%s selector: %w", sel.field, err)
+		}
+	}
+	return nil
+}
+
+// sourceURL checks the url of a source, turning the path of a page saved
+// to disk into a file:// URL. Relative paths are taken from dir, the
+// directory of the sources file.
+func sourceURL(raw, dir string) (string, bool) {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil || raw == "":
+		return "", false
+	case u.Scheme == "http" || u.Scheme == "https":
+		return raw, u.Host != ""
+	case u.Scheme == "file":
+		return raw, u.Path != ""
+	case u.Scheme != "":
+		return "", false
+	}
+
+	path, err := filepath.Abs(filepath.Join(dir, raw))
+	if filepath.IsAbs(raw) {
+		path, err = raw, nil
+	}
+	if err != nil {
+		return "", false
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(), true
+}
+
+// scrapeWithSelectors extracts terms from a page laid out as spec
+// describes.
+func scrapeWithSelectors(doc *goquery.Document, spec selectorSpec) Extracted {
+	terms := newExtracted()
+
+	doc.Find(spec.Term).Each(func(i int, termElement *goquery.Selection) {
+		var definitionElement *goquery.Selection
+		if spec.Pattern == patternParagraph {
+			definitionElement = termElement.Parent().NextAllFiltered(spec.Definition).First()
+		} else {
+			definitionElement = termElement.NextFilteredUntil(spec.Definition, spec.Term).First()
+		}
+		if definitionElement.Length() == 0 {
+			return
+		}
+
+		term := CleanText(termElement.Text())
+		definition := CleanText(definitionElement.Text())
+		terms.add(term, definition)
+	})
+
+	return terms
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"scrape_cp/internal/atomicfile"
+)
+
+// sourceStateFile remembers each source's validators between runs, in
+// the directory given to SetStateDir.
+const sourceStateFile = "source_state.json"
+
+// sourceStates holds the validators from the latest fetch of each source,
+// loaded from disk on first use and written back whenever they change.
+type sourceStates struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	states map[string]validators
+}
+
+var sourceState = &sourceStates{path: filepath.Join("output", sourceStateFile)}
+
+// SetStateDir keeps the validators sources sent back in dir, which must be
+// set before the first scrape.
+func SetStateDir(dir string) {
+	sourceState.mu.Lock()
+	defer sourceState.mu.Unlock()
+
+	sourceState.path = filepath.Join(dir, sourceStateFile)
+}
+
+// load reads the state file if it hasn't been read yet. Callers must hold
+// s.mu.
+func (s *sourceStates) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.states = make(map[string]validators)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &s.states)
+	}
+	if err != nil {
+		slog.Warn("Ignoring source state", "file", s.path, "err", err)
+	}
+}
+
+func (s *sourceStates) get(source string) validators {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load()
+	return s.states[source]
+}
+
+func (s *sourceStates) set(source string, v validators) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load()
+	if s.states[source] == v {
+		return
+	}
+	s.states[source] = v
+
+	data, err := json.MarshalIndent(s.states, "", "    ")
+	if err == nil {
+		err = atomicfile.Write(s.path, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to save source state", "file", s.path, "err", err)
+	}
+}
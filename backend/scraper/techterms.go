@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// crawlTechTermsList parses one of TechTerms' alphabetical listing pages,
+// following each term on it and the listings for the other letters.
+func crawlTechTermsList(doc *goquery.Document, base *url.URL) crawlResult {
+	var result crawlResult
+
+	doc.Find(`a[href*="/list/"]`).Each(func(i int, a *goquery.Selection) {
+		if link := techTermsLink(base, a, "/list/"); link != "" {
+			result.follow = append(result.follow, crawlLink{url: link, parse: crawlTechTermsList})
+		}
+	})
+	doc.Find(`a[href*="/definition/"]`).Each(func(i int, a *goquery.Selection) {
+		if link := techTermsLink(base, a, "/definition/"); link != "" {
+			result.follow = append(result.follow, crawlLink{url: link, parse: crawlTechTermsDefinition})
+		}
+	})
+
+	return result
+}
+
+// techTermsLink resolves a's href if it points to a page under dir on
+// TechTerms itself.
+func techTermsLink(base *url.URL, a *goquery.Selection, dir string) string {
+	href, ok := a.Attr("href")
+	if !ok {
+		return ""
+	}
+	link := resolveLink(base, href)
+	u, err := url.Parse(link)
+	if err != nil || u.Host != base.Host || !strings.HasPrefix(u.Path, dir) {
+		return ""
+	}
+	return link
+}
+
+// techTermsSeeAlso matches the cross-reference sentence TechTerms ends
+// some definitions with.
+var techTermsSeeAlso = regexp.MustCompile(`\s*See also:?\s.*$`)
+
+// crawlTechTermsDefinition takes the first paragraph of a term's
+// definition page.
+func crawlTechTermsDefinition(doc *goquery.Document, base *url.URL) crawlResult {
+	terms := newExtracted()
+
+	term := CleanText(doc.Find("h1").First().Text())
+	doc.Find(".card p, article p").EachWithBreak(func(i int, p *goquery.Selection) bool {
+		definition := CleanText(p.Text())
+		if definition == "" {
+			return true
+		}
+		definition = techTermsSeeAlso.ReplaceAllString(definition, "")
+		terms.add(term, definition)
+		return false
+	})
+
+	return crawlResult{terms: terms}
+}
@@ -0,0 +1,58 @@
+package scraper
+
+import (
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCrawlTechTermsList(t *testing.T) {
+	base, _ := url.Parse("https://techterms.com/list/a")
+	result := crawlTechTermsList(loadFixture(t, "techterms_list.html"), base)
+
+	var lists, definitions []string
+	for _, link := range result.follow {
+		switch reflect.ValueOf(link.parse).Pointer() {
+		case reflect.ValueOf(crawlTechTermsList).Pointer():
+			lists = append(lists, link.url)
+		case reflect.ValueOf(crawlTechTermsDefinition).Pointer():
+			definitions = append(definitions, link.url)
+		default:
+			t.Errorf("%s is followed with an unknown parser", link.url)
+		}
+	}
+	// Links off the site or outside the listings and definitions aren't
+	// followed
+	wantLists := []string{
+		"https://techterms.com/list/a",
+		"https://techterms.com/list/b",
+		"https://techterms.com/list/c",
+	}
+	wantDefinitions := []string{
+		"https://techterms.com/definition/algorithm",
+		"https://techterms.com/definition/api",
+	}
+	if !slices.Equal(lists, wantLists) {
+		t.Errorf("listings followed = %q, want %q", lists, wantLists)
+	}
+	if !slices.Equal(definitions, wantDefinitions) {
+		t.Errorf("definitions followed = %q, want %q", definitions, wantDefinitions)
+	}
+	if len(result.terms.Terms) != 0 {
+		t.Errorf("a listing yielded terms: %q", result.terms.Terms)
+	}
+}
+
+func TestCrawlTechTermsDefinition(t *testing.T) {
+	base, _ := url.Parse("https://techterms.com/definition/algorithm")
+	result := crawlTechTermsDefinition(loadFixture(t, "techterms_definition.html"), base)
+
+	// Only the first paragraph with text is taken, without its "See also"
+	checkExtracted(t, result.terms, map[string][]string{
+		"Algorithm": {"An algorithm is a set of instructions designed to perform a specific task."},
+	}, 1, 0)
+	if len(result.follow) != 0 {
+		t.Errorf("a definition page was followed to %d more pages", len(result.follow))
+	}
+}
@@ -0,0 +1,35 @@
+package scraper
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the spans of scrapes. Until a tracer provider is set up,
+// which only happens when an OTLP endpoint is configured, its spans are
+// no-ops.
+var tracer = otel.Tracer("scrape_cp/scraper")
+
+// Attributes of the scraper's spans.
+const (
+	attrSource    = attribute.Key("scrape.source")
+	attrURL       = attribute.Key("url.full")
+	attrAttempts  = attribute.Key("scrape.attempts")
+	attrTerms     = attribute.Key("scrape.terms")
+	attrPages     = attribute.Key("scrape.pages")
+	attrUnchanged = attribute.Key("scrape.unchanged")
+)
+
+// endSpan ends span, marking it failed if err is. A page that hasn't
+// changed isn't a failure.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, errNotModified) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const DefaultGlossaryDepth = 1
+
+// GlossaryDepth is how many "See also" hops to follow from the Wikipedia
+// glossary; 0 scrapes only the glossary itself.
+var GlossaryDepth = DefaultGlossaryDepth
+
+// crawlWikipediaGlossaries returns a crawlFunc for a Wikipedia glossary
+// depth hops from the first one. It scrapes the page like
+// scrapeWikipediaTerms and, until GlossaryDepth is reached, follows the
+// glossaries its "See also" section links to.
+func crawlWikipediaGlossaries(depth int) crawlFunc {
+	return func(doc *goquery.Document, base *url.URL) crawlResult {
+		result := crawlResult{terms: scrapeWikipediaTerms(doc)}
+		if depth >= GlossaryDepth {
+			return result
+		}
+
+		heading := doc.Find("#See_also").Closest("h2")
+		if wrapper := heading.Parent(); wrapper.HasClass("mw-heading") {
+			heading = wrapper
+		}
+		heading.NextUntil("h2, div.mw-heading2").Find("a[href]").Each(func(i int, a *goquery.Selection) {
+			title := a.AttrOr("title", a.Text())
+			if !strings.HasPrefix(title, "Glossary of") {
+				return
+			}
+			link := resolveLink(base, a.AttrOr("href", ""))
+			if u, err := url.Parse(link); err == nil && u.Host == base.Host {
+				result.follow = append(result.follow, crawlLink{url: link, parse: crawlWikipediaGlossaries(depth + 1)})
+			}
+		})
+		return result
+	}
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// scrapeWikipediaTermsByBrackets is how Wikipedia's glossary used to be
+// scraped: every bracket was deleted from definitions and terms were cut
+// at the first one. It is kept to show what the fixture came out as.
+func scrapeWikipediaTermsByBrackets(doc *goquery.Document) map[string]string {
+	terms := make(map[string]string)
+	doc.Find("dl.glossary").Each(func(i int, dl *goquery.Selection) {
+		var term string
+		dl.Children().Each(func(j int, element *goquery.Selection) {
+			if element.Is("dt") {
+				term = strings.TrimSpace(strings.Split(CleanText(element.Text()), "[")[0])
+			} else if element.Is("dd") && term != "" {
+				terms[term] = strings.TrimSpace(strings.NewReplacer("[", "", "]", "").Replace(CleanText(element.Text())))
+			}
+		})
+	})
+	return terms
+}
+
+func TestScrapeWikipediaTermsReferences(t *testing.T) {
+	tests := []struct {
+		term string
+		// was is what cutting at brackets made of the definition
+		was  string
+		want string
+	}{
+		{
+			term: "array",
+			was:  "A data structure of elements each identified by an index, so that ai is the element at position i.12",
+			want: "A data structure of elements each identified by an index, so that a[i] is the element at position i.",
+		},
+		{
+			term: "Big O notation",
+			was:  "A notation that is used to describe how the running time of an algorithm grows with the size of its input.citation needed",
+			want: "A notation that [is] used to describe how the running time of an algorithm grows with the size of its input.",
+		},
+		{
+			term: "heap",
+			was:  "A tree-based data structure satisfying the heap property note 1 that is used to implement priority queues.4",
+			want: "A tree-based data structure satisfying the heap property that is used to implement priority queues.",
+		},
+		{
+			term: "half-open interval",
+			was:  "An interval such as 0, n) that contains its lower bound but not its upper one.citation needed",
+			want: "An interval such as [0, n) that contains its lower bound but not its upper one.",
+		},
+	}
+
+	before := scrapeWikipediaTermsByBrackets(loadFixture(t, "wikipedia_glossary.html"))
+	after := scrapeWikipediaTerms(loadFixture(t, "wikipedia_glossary.html"))
+	for _, tt := range tests {
+		if got := before[tt.term]; got != tt.was {
+			t.Errorf("cutting at brackets made %q of %s, the fixture no longer shows that: %q", tt.was, tt.term, got)
+		}
+		if got := after.Terms[tt.term]; len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+	if len(after.Terms) != len(tests) {
+		t.Errorf("got %d terms, want %d", len(after.Terms), len(tests))
+	}
+}
@@ -0,0 +1,102 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// crawlWiktionaryCategory parses a page of a Wiktionary category listing,
+// following each member entry and the link to the next page of members.
+func crawlWiktionaryCategory(doc *goquery.Document, base *url.URL) crawlResult {
+	var result crawlResult
+
+	doc.Find("#mw-pages .mw-category a").Each(func(i int, a *goquery.Selection) {
+		if href, ok := a.Attr("href"); ok {
+			if link := resolveLink(base, href); link != "" {
+				result.follow = append(result.follow, crawlLink{url: link, parse: crawlWiktionaryEntry})
+			}
+		}
+	})
+
+	doc.Find("#mw-pages > a").EachWithBreak(func(i int, a *goquery.Selection) bool {
+		if href, ok := a.Attr("href"); ok && strings.EqualFold(a.Text(), "next page") {
+			if link := resolveLink(base, href); link != "" {
+				result.follow = append(result.follow, crawlLink{url: link, parse: crawlWiktionaryCategory})
+			}
+			return false
+		}
+		return true
+	})
+
+	return result
+}
+
+// crawlWiktionaryEntry takes the first English sense of an entry that is
+// labelled as a computing sense.
+func crawlWiktionaryEntry(doc *goquery.Document, base *url.URL) crawlResult {
+	terms := newExtracted()
+
+	term := CleanText(doc.Find("#firstHeading").Text())
+	english := wiktionaryEnglishSection(doc)
+
+	english.Filter("ol").Children().Filter("li").EachWithBreak(func(i int, sense *goquery.Selection) bool {
+		label := sense.Find(".ib-content").First()
+		if !strings.Contains(strings.ToLower(label.Text()), "computing") {
+			return true
+		}
+
+		// Drop the label itself, usage examples and quotations
+		sense = sense.Clone()
+		sense.Find(".ib-brac, .ib-content, ul, ol, dl, .h-usage-example").Remove()
+		definition := CleanText(sense.Text())
+		terms.add(term, definition)
+		return false
+	})
+
+	return crawlResult{terms: terms}
+}
+
+// wiktionaryEnglishSection returns the elements between the English
+// heading and the next language's heading. Newer markup wraps headings in
+// a div.mw-heading.
+func wiktionaryEnglishSection(doc *goquery.Document) *goquery.Selection {
+	heading := doc.Find("h2#English")
+	if wrapper := heading.Parent(); wrapper.HasClass("mw-heading") {
+		heading = wrapper
+	}
+	return heading.NextUntil("h2, div.mw-heading2")
+}
+
+// wiktionaryAbbreviation matches senses that only say what a term is short
+// for.
+var wiktionaryAbbreviation = regexp.MustCompile(`^(Abbreviation|Initialism|Acronym|Clipping) of (.+?)\.?$`)
+
+// resolveWiktionaryAbbreviations expands senses like "Initialism of
+// central processing unit" with the definition of what they stand for,
+// when that is known from this crawl or to known.
+func resolveWiktionaryAbbreviations(terms map[string][]string, known func(term string) (string, bool)) {
+	for _, defs := range terms {
+		for i, definition := range defs {
+			m := wiktionaryAbbreviation.FindStringSubmatch(definition)
+			if m == nil {
+				continue
+			}
+
+			expansion := m[2]
+			var full string
+			found, ok := terms[expansion]
+			if ok {
+				full = found[0]
+			} else {
+				full, ok = known(expansion)
+			}
+			if ok && wiktionaryAbbreviation.FindStringSubmatch(full) == nil {
+				defs[i] = fmt.Sprintf("%s of %s. %s", m[1], expansion, full)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// acronymCandidate matches a bracketed single word that could be an
+// acronym, as in "Central processing unit (CPU)".
+var acronymCandidate = regexp.MustCompile(`\(([A-Za-z][A-Za-z0-9&.\-]{1,11})\)`)
+
+// shortFor matches definitions that open by expanding an acronym, as in
+// "CPU, short for central processing unit".
+var shortFor = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9&.\-]{1,11}),?\s+(?:short for|an? (?:abbreviation|acronym|initialism) (?:for|of))\s`)
+
+// acronymsFor returns the acronyms of name found in the names it was
+// scraped under and in the first sentence of its definitions. A candidate
+// only counts if it is mostly capitals and its letters can be read off
+// the words of name, so qualifiers like "Tree (data structure)" don't.
+func acronymsFor(name string, variants, defs []string) []string {
+	var candidates []string
+	for _, v := range variants {
+		for _, m := range acronymCandidate.FindAllStringSubmatch(v, -1) {
+			candidates = append(candidates, m[1])
+		}
+	}
+	for _, def := range defs {
+		first, _, _ := strings.Cut(def, ". ")
+		for _, m := range acronymCandidate.FindAllStringSubmatch(first, -1) {
+			candidates = append(candidates, m[1])
+		}
+		if m := shortFor.FindStringSubmatch(def); m != nil {
+			candidates = append(candidates, m[1])
+		}
+	}
+
+	words := tokenize(name)
+	var acronyms []string
+	for _, c := range candidates {
+		if mostlyUpper(c) && !strings.EqualFold(c, name) && abbreviates(letters(c), words) {
+			acronyms = append(acronyms, c)
+		}
+	}
+	return acronyms
+}
+
+// mostlyUpper reports whether at least half the letters in s are capitals.
+func mostlyUpper(s string) bool {
+	upper, total := 0, 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			total++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return total > 1 && upper*2 >= total
+}
+
+// letters returns the lowercased letters and digits of s.
+func letters(s string) string {
+	return strings.Join(tokenize(s), "")
+}
+
+// acronymFiller are words an acronym usually skips.
+var acronymFiller = map[string]bool{"a": true, "an": true, "and": true, "the": true, "of": true, "for": true, "in": true, "on": true, "to": true}
+
+// abbreviates reports whether acronym can be spelt by taking the first
+// letter of each word, and optionally more letters from within it, in
+// order. Filler words may be skipped; every other word must contribute.
+func abbreviates(acronym string, words []string) bool {
+	if acronym == "" {
+		for _, w := range words {
+			if !acronymFiller[w] {
+				return false
+			}
+		}
+		return true
+	}
+	if len(words) == 0 {
+		return false
+	}
+
+	w := words[0]
+	if w[0] != acronym[0] {
+		return acronymFiller[w] && abbreviates(acronym, words[1:])
+	}
+	// Take the word's first letter, then try taking more from inside it,
+	// as JSON does with "JavaScript"
+	rest := w[1:]
+	for n := 1; ; n++ {
+		if abbreviates(acronym[n:], words[1:]) {
+			return true
+		}
+		if n == len(acronym) {
+			break
+		}
+		i := strings.IndexByte(rest, acronym[n])
+		if i < 0 {
+			break
+		}
+		rest = rest[i+1:]
+	}
+	return acronymFiller[w] && abbreviates(acronym, words[1:])
+}
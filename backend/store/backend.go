@@ -0,0 +1,214 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Store backends accepted by --store.
+const (
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+	BackendBolt   = "bolt"
+	BackendRedis  = "redis"
+)
+
+var Backends = []string{BackendMemory, BackendSQLite, BackendBolt, BackendRedis}
+
+// Backend keeps the terms somewhere that outlives the process. The
+// in-memory store writes each change through to its backend, and is filled
+// from it at startup. Listings, lookups by name and searches of names are
+// answered by the backend itself; everything else reads the in-memory
+// index.
+type Backend interface {
+	// Upsert inserts terms or replaces those already stored by name
+	Upsert(terms []Term) error
+	Delete(name string) error
+	// Get finds a term by name ignoring case, preferring an exact match
+	Get(name string) (Term, bool, error)
+	// List returns terms in case-insensitive order of name; a negative
+	// limit returns all of them
+	List(offset, limit int) ([]Term, error)
+	// Search returns the terms whose name starts with query, or contains
+	// it if substring is set, ignoring case, in the order list uses
+	Search(query string, substring bool, limit int) ([]Term, error)
+	Count() (int, error)
+	// ScrapedAt returns when the terms were last scraped, or the zero
+	// time if they never have been
+	ScrapedAt() (time.Time, error)
+	// SetScraped records a finished scrape and the store's data version
+	// after it
+	SetScraped(at time.Time, version uint64) error
+	Close() error
+}
+
+// Watcher is implemented by backends other processes write to as well,
+// such as Redis shared by several replicas. Watch calls changed with the
+// terms another process stored and the names of those it deleted, in
+// the order it did so, until the backend is closed. Changes made from
+// the time Watch returns on are all passed on.
+type Watcher interface {
+	Watch(changed func(stored []Term, deleted []string)) error
+}
+
+// Noter is implemented by backends with a caveat worth reporting
+// in /stats, such as an operation that is costlier than it looks.
+type Noter interface {
+	Note() string
+}
+
+// Open opens the backend named by --store, keeping its terms in
+// the file at path or the Redis server at redisURL, or returns nil for the
+// in-memory default.
+func Open(name, path, redisURL string) (Backend, error) {
+	switch name {
+	case BackendMemory:
+		return nil, nil
+	case BackendSQLite:
+		return openSQLite(path)
+	case BackendBolt:
+		return openBolt(path)
+	case BackendRedis:
+		return openRedis(redisURL)
+	}
+	return nil, fmt.Errorf("--store must be one of %s", strings.Join(Backends, ", "))
+}
+
+// Attach fills the store with what b holds and writes every later change
+// through to it. If b is a Watcher, what other processes change in it is
+// changed in the store too.
+func (s *MemoryStore) Attach(b Backend) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Watching starts before the terms are listed so that no change in
+	// between is missed, but the changes wait for the lock, so none is
+	// overwritten by the older listing
+	if w, ok := b.(Watcher); ok {
+		if err := w.Watch(s.changedElsewhere); err != nil {
+			return fmt.Errorf("watching stored terms: %w", err)
+		}
+	}
+	terms, err := b.List(0, -1)
+	if err != nil {
+		return fmt.Errorf("loading stored terms: %w", err)
+	}
+	s.upsert(terms)
+	s.backend = b
+	return nil
+}
+
+// changedElsewhere applies what another process changed in the backend,
+// without writing it back.
+func (s *MemoryStore) changedElsewhere(stored []Term, deleted []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(stored) > 0 {
+		s.upsert(stored)
+	}
+	for _, name := range deleted {
+		s.remove(name)
+	}
+}
+
+// unlockAndPersist releases s.mu, then writes terms through to the
+// backend, if there is one. The write lock is taken first so writes reach
+// the backend in the order they were made, without holding up readers of
+// the store meanwhile. A failure leaves the backend behind the store until
+// the terms change again; the change isn't undone, but the error is
+// returned so that whoever asked for it can be told it wasn't saved.
+// Callers must hold s.mu.
+func (s *MemoryStore) unlockAndPersist(terms []Term) error {
+	b := s.backend
+	s.writes.Lock()
+	defer s.writes.Unlock()
+	s.mu.Unlock()
+
+	if b == nil || len(terms) == 0 {
+		return nil
+	}
+	if err := b.Upsert(terms); err != nil {
+		return fmt.Errorf("saving %d terms to the store: %w", len(terms), err)
+	}
+	return nil
+}
+
+// MarkScraped records in the backend that a scrape has just finished.
+func (s *MemoryStore) MarkScraped(at time.Time) {
+	s.mu.Lock()
+	b, version := s.backend, s.version
+	s.mu.Unlock()
+
+	if b == nil {
+		return
+	}
+	if err := b.SetScraped(at, version); err != nil {
+		slog.Error("Failed to record the scrape in the store", "err", err)
+	}
+}
+
+// ScrapedWithin reports whether b's terms were scraped no more than maxAge
+// ago.
+func ScrapedWithin(b Backend, maxAge time.Duration) bool {
+	at, err := b.ScrapedAt()
+	if err != nil {
+		slog.Warn("Can't tell when the stored terms were scraped", "err", err)
+		return false
+	}
+	return !at.IsZero() && time.Since(at) <= maxAge
+}
+
+// Persistent returns the backend, or nil when the terms are only in memory.
+func (s *MemoryStore) Persistent() Backend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.backend
+}
+
+// SearchNames searches the names of the terms in b as Search does with
+// FieldsTerm. b finds the names holding a word of the query, which are
+// ranked here; when every part must match, the longest word alone picks
+// them. b knows nothing of aliases, and stems needn't be part of the
+// words they match, so a search relying on either should use Search.
+func SearchNames(ctx context.Context, b Backend, query string, opts SearchOptions) ([]TermResponse, error) {
+	m, err := NewMatcher(query, opts)
+	if err != nil {
+		return []TermResponse{}, nil
+	}
+
+	words := m.words
+	if !m.any {
+		longest := slices.MaxFunc(words, func(a, b string) int { return cmp.Compare(len(a), len(b)) })
+		words = []string{longest}
+	}
+	seen := make(map[string]bool)
+	hits := []searchHit{}
+	for _, word := range slices.Compact(slices.Sorted(slices.Values(words))) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		terms, err := b.Search(word, true, -1)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range terms {
+			if seen[t.Name] {
+				continue
+			}
+			seen[t.Name] = true
+			if score := m.Rank(t.Name, t.Aliases, t.Definition, FieldsTerm); score > 0 {
+				result := t.Response()
+				result.Score = score
+				hits = append(hits, searchHit{strings.ToLower(t.Name), result})
+			}
+		}
+	}
+	return sortHits(hits), nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"scrape_cp/scraper"
+)
+
+// watchedBackend is a Backend other processes write to, holding terms in
+// memory and counting what is written through to it.
+type watchedBackend struct {
+	terms   []Term
+	writes  int
+	changed func(stored []Term, deleted []string)
+}
+
+func (b *watchedBackend) Upsert(terms []Term) error                { b.writes++; return nil }
+func (b *watchedBackend) Delete(name string) error                 { b.writes++; return nil }
+func (b *watchedBackend) Get(name string) (Term, bool, error)      { return Term{}, false, nil }
+func (b *watchedBackend) List(offset, limit int) ([]Term, error)   { return b.terms, nil }
+func (b *watchedBackend) Search(string, bool, int) ([]Term, error) { return nil, nil }
+func (b *watchedBackend) Count() (int, error)                      { return len(b.terms), nil }
+func (b *watchedBackend) ScrapedAt() (time.Time, error)            { return time.Time{}, nil }
+func (b *watchedBackend) SetScraped(time.Time, uint64) error       { return nil }
+func (b *watchedBackend) Close() error                             { return nil }
+
+func (b *watchedBackend) Watch(changed func(stored []Term, deleted []string)) error {
+	b.changed = changed
+	return nil
+}
+
+func TestAttachFollowsChangesElsewhere(t *testing.T) {
+	b := &watchedBackend{terms: []Term{
+		{Name: "Cache", Definition: "Fast memory."},
+		{Name: "Heap", Definition: "Memory allocated at run time."},
+	}}
+	s := NewMemoryStore()
+	if err := s.Attach(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.changed == nil {
+		t.Fatal("Attach didn't watch the backend")
+	}
+	sub := s.Events().Subscribe()
+	defer s.Events().Unsubscribe(sub)
+
+	// Another replica changes a term, adds one and deletes one
+	b.changed([]Term{
+		{Name: "Cache", Definition: "Small, fast memory close to the CPU."},
+		{Name: "Stack", Definition: "A last-in, first-out list."},
+	}, []string{"Heap"})
+
+	if got := s.Names("", ""); !slices.Equal(got, []string{"Cache", "Stack"}) {
+		t.Errorf("terms = %q, want Cache and Stack", got)
+	}
+	if term, _ := s.Get("Cache"); term.Definition != "Small, fast memory close to the CPU." {
+		t.Errorf("Cache = %q, want the other replica's definition", term.Definition)
+	}
+	want := []string{"updated Cache", "added Stack", "deleted Heap"}
+	if got := received(sub); !slices.Equal(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+	// What came from the backend isn't written back to it
+	if b.writes != 0 {
+		t.Errorf("%d writes went back to the backend", b.writes)
+	}
+}
+
+// refusingBackend refuses every write.
+type refusingBackend struct{ watchedBackend }
+
+func (*refusingBackend) Upsert([]Term) error { return errors.New("READONLY") }
+func (*refusingBackend) Delete(string) error { return errors.New("READONLY") }
+
+func TestFailedWritesThrough(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.Attach(&refusingBackend{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upsert([]Term{{Name: "Cache", Definition: "Fast memory."}}); err == nil {
+		t.Error("Upsert didn't fail")
+	}
+	if _, err := s.Merge(map[string][]string{"Heap": {"Memory allocated at run time, unlike a cache."}}, scraper.Source{Name: "Test"}); err == nil {
+		t.Error("Merge didn't fail")
+	}
+	if err := s.Link(); err == nil {
+		t.Error("Link didn't fail")
+	}
+	// The store has the changes all the same
+	if got := s.Names("", ""); !slices.Equal(got, []string{"Cache", "Heap"}) {
+		t.Errorf("terms = %q, want Cache and Heap", got)
+	}
+
+	if deleted, err := s.Delete("Heap"); !deleted || err == nil {
+		t.Errorf("Delete = %v, %v; want deleted with an error", deleted, err)
+	}
+	if _, exists := s.Get("Heap"); exists {
+		t.Error("Heap is still there")
+	}
+}
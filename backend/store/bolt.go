@@ -0,0 +1,190 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTerms = []byte("terms")
+	boltMeta  = []byte("meta")
+
+	boltLastScrape = []byte("last_scrape")
+	boltVersion    = []byte("version")
+)
+
+// boltBackend keeps the terms in a bbolt file, one JSON-encoded Term per
+// key. bbolt lets any number of read transactions run alongside the one
+// writer, so reads carry on while a scrape's terms are being saved.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+func openBolt(path string) (*boltBackend, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTerms, boltMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating buckets in %s: %w", path, err)
+	}
+	return &boltBackend{db: db}, nil
+}
+
+// boltKey is the lowercased name followed by the name itself, so keys sort
+// the way the in-memory store does and names differing only in case
+// don't collide.
+func boltKey(name string) []byte {
+	return []byte(strings.ToLower(name) + "\x00" + name)
+}
+
+// Upsert saves terms in a single transaction, so one scrape's worth of
+// changes costs one sync to disk.
+func (b *boltBackend) Upsert(terms []Term) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTerms)
+		for _, t := range terms {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("encoding %q: %w", t.Name, err)
+			}
+			if err := bucket.Put(boltKey(t.Name), data); err != nil {
+				return fmt.Errorf("saving %q: %w", t.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBackend) Delete(name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTerms).Delete(boltKey(name))
+	})
+}
+
+// Get finds name ignoring case, preferring an exact match.
+func (b *boltBackend) Get(name string) (Term, bool, error) {
+	prefix := []byte(strings.ToLower(name) + "\x00")
+	var found []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltTerms).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if found == nil || string(k[len(prefix):]) == name {
+				found = bytes.Clone(v)
+			}
+		}
+		return nil
+	})
+	if err != nil || found == nil {
+		return Term{}, false, err
+	}
+	var t Term
+	if err := json.Unmarshal(found, &t); err != nil {
+		return Term{}, false, fmt.Errorf("decoding %q: %w", name, err)
+	}
+	return t, true, nil
+}
+
+func (b *boltBackend) List(offset, limit int) ([]Term, error) {
+	return b.scan(nil, offset, limit, func([]byte) bool { return true })
+}
+
+// Search walks from the first key with query as its prefix when it can;
+// a substring search has to look at every key.
+func (b *boltBackend) Search(query string, substring bool, limit int) ([]Term, error) {
+	folded := []byte(strings.ToLower(query))
+	if substring {
+		return b.scan(nil, 0, limit, func(k []byte) bool {
+			name, _, _ := bytes.Cut(k, []byte{0})
+			return bytes.Contains(name, folded)
+		})
+	}
+	return b.scan(folded, 0, limit, func(k []byte) bool { return bytes.HasPrefix(k, folded) })
+}
+
+// scan decodes the terms from the key from onwards that match, skipping
+// offset of them and stopping after limit unless it is negative. It stops
+// at the first key past from's prefix when from is set.
+func (b *boltBackend) scan(from []byte, offset, limit int, match func(k []byte) bool) ([]Term, error) {
+	var terms []Term
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltTerms).Cursor()
+		k, v := c.First()
+		if from != nil {
+			k, v = c.Seek(from)
+		}
+		for ; k != nil && limit != 0; k, v = c.Next() {
+			if !match(k) {
+				if from != nil {
+					break
+				}
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			var t Term
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("decoding stored term: %w", err)
+			}
+			terms = append(terms, t)
+			limit--
+		}
+		return nil
+	})
+	return terms, err
+}
+
+func (b *boltBackend) Count() (int, error) {
+	var n int
+	err := b.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(boltTerms).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func (b *boltBackend) ScrapedAt() (time.Time, error) {
+	var at time.Time
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltMeta).Get(boltLastScrape); v != nil {
+			return at.UnmarshalText(v)
+		}
+		return nil
+	})
+	return at, err
+}
+
+func (b *boltBackend) SetScraped(at time.Time, version uint64) error {
+	text, err := at.MarshalText()
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMeta)
+		if err := meta.Put(boltLastScrape, text); err != nil {
+			return err
+		}
+		return meta.Put(boltVersion, binary.BigEndian.AppendUint64(nil, version))
+	})
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
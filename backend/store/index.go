@@ -0,0 +1,160 @@
+package store
+
+import (
+	"maps"
+	"strings"
+)
+
+// wordIndex is an inverted index from each word of the terms' names,
+// aliases and primary definitions to the terms it appears in, so a search
+// only has to rank the terms that could match it instead of every term.
+// It belongs to a MemoryStore and is guarded by its lock.
+type wordIndex struct {
+	// terms holds the names of the terms each word appears in
+	terms map[string]map[string]struct{}
+	// stems does the same for the stem of each word, for stemmed searches
+	stems map[string]map[string]struct{}
+	// words holds the words each term was indexed under, so they can be
+	// taken out again when it changes
+	words map[string][]string
+}
+
+func newWordIndex() wordIndex {
+	return wordIndex{
+		terms: make(map[string]map[string]struct{}),
+		stems: make(map[string]map[string]struct{}),
+		words: make(map[string][]string),
+	}
+}
+
+// update indexes t, replacing whatever it was indexed under before.
+func (ix wordIndex) update(t Term) {
+	ix.remove(t.Name)
+
+	seen := make(map[string]bool)
+	for _, text := range append([]string{t.Name, t.Definition}, t.Aliases...) {
+		for _, word := range tokenize(text) {
+			if seen[word] {
+				continue
+			}
+			seen[word] = true
+			post(ix.terms, word, t.Name)
+			post(ix.stems, stemWord(word), t.Name)
+			ix.words[t.Name] = append(ix.words[t.Name], word)
+		}
+	}
+}
+
+// remove takes the term stored as name out of the index.
+func (ix wordIndex) remove(name string) {
+	for _, word := range ix.words[name] {
+		unpost(ix.terms, word, name)
+		unpost(ix.stems, stemWord(word), name)
+	}
+	delete(ix.words, name)
+}
+
+// post adds name to the terms indexed under key.
+func post(index map[string]map[string]struct{}, key, name string) {
+	names := index[key]
+	if names == nil {
+		names = make(map[string]struct{})
+		index[key] = names
+	}
+	names[name] = struct{}{}
+}
+
+// unpost takes name out of the terms indexed under key.
+func unpost(index map[string]map[string]struct{}, key, name string) {
+	delete(index[key], name)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// candidates returns the names of the terms that could match m: those
+// with every word of every part of the query, or of any part for an OR.
+// Matching each word as a substring, as m does unless it is exact, finds
+// the terms with a word containing it, since a part of the query can only
+// occur where each of its words falls inside a word of the text. A
+// stemmed part also finds the terms with a word of the same stem. The
+// result is a superset of the matches, which m still has to rank.
+func (ix wordIndex) candidates(m Matcher) map[string]struct{} {
+	var all map[string]struct{}
+	for i, p := range m.parts {
+		var part map[string]struct{}
+		for j, word := range p.words {
+			names := ix.containing(word, !m.exact)
+			if p.stem != "" {
+				names = union(names, ix.stems[p.stem])
+			}
+			if j == 0 {
+				part = names
+			} else {
+				part = intersect(part, names)
+			}
+		}
+
+		switch {
+		case i == 0:
+			all = part
+		case m.any:
+			all = union(all, part)
+		default:
+			all = intersect(all, part)
+		}
+	}
+	return all
+}
+
+// containing returns the names of the terms indexed under word or, when
+// substring is set, under any word containing it. The result may be the
+// index's own set, so it must not be modified.
+func (ix wordIndex) containing(word string, substring bool) map[string]struct{} {
+	if !substring {
+		return ix.terms[word]
+	}
+
+	var found map[string]struct{}
+	copied := false
+	for indexed, names := range ix.terms {
+		if !strings.Contains(indexed, word) {
+			continue
+		}
+		switch {
+		case found == nil:
+			found = names
+		case !copied:
+			found = maps.Clone(found)
+			copied = true
+			fallthrough
+		default:
+			maps.Copy(found, names)
+		}
+	}
+	return found
+}
+
+// intersect returns the names in both a and b as a new set.
+func intersect(a, b map[string]struct{}) map[string]struct{} {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	both := make(map[string]struct{}, len(a))
+	for name := range a {
+		if _, ok := b[name]; ok {
+			both[name] = struct{}{}
+		}
+	}
+	return both
+}
+
+// union returns the names in either a or b as a new set.
+func union(a, b map[string]struct{}) map[string]struct{} {
+	either := maps.Clone(a)
+	if either == nil {
+		either = make(map[string]struct{}, len(b))
+	}
+	maps.Copy(either, b)
+	return either
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrape_cp/scraper"
+)
+
+// scanSearch is Search without the index, ranking every term.
+func scanSearch(s *MemoryStore, query string, opts SearchOptions) []TermResponse {
+	results := []TermResponse{}
+	m, err := NewMatcher(query, opts)
+	if err != nil {
+		return results
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for term, e := range s.terms {
+		if score := m.Rank(term, e.Aliases, e.Definition, opts.Fields); score > 0 {
+			result := e.Response()
+			result.Score = score
+			results = append(results, result)
+		}
+	}
+	slices.SortFunc(results, func(a, b TermResponse) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(strings.ToLower(a.Term), strings.ToLower(b.Term)),
+			cmp.Compare(a.Term, b.Term),
+		)
+	})
+	return results
+}
+
+var vocabulary = strings.Fields(`algorithm array binary bit buffer byte cache call
+	class compiler data database graph hash heap index interface kernel key
+	list lock memory network node object packet pointer process protocol queue
+	register routing search server socket stack stream string table thread
+	tree type value variable vector`)
+
+// generatedTerms returns n made-up terms of a few words each, the same
+// ones every time.
+func generatedTerms(n int) map[string][]string {
+	rng := rand.New(rand.NewPCG(1, 2))
+	words := func(k int) string {
+		picked := make([]string, k)
+		for i := range picked {
+			picked[i] = vocabulary[rng.IntN(len(vocabulary))]
+		}
+		return strings.Join(picked, " ")
+	}
+	terms := make(map[string][]string, n)
+	for i := range n {
+		name := fmt.Sprintf("%s %d", words(2), i)
+		terms[name] = []string{fmt.Sprintf("A %s that keeps the %s of a %s.", words(2), words(3), words(2))}
+	}
+	return terms
+}
+
+var searchQueries = []struct {
+	query string
+	opts  SearchOptions
+}{
+	{"cache", SearchOptions{}},
+	{"hash table", SearchOptions{}},
+	{"hash table", SearchOptions{Op: OpOr}},
+	{`"binary tree"`, SearchOptions{}},
+	{"memory pointer stack", SearchOptions{}},
+	{"ache", SearchOptions{}},
+	{"queue", SearchOptions{Exact: true}},
+	{"threads", SearchOptions{Stem: true}},
+	{"routing", SearchOptions{Fields: FieldsTerm}},
+	{"kernel", SearchOptions{Fields: FieldsDefinition, Exact: true}},
+}
+
+func TestIndexFindsWhatAScanDoes(t *testing.T) {
+	s := NewMemoryStore()
+	s.Merge(generatedTerms(2000), scraper.Source{Name: "Test"})
+	// Changes after the first merge must reach the index too
+	s.Merge(generatedTerms(200), scraper.Source{Name: "Other"})
+	s.Delete(s.Snapshot()[0].Name)
+
+	for _, q := range searchQueries {
+		got, want := names(search(t, s, q.query, q.opts)), names(scanSearch(s, q.query, q.opts))
+		if !slices.Equal(got, want) {
+			t.Errorf("search %q %+v found %d terms, a scan %d", q.query, q.opts, len(got), len(want))
+		}
+	}
+}
+
+var benchStore = sync.OnceValue(func() *MemoryStore {
+	s := NewMemoryStore()
+	s.Merge(generatedTerms(50_000), scraper.Source{Name: "Test"})
+	return s
+})
+
+// BenchmarkSearch compares searching 50,000 terms through the index with
+// ranking every one of them.
+func BenchmarkSearch(b *testing.B) {
+	s := benchStore()
+	b.ResetTimer()
+	for _, q := range searchQueries[:5] {
+		name := q.query
+		if q.opts.Op != "" {
+			name += " op=" + string(q.opts.Op)
+		}
+		b.Run("indexed/"+name, func(b *testing.B) {
+			for range b.N {
+				s.Search(context.Background(), q.query, q.opts)
+			}
+		})
+		b.Run("scan/"+name, func(b *testing.B) {
+			for range b.N {
+				scanSearch(s, q.query, q.opts)
+			}
+		})
+	}
+}
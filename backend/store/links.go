@@ -0,0 +1,186 @@
+package store
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// linkMatch is a mention of another term in a definition, at byte
+// offsets start to end.
+type linkMatch struct {
+	term       string
+	start, end int
+}
+
+// linker finds mentions of stored terms in text. Names are bucketed by
+// their first word, longest first, so the longest name wins where names
+// overlap.
+type linker struct {
+	byFirstWord map[string][]linkName
+}
+
+type linkName struct {
+	term  string
+	words []string
+}
+
+func newLinker(terms map[string]Term) *linker {
+	l := &linker{byFirstWord: make(map[string][]linkName)}
+	for term := range terms {
+		words := tokenize(mentionName(term))
+		if len(words) > 0 {
+			l.byFirstWord[words[0]] = append(l.byFirstWord[words[0]], linkName{term, words})
+		}
+	}
+	for _, names := range l.byFirstWord {
+		sort.Slice(names, func(i, j int) bool {
+			if len(names[i].words) != len(names[j].words) {
+				return len(names[i].words) > len(names[j].words)
+			}
+			return names[i].term < names[j].term
+		})
+	}
+	return l
+}
+
+// wordSpan is a word of a text and where it is.
+type wordSpan struct {
+	word       string
+	start, end int
+}
+
+// wordSpans splits text into words the way tokenize does, keeping their
+// byte offsets.
+func wordSpans(text string) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range text {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			spans = append(spans, wordSpan{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return spans
+}
+
+// matches returns the mentions of terms other than self in text, in
+// order and without overlaps, taking the longest name at each position.
+func (l *linker) matches(text, self string) []linkMatch {
+	spans := wordSpans(text)
+	var found []linkMatch
+	for i := 0; i < len(spans); {
+		n := 0
+		for _, name := range l.byFirstWord[spans[i].word] {
+			if name.term == self || len(name.words) > len(spans)-i {
+				continue
+			}
+			if spansEqual(spans[i:i+len(name.words)], name.words) {
+				found = append(found, linkMatch{name.term, spans[i].start, spans[i+len(name.words)-1].end})
+				n = len(name.words)
+				break
+			}
+		}
+		i += max(n, 1)
+	}
+	return found
+}
+
+func spansEqual(spans []wordSpan, words []string) bool {
+	for j, w := range words {
+		if spans[j].word != w {
+			return false
+		}
+	}
+	return true
+}
+
+// links returns the distinct terms text mentions, in the order they are
+// first mentioned.
+func (l *linker) links(text, self string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range l.matches(text, self) {
+		if !seen[m.term] {
+			seen[m.term] = true
+			names = append(names, m.term)
+		}
+	}
+	return names
+}
+
+// linkifyHTML escapes text as HTML and wraps each mention of another term
+// in a link to it, at the URL href returns for the term's name.
+func (l *linker) linkifyHTML(text, self string, href func(term string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range l.matches(text, self) {
+		b.WriteString(html.EscapeString(text[last:m.start]))
+		b.WriteString(`<a href="`)
+		b.WriteString(html.EscapeString(href(m.term)))
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString(`</a>`)
+		last = m.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// Link records on every term the other terms its definition mentions. It
+// runs after each scrape, once the store has everything the scrape found.
+// It fails as Upsert does if the changed terms couldn't be written through.
+func (s *MemoryStore) Link() (err error) {
+	s.mu.Lock()
+	var changed []Term
+	defer func() { err = s.unlockAndPersist(changed) }()
+
+	s.linker = newLinker(s.terms)
+	for name, t := range s.terms {
+		links := s.linker.links(t.Definition, name)
+		if !slices.Equal(links, t.Links) {
+			t.Links = links
+			s.terms[name] = t
+			changed = append(changed, t)
+		}
+	}
+	if len(changed) > 0 {
+		s.version++
+	}
+	return nil
+}
+
+// Linkified returns term's primary definition as HTML with links to the
+// other terms it mentions, each at base followed by the term's name.
+func (s *MemoryStore) Linkified(term, base string) (string, bool) {
+	return s.LinkifiedFunc(term, func(name string) string {
+		return base + url.PathEscape(name)
+	})
+}
+
+// LinkifiedFunc is Linkified with each link at the URL href returns for
+// the name of the term it mentions.
+func (s *MemoryStore) LinkifiedFunc(term string, href func(name string) string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, exists := s.lookup(term)
+	if !exists {
+		return "", false
+	}
+	s.lazy.Lock()
+	if s.linker == nil {
+		s.linker = newLinker(s.terms)
+	}
+	l := s.linker
+	s.lazy.Unlock()
+	return l.linkifyHTML(s.terms[name].Definition, name, href), true
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MergeFunc decides what a term's definitions become when a source
+// supplies it. existing is the term as stored, without anything the same
+// source said before, and is the zero Term for a term not seen yet;
+// incoming holds what the source just scraped. Only the Definitions of the
+// result are kept, the first being the primary one.
+type MergeFunc func(existing, incoming Term) Term
+
+// Names of the merge strategies --merge-strategy accepts.
+const (
+	MergeLongest      = "longest"
+	MergePreferSource = "prefer-source"
+	MergeFirstWins    = "first-wins"
+	MergeKeepBoth     = "keep-both"
+)
+
+var MergeStrategies = []string{MergeLongest, MergePreferSource, MergeFirstWins, MergeKeepBoth}
+
+// MergeStrategy is how MemoryStore.Merge settles conflicting definitions.
+var MergeStrategy MergeFunc = mergeKeepingBoth
+
+// MergeStrategyNamed returns the named strategy. priority lists sources
+// from most to least trusted, for prefer-source.
+func MergeStrategyNamed(name string, priority []string) (MergeFunc, error) {
+	switch name {
+	case MergeLongest:
+		return mergeKeepingLongest, nil
+	case MergePreferSource:
+		if len(priority) == 0 {
+			return nil, fmt.Errorf("the %s merge strategy needs a source priority list", MergePreferSource)
+		}
+		return mergePreferringSources(priority), nil
+	case MergeFirstWins:
+		return mergeKeepingFirst, nil
+	case MergeKeepBoth:
+		return mergeKeepingBoth, nil
+	}
+	return nil, fmt.Errorf("merge strategy must be one of %s", strings.Join(MergeStrategies, ", "))
+}
+
+// mergeKeepingLongest keeps only the longest definition, if there are any.
+func mergeKeepingLongest(existing, incoming Term) Term {
+	senses := byLength(append(slices.Clone(existing.Definitions), incoming.Definitions...))
+	return Term{Definitions: senses[:min(1, len(senses))]}
+}
+
+// mergePreferringSources keeps only the definition from the source that
+// comes first in priority, falling back to the longest among sources it
+// doesn't list.
+func mergePreferringSources(priority []string) MergeFunc {
+	rank := func(source string) int {
+		if i := slices.IndexFunc(priority, func(p string) bool { return strings.EqualFold(p, source) }); i >= 0 {
+			return i
+		}
+		return len(priority)
+	}
+	return func(existing, incoming Term) Term {
+		senses := byLength(append(slices.Clone(existing.Definitions), incoming.Definitions...))
+		sort.SliceStable(senses, func(i, j int) bool {
+			return rank(senses[i].Source) < rank(senses[j].Source)
+		})
+		return Term{Definitions: senses[:min(1, len(senses))]}
+	}
+}
+
+// mergeKeepingFirst keeps the definition that was seen first, only taking
+// one from incoming for a new term.
+func mergeKeepingFirst(existing, incoming Term) Term {
+	if len(existing.Definitions) > 0 {
+		return Term{Definitions: existing.Definitions[:1]}
+	}
+	return Term{Definitions: incoming.Definitions[:min(1, len(incoming.Definitions))]}
+}
+
+// mergeKeepingBoth keeps every distinct definition, longest first.
+// Definitions that are the same after cleaning, or that another one
+// already contains, are dropped in favour of the longer one.
+func mergeKeepingBoth(existing, incoming Term) Term {
+	senses := byLength(append(slices.Clone(existing.Definitions), incoming.Definitions...))
+
+	kept := senses[:0]
+	var folded []string
+	for _, sense := range senses {
+		f := strings.Join(tokenize(sense.Text), " ")
+		if !slices.ContainsFunc(folded, func(k string) bool { return strings.Contains(k, f) }) {
+			kept = append(kept, sense)
+			folded = append(folded, f)
+		}
+	}
+	return Term{Definitions: kept}
+}
+
+// byLength sorts senses longest first. The sort is stable, so among
+// definitions of the same length the ones already stored stay first.
+func byLength(senses []Sense) []Sense {
+	sort.SliceStable(senses, func(i, j int) bool {
+		return len(senses[i].Text) > len(senses[j].Text)
+	})
+	return senses
+}
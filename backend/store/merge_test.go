@@ -0,0 +1,82 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeStrategies(t *testing.T) {
+	// Wikipedia's definition is stored first; Coursera's is longer, and
+	// TechTerms' only restates part of Wikipedia's
+	existing := Term{Name: "Cache", Definitions: []Sense{
+		{Text: "Fast memory close to the CPU.", Source: "Wikipedia"},
+	}}
+	incoming := Term{Name: "Cache", Definitions: []Sense{
+		{Text: "A small, fast store of recently used data, kept close to the CPU.", Source: "Coursera"},
+		{Text: "fast memory close to the CPU", Source: "TechTerms"},
+	}}
+
+	tests := []struct {
+		strategy string
+		priority []string
+		want     []string
+	}{
+		{MergeLongest, nil, []string{"Coursera"}},
+		{MergePreferSource, []string{"TechTerms", "Wikipedia"}, []string{"TechTerms"}},
+		{MergePreferSource, []string{"wikipedia"}, []string{"Wikipedia"}},
+		// Sources not in the list fall back to the longest
+		{MergePreferSource, []string{"Britannica"}, []string{"Coursera"}},
+		{MergeFirstWins, nil, []string{"Wikipedia"}},
+		// TechTerms' is the same as Wikipedia's once cleaned, so is dropped
+		{MergeKeepBoth, nil, []string{"Coursera", "Wikipedia"}},
+	}
+	for _, tt := range tests {
+		merge, err := MergeStrategyNamed(tt.strategy, tt.priority)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.strategy, err)
+		}
+		got := merge(existing, incoming).Definitions
+		var sources []string
+		for _, sense := range got {
+			sources = append(sources, sense.Source)
+		}
+		if !slices.Equal(sources, tt.want) {
+			t.Errorf("%s %q kept definitions from %q, want %q", tt.strategy, tt.priority, sources, tt.want)
+		}
+	}
+}
+
+func TestMergeStrategiesNewTerm(t *testing.T) {
+	incoming := Term{Name: "Heap", Definitions: []Sense{
+		{Text: "Memory allocated at run time.", Source: "Wikipedia"},
+		{Text: "A tree in which every parent is ordered before its children.", Source: "Wikipedia"},
+	}}
+	tests := []struct {
+		strategy string
+		want     int
+	}{
+		{MergeLongest, 1},
+		{MergePreferSource, 1},
+		{MergeFirstWins, 1},
+		{MergeKeepBoth, 2},
+	}
+	for _, tt := range tests {
+		merge, _ := MergeStrategyNamed(tt.strategy, []string{"Wikipedia"})
+		if got := merge(Term{}, incoming).Definitions; len(got) != tt.want {
+			t.Errorf("%s kept %d of a new term's definitions, want %d", tt.strategy, len(got), tt.want)
+		}
+		// Nothing on either side leaves nothing, rather than panicking
+		if got := merge(Term{}, Term{Name: "Heap"}).Definitions; len(got) != 0 {
+			t.Errorf("%s made up definitions: %q", tt.strategy, got)
+		}
+	}
+}
+
+func TestMergeStrategyNamed(t *testing.T) {
+	if _, err := MergeStrategyNamed("newest", nil); err == nil {
+		t.Error("an unknown strategy was accepted")
+	}
+	if _, err := MergeStrategyNamed(MergePreferSource, nil); err == nil {
+		t.Error("prefer-source was accepted without a priority list")
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"scrape_cp/scraper"
+)
+
+// acronymSuffix matches an abbreviation given in brackets after a name, as
+// in "Abstract data type (ADT)". Qualifiers that are words, as in "Tree
+// (data structure)", tell different terms apart and are left alone.
+var acronymSuffix = regexp.MustCompile(`\s*\(([A-Z0-9][A-Za-z0-9.&/-]*)\)$`)
+
+// displayName returns the name a term is stored under, without a trailing
+// abbreviation.
+func displayName(raw string) string {
+	return strings.TrimSpace(acronymSuffix.ReplaceAllString(scraper.CleanText(raw), ""))
+}
+
+// normalizeKey folds the variants of a term name that mean the same thing
+// onto one key: it ignores case, a trailing abbreviation and a plain
+// plural ending on the last word.
+func normalizeKey(raw string) string {
+	words := strings.Fields(strings.ToLower(displayName(raw)))
+	if len(words) == 0 {
+		return ""
+	}
+	words[len(words)-1] = singular(words[len(words)-1])
+	return strings.Join(words, " ")
+}
+
+// singular strips a trivial plural ending: "strings" and "classes" fold
+// onto "string" and "class", while words like "bus", "process", "analysis"
+// and "canvas" that merely end in s are kept, as are "-ies" plurals, which
+// can't be told from words like "series".
+func singular(word string) string {
+	for _, suffix := range []string{"sses", "xes", "ches", "shes"} {
+		if strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, "es")
+		}
+	}
+	if len(word) <= 3 || !strings.HasSuffix(word, "s") {
+		return word
+	}
+	for _, ending := range []string{"ss", "us", "is", "as", "os", "ies"} {
+		if strings.HasSuffix(word, ending) {
+			return word
+		}
+	}
+	return strings.TrimSuffix(word, "s")
+}
+
+// termVariants is the names a scrape used for one term and their
+// definitions.
+type termVariants struct {
+	names []string
+	defs  []string
+}
+
+// groupVariants gathers scraped terms whose names normalize to the same
+// key, so variants from one source are merged together.
+func groupVariants(terms map[string][]string) map[string]*termVariants {
+	groups := make(map[string]*termVariants)
+	for name := range terms {
+		key := normalizeKey(name)
+		if key == "" {
+			continue
+		}
+		g := groups[key]
+		if g == nil {
+			g = &termVariants{}
+			groups[key] = g
+		}
+		g.names = append(g.names, name)
+	}
+	// Map order is random, so settle the order the variants and their
+	// definitions are seen in
+	for _, g := range groups {
+		slices.Sort(g.names)
+		for _, name := range g.names {
+			g.defs = append(g.defs, terms[name]...)
+		}
+	}
+	return groups
+}
+
+// canonicalName picks the name a new term is stored under from the
+// variants of it: preferably one already in singular form, otherwise the
+// first.
+func (g *termVariants) canonicalName(key string) string {
+	for _, name := range g.names {
+		if strings.ToLower(displayName(name)) == key {
+			return displayName(name)
+		}
+	}
+	return displayName(g.names[0])
+}
+
+// withAliases returns aliases with every variant that differs from name by
+// more than case added, sorted and without duplicates. aliases itself is
+// left alone.
+func withAliases(aliases []string, name string, variants []string) []string {
+	merged := slices.Clone(aliases)
+	for _, v := range variants {
+		if !strings.EqualFold(v, name) && !slices.Contains(merged, v) {
+			merged = append(merged, v)
+		}
+	}
+	slices.Sort(merged)
+	return merged
+}
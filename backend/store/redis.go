@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultRedisURL = "redis://localhost:6379/0"
+
+// Keys the Redis backend uses. Every term is a JSON string under
+// redisTermPrefix plus its member of redisNames, a sorted set whose
+// members all score 0 so they sort by name, lowercased first as boltKey
+// does. Every change is announced on redisChanges as a redisChange.
+const (
+	redisNames      = "scrape_cp:names"
+	redisTermPrefix = "scrape_cp:term:"
+	redisMeta       = "scrape_cp:meta"
+	redisChanges    = "scrape_cp:changes"
+)
+
+// redisChange announces what a replica changed: the members of the terms
+// it stored, those it tried to but found a longer definition already
+// there for, and the names of the terms it deleted.
+type redisChange struct {
+	Origin  string   `json:"origin"`
+	Stored  []string `json:"stored,omitempty"`
+	Kept    []string `json:"kept,omitempty"`
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// redisUpsertBatch is how many terms go to the upsert script at once.
+const redisUpsertBatch = 500
+
+// redisUpsert saves terms atomically, keeping a stored term instead when
+// its definition is longer, so replicas scraping at the same time settle
+// on the same data. That is the longest merge strategy, which is why it is
+// the only one --store=redis allows. KEYS are the name set and then each
+// term's key; ARGV holds the member, JSON and definition length of each
+// term in turn. It returns the members it stored.
+var redisUpsert = redis.NewScript(`
+local stored = {}
+for i = 2, #KEYS do
+	local j = (i - 2) * 3
+	local current = redis.call('GET', KEYS[i])
+	if not current or #cjson.decode(current).definition <= tonumber(ARGV[j + 3]) then
+		redis.call('SET', KEYS[i], ARGV[j + 2])
+		redis.call('ZADD', KEYS[1], 0, ARGV[j + 1])
+		stored[#stored + 1] = ARGV[j + 1]
+	end
+end
+return stored
+`)
+
+// redisBackend keeps the terms in Redis, so several replicas can share
+// them. origin tells its own announcements on redisChanges apart from
+// other replicas'.
+type redisBackend struct {
+	client  *redis.Client
+	origin  string
+	changes *redis.PubSub
+}
+
+// openRedis connects to the server at url, failing if it can't be reached.
+func openRedis(url string) (*redisBackend, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --redis-url: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis at %s: %w", opts.Addr, err)
+	}
+	var origin [16]byte
+	rand.Read(origin[:])
+	return &redisBackend{client: client, origin: hex.EncodeToString(origin[:])}, nil
+}
+
+// Note warns that substring search reads every name; it is shown in
+// /stats.
+func (b *redisBackend) Note() string {
+	return "substring search scans every term name with ZSCAN, so it costs time in proportion to the number of terms"
+}
+
+// Upsert saves terms in batches, announcing each one.
+func (b *redisBackend) Upsert(terms []Term) error {
+	ctx := context.Background()
+	for batch := range slices.Chunk(terms, redisUpsertBatch) {
+		keys := []string{redisNames}
+		members := make([]string, 0, len(batch))
+		args := make([]any, 0, 3*len(batch))
+		for _, t := range batch {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return fmt.Errorf("encoding %q: %w", t.Name, err)
+			}
+			member := string(boltKey(t.Name))
+			keys = append(keys, redisTermPrefix+member)
+			members = append(members, member)
+			args = append(args, member, data, len(t.Definition))
+		}
+		stored, err := redisUpsert.Run(ctx, b.client, keys, args...).StringSlice()
+		if err != nil {
+			return err
+		}
+		kept := slices.DeleteFunc(members, func(m string) bool { return slices.Contains(stored, m) })
+		if len(kept) > 0 {
+			slog.Info("Kept longer definitions already in Redis", "definitions", len(kept))
+		}
+		if err := b.announce(ctx, redisChange{Stored: stored, Kept: kept}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *redisBackend) Delete(name string) error {
+	ctx := context.Background()
+	member := string(boltKey(name))
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, redisNames, member)
+		pipe.Del(ctx, redisTermPrefix+member)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.announce(ctx, redisChange{Deleted: []string{name}})
+}
+
+// announce publishes change on redisChanges as coming from b.
+func (b *redisBackend) announce(ctx context.Context, change redisChange) error {
+	change.Origin = b.origin
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisChanges, data).Err()
+}
+
+// Watch subscribes to redisChanges, passing on what other replicas
+// stored and deleted, and reloading the terms this one found longer
+// definitions of in Redis, so every replica serves what Redis holds.
+// Redis doesn't keep announcements, so any made while the connection is
+// being reestablished are missed until the terms change again.
+func (b *redisBackend) Watch(changed func(stored []Term, deleted []string)) error {
+	ctx := context.Background()
+	b.changes = b.client.Subscribe(ctx, redisChanges)
+	// Receive waits for the subscription to be confirmed, after which no
+	// announcement is missed
+	if _, err := b.changes.Receive(ctx); err != nil {
+		b.changes.Close()
+		return err
+	}
+
+	go func() {
+		for msg := range b.changes.Channel() {
+			var change redisChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				slog.Warn("Ignoring a malformed change announced in Redis", "err", err)
+				continue
+			}
+			reload, deleted := change.Stored, change.Deleted
+			if change.Origin == b.origin {
+				reload, deleted = change.Kept, nil
+			}
+			if len(reload) == 0 && len(deleted) == 0 {
+				continue
+			}
+			terms, err := b.load(reload)
+			if err != nil {
+				slog.Error("Failed to load terms changed in Redis", "terms", len(reload), "err", err)
+				continue
+			}
+			changed(terms, deleted)
+		}
+	}()
+	return nil
+}
+
+// Get finds name ignoring case, preferring an exact match.
+func (b *redisBackend) Get(name string) (Term, bool, error) {
+	prefix := strings.ToLower(name) + "\x00"
+	members, err := b.client.ZRangeByLex(context.Background(), redisNames, &redis.ZRangeBy{
+		Min: "[" + prefix,
+		Max: "[" + prefix + "\xff",
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return Term{}, false, err
+	}
+	member := members[0]
+	if i := slices.Index(members, prefix+name); i >= 0 {
+		member = members[i]
+	}
+	terms, err := b.load([]string{member})
+	if err != nil || len(terms) == 0 {
+		return Term{}, false, err
+	}
+	return terms[0], true, nil
+}
+
+func (b *redisBackend) List(offset, limit int) ([]Term, error) {
+	stop := int64(-1)
+	if limit >= 0 {
+		if limit == 0 {
+			return nil, nil
+		}
+		stop = int64(offset + limit - 1)
+	}
+	members, err := b.client.ZRange(context.Background(), redisNames, int64(offset), stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return b.load(members)
+}
+
+func (b *redisBackend) Search(query string, substring bool, limit int) ([]Term, error) {
+	ctx := context.Background()
+	folded := strings.ToLower(query)
+	if !substring {
+		by := &redis.ZRangeBy{Min: "[" + folded, Max: "[" + folded + "\xff"}
+		if limit >= 0 {
+			by.Count = int64(limit)
+		}
+		members, err := b.client.ZRangeByLex(ctx, redisNames, by).Result()
+		if err != nil {
+			return nil, err
+		}
+		return b.load(members)
+	}
+
+	// Redis has no index for substrings, so every name is looked at
+	var members []string
+	iter := b.client.ZScan(ctx, redisNames, 0, "*"+globEscaper.Replace(folded)+"*", 1000).Iterator()
+	for i := 0; iter.Next(ctx); i++ {
+		// ZSCAN returns each member followed by its score
+		if i%2 == 0 {
+			members = append(members, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(members)
+	members = slices.Compact(members)
+	if limit >= 0 && len(members) > limit {
+		members = members[:limit]
+	}
+	return b.load(members)
+}
+
+// globEscaper escapes the characters MATCH patterns treat specially.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// load fetches and decodes the terms stored under members, skipping any
+// removed in the meantime.
+func (b *redisBackend) load(members []string) ([]Term, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = redisTermPrefix + member
+	}
+	values, err := b.client.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := make([]Term, 0, len(values))
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var t Term
+		if err := json.Unmarshal([]byte(data), &t); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", keys[i], err)
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+func (b *redisBackend) Count() (int, error) {
+	n, err := b.client.ZCard(context.Background(), redisNames).Result()
+	return int(n), err
+}
+
+func (b *redisBackend) ScrapedAt() (time.Time, error) {
+	value, err := b.client.HGet(context.Background(), redisMeta, "last_scrape").Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (b *redisBackend) SetScraped(at time.Time, version uint64) error {
+	return b.client.HSet(context.Background(), redisMeta,
+		"last_scrape", at.Format(time.RFC3339Nano),
+		"version", strconv.FormatUint(version, 10),
+	).Err()
+}
+
+func (b *redisBackend) Close() error {
+	if b.changes != nil {
+		b.changes.Close()
+	}
+	return b.client.Close()
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// crossRefs maps each term to the other terms its definition mentions and
+// how many times it mentions them.
+type crossRefs map[string]map[string]int
+
+// mentionName is the part of a term name that is looked for in
+// definitions. Qualifiers like "Tree (data structure)" never appear
+// verbatim in running text, so they are dropped.
+func mentionName(term string) string {
+	if i := strings.Index(term, " ("); i != -1 {
+		return term[:i]
+	}
+	return term
+}
+
+// buildCrossRefs scans every definition once for whole-word mentions of
+// every term name. Names are bucketed by their first word so each word of
+// a definition is only compared against the names that could start there.
+func buildCrossRefs(terms map[string]Term) crossRefs {
+	type candidate struct {
+		term  string
+		words []string
+	}
+	byFirstWord := make(map[string][]candidate)
+	for term := range terms {
+		words := tokenize(mentionName(term))
+		if len(words) > 0 {
+			byFirstWord[words[0]] = append(byFirstWord[words[0]], candidate{term, words})
+		}
+	}
+
+	refs := make(crossRefs, len(terms))
+	for term, e := range terms {
+		tokens := tokenize(e.Definition)
+		for i, token := range tokens {
+			for _, c := range byFirstWord[token] {
+				if c.term == term || len(c.words) > len(tokens)-i {
+					continue
+				}
+				if containsWords(tokens[i:i+len(c.words)], c.words) {
+					if refs[term] == nil {
+						refs[term] = make(map[string]int)
+					}
+					refs[term][c.term]++
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// Related returns the terms connected to term through mentions in either
+// direction, ranked by how many mentions link them.
+func (s *MemoryStore) Related(term string, limit int) (string, []TermResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, exists := s.lookup(term)
+	if !exists {
+		return "", nil, false
+	}
+
+	// Readers share the lock, so the first one after a change rebuilds the
+	// references for the rest
+	s.lazy.Lock()
+	if s.refs == nil || s.refsStale {
+		s.refs = buildCrossRefs(s.terms)
+		s.refsStale = false
+	}
+	refs := s.refs
+	s.lazy.Unlock()
+
+	scores := make(map[string]int)
+	for other, n := range refs[name] {
+		scores[other] += n
+	}
+	for other, mentions := range refs {
+		if n := mentions[name]; n > 0 {
+			scores[other] += n
+		}
+	}
+
+	related := make([]TermResponse, 0, len(scores))
+	for other, score := range scores {
+		result := s.terms[other].Response()
+		result.Score = score
+		related = append(related, result)
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+		return strings.ToLower(related[i].Term) < strings.ToLower(related[j].Term)
+	})
+
+	return name, related[:min(limit, len(related))], true
+}
@@ -0,0 +1,697 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	DefaultFuzzyDistance = 2
+	MaxFuzzyDistance     = 4
+
+	// cancelCheckInterval is how many terms Search and Fuzzy compare
+	// between checks that their caller still wants the results
+	cancelCheckInterval = 256
+)
+
+// SearchFields selects which parts of an entry a query is matched against.
+type SearchFields string
+
+const (
+	FieldsTerm       SearchFields = "term"
+	FieldsDefinition SearchFields = "definition"
+	FieldsBoth       SearchFields = "both"
+)
+
+func ParseSearchFields(raw string) (SearchFields, error) {
+	switch f := SearchFields(raw); f {
+	case "":
+		return FieldsBoth, nil
+	case FieldsTerm, FieldsDefinition, FieldsBoth:
+		return f, nil
+	}
+	return "", fmt.Errorf("fields must be one of %s, %s or %s", FieldsTerm, FieldsDefinition, FieldsBoth)
+}
+
+// SearchOp says whether a query's parts must all match an entry or only
+// one of them.
+type SearchOp string
+
+const (
+	OpAnd SearchOp = "and"
+	OpOr  SearchOp = "or"
+)
+
+func ParseSearchOp(raw string) (SearchOp, error) {
+	switch op := SearchOp(raw); op {
+	case "":
+		return OpAnd, nil
+	case OpAnd, OpOr:
+		return op, nil
+	}
+	return "", fmt.Errorf("op must be %s or %s", OpAnd, OpOr)
+}
+
+type SearchOptions struct {
+	Fields SearchFields
+	// Exact requires the query to match whole words rather than any
+	// substring, so "cache" no longer matches "caches".
+	Exact bool
+	// Op is OpOr to match entries containing any part of the query rather
+	// than all of them
+	Op SearchOp
+	// Stem also matches each word of the query against words with the
+	// same stem, so "compiling" finds "Compiler". Quoted phrases and exact
+	// searches are never stemmed.
+	Stem bool
+}
+
+// ErrEmptyQuery is a query with no words in it to match.
+var ErrEmptyQuery = errors.New("search query has no words to match")
+
+// Relevance scores for search hits. Matches of the whole query on the term
+// name always outrank entries that need the definition to match.
+const (
+	scoreExactName      = 100
+	scorePrefixName     = 75
+	scoreSubstringName  = 50
+	scoreDefinitionOnly = 25
+	// scoreNamedParts is shared among the parts of a query matched in the
+	// name when the rest are only in the definition
+	scoreNamedParts = 15
+	// scoreCloseness rewards definitions where the parts of a query are
+	// close together; it halves every closeWindow bytes apart they are
+	scoreCloseness = 5
+	closeWindow    = 40
+)
+
+// maxOccurrences caps how many matches of each part of a query are
+// weighed when judging how close together they are.
+const maxOccurrences = 50
+
+// Matcher compares entries against a query. The query is split on
+// whitespace into parts, each matched on its own, except that a quoted
+// phrase is one part. Parts match as plain substrings or, when exact is
+// set, as runs of whole words. When stemming, a part that is a single word
+// also matches words with the same stem.
+type Matcher struct {
+	query string
+	parts []queryPart
+	// joined is the parts as one string and words all of their words,
+	// for comparing the whole query against names
+	joined  string
+	words   []string
+	exact   bool
+	any     bool
+	stemmed bool
+}
+
+// queryPart is a word of a query or a quoted phrase, lowercased. stem is
+// the stem of a lone word when the query is stemmed.
+type queryPart struct {
+	text  string
+	words []string
+	stem  string
+}
+
+// NewMatcher parses query. It fails with ErrEmptyQuery if nothing in it
+// has a letter or digit to match.
+func NewMatcher(query string, opts SearchOptions) (Matcher, error) {
+	m := Matcher{query: strings.ToLower(strings.TrimSpace(query)), exact: opts.Exact, any: opts.Op == OpOr}
+	add := func(text string, phrase bool) {
+		text = strings.Join(strings.Fields(text), " ")
+		words := tokenize(text)
+		if len(words) == 0 {
+			return
+		}
+		p := queryPart{text: text, words: words}
+		// Only plain words are stemmed; "c++" is not the word "c"
+		if opts.Stem && !opts.Exact && !phrase && len(words) == 1 && words[0] == text {
+			p.stem = stemWord(text)
+			m.stemmed = true
+		}
+		m.parts = append(m.parts, p)
+		m.words = append(m.words, words...)
+	}
+
+	// An unterminated quote runs to the end of the query
+	for rest := m.query; rest != ""; {
+		before, after, quoted := strings.Cut(rest, `"`)
+		for _, word := range strings.Fields(before) {
+			add(strings.Trim(word, ",;:!?()[]{}"), false)
+		}
+		if !quoted {
+			break
+		}
+		phrase, after, _ := strings.Cut(after, `"`)
+		add(phrase, true)
+		rest = after
+	}
+
+	if len(m.parts) == 0 {
+		return m, ErrEmptyQuery
+	}
+	texts := make([]string, len(m.parts))
+	for i, p := range m.parts {
+		texts[i] = p.text
+	}
+	m.joined = strings.Join(texts, " ")
+	return m, nil
+}
+
+// Query returns the query being matched, lowercased.
+func (m Matcher) Query() string {
+	return m.query
+}
+
+// Stemmed reports whether any word of the query is matched by its stem.
+func (m Matcher) Stemmed() bool {
+	return m.stemmed
+}
+
+// searchText is text prepared for matching parts against.
+type searchText struct {
+	lower  string
+	tokens []string
+	stems  []string
+}
+
+func (m Matcher) prepare(text string) searchText {
+	t := searchText{lower: strings.ToLower(text)}
+	if m.exact || m.stemmed {
+		t.tokens = tokenize(text)
+	}
+	if m.stemmed {
+		t.stems = stemWords(t.tokens)
+	}
+	return t
+}
+
+func (m Matcher) matchesPart(t searchText, p queryPart) bool {
+	if !m.exact {
+		return strings.Contains(t.lower, p.text) || p.stem != "" && slices.Contains(t.stems, p.stem)
+	}
+	return containsWords(t.tokens, p.words)
+}
+
+// nameScore rates how well the whole query matches name: as the whole
+// name, at its start, or with every part somewhere inside it. It returns 0
+// when some part isn't in the name.
+func (m Matcher) nameScore(name string) int {
+	t := m.prepare(name)
+	for _, p := range m.parts {
+		if !m.matchesPart(t, p) {
+			return 0
+		}
+	}
+
+	if !m.exact {
+		switch {
+		case t.lower == m.joined:
+			return scoreExactName
+		case strings.HasPrefix(t.lower, m.joined):
+			return scorePrefixName
+		}
+		return scoreSubstringName
+	}
+	switch {
+	case slices.Equal(t.tokens, m.words):
+		return scoreExactName
+	case len(t.tokens) >= len(m.words) && slices.Equal(t.tokens[:len(m.words)], m.words):
+		return scorePrefixName
+	}
+	return scoreSubstringName
+}
+
+// Rank scores an entry against the query, looking at its name and aliases,
+// its definition or both as fields says, and returns 0 if it doesn't
+// match. Unless the query is an OR, every part must be in the name or the
+// definition. Entries whose name holds the whole query come first; the
+// rest are ranked by how many parts they match, how many of those are in
+// the name and how close together they are in the definition.
+func (m Matcher) Rank(name string, aliases []string, definition string, fields SearchFields) int {
+	if len(m.parts) == 0 {
+		return 0
+	}
+
+	var names []searchText
+	if fields != FieldsDefinition {
+		score := m.nameScore(name)
+		for _, alias := range aliases {
+			score = max(score, m.nameScore(alias))
+		}
+		if score > 0 {
+			return score
+		}
+		names = append(names, m.prepare(name))
+		for _, alias := range aliases {
+			names = append(names, m.prepare(alias))
+		}
+	}
+	var def searchText
+	if fields != FieldsTerm {
+		def = m.prepare(definition)
+	}
+
+	matched, named := 0, 0
+	var inDefinition []queryPart
+	for _, p := range m.parts {
+		inName := slices.ContainsFunc(names, func(t searchText) bool { return m.matchesPart(t, p) })
+		inDef := fields != FieldsTerm && m.matchesPart(def, p)
+		if inName {
+			named++
+		}
+		if inDef {
+			inDefinition = append(inDefinition, p)
+		}
+		if inName || inDef {
+			matched++
+		}
+	}
+	if matched == 0 || !m.any && matched < len(m.parts) {
+		return 0
+	}
+
+	n := len(m.parts)
+	score := scoreDefinitionOnly*matched/n + scoreNamedParts*named/n + m.closeness(def.lower, inDefinition)
+	return max(score, 1)
+}
+
+// closeness rewards a definition for having parts close together: the
+// nearer the tightest stretch of lower holding one match of each, the
+// higher, up to scoreCloseness.
+func (m Matcher) closeness(lower string, parts []queryPart) int {
+	if len(parts) < 2 {
+		return 0
+	}
+
+	type occurrence struct{ at, part int }
+	var all []occurrence
+	for i, p := range parts {
+		for _, at := range m.occurrences(lower, p.text) {
+			all = append(all, occurrence{at, i})
+		}
+	}
+	slices.SortFunc(all, func(a, b occurrence) int { return a.at - b.at })
+
+	// Slide a window over the matches in order, shrinking it from the
+	// left whenever it still holds every part
+	span := -1
+	counts := make([]int, len(parts))
+	covered, left := 0, 0
+	for _, o := range all {
+		if counts[o.part]++; counts[o.part] == 1 {
+			covered++
+		}
+		for covered == len(parts) {
+			if width := o.at - all[left].at; span < 0 || width < span {
+				span = width
+			}
+			if counts[all[left].part]--; counts[all[left].part] == 0 {
+				covered--
+			}
+			left++
+		}
+	}
+	if span < 0 {
+		// Exact parts can match across punctuation that a plain search
+		// of the text doesn't see past, and stemmed ones words it spells
+		// differently
+		return 0
+	}
+	return scoreCloseness * closeWindow / (closeWindow + span)
+}
+
+// occurrences returns the byte offsets of up to maxOccurrences matches of
+// text in lower, only on word boundaries in exact mode.
+func (m Matcher) occurrences(lower, text string) []int {
+	var offsets []int
+	for from := 0; len(offsets) < maxOccurrences; {
+		i := strings.Index(lower[from:], text)
+		if i < 0 {
+			break
+		}
+		at, end := from+i, from+i+len(text)
+		before, _ := utf8.DecodeLastRuneInString(lower[:at])
+		after, _ := utf8.DecodeRuneInString(lower[end:])
+		if !m.exact || !isWordRune(before) && !isWordRune(after) {
+			offsets = append(offsets, at)
+		}
+		_, size := utf8.DecodeRuneInString(lower[at:])
+		from = at + size
+	}
+	return offsets
+}
+
+// Search returns the entries matching query in the selected fields, most
+// relevant first and alphabetically among equally relevant hits. A query
+// NewMatcher rejects matches nothing. It gives up with ctx's error once ctx
+// is done, so a large store isn't searched for a client that has gone.
+func (s *MemoryStore) Search(ctx context.Context, query string, opts SearchOptions) ([]TermResponse, error) {
+	results := []TermResponse{}
+	m, err := NewMatcher(query, opts)
+	if err != nil {
+		return results, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Only the terms the index says could match are ranked
+	var hits []searchHit
+	ranked := 0
+	for term := range s.inverted.candidates(m) {
+		if ranked%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		ranked++
+		e := s.terms[term]
+		if score := m.Rank(term, e.Aliases, e.Definition, opts.Fields); score > 0 {
+			result := e.Response()
+			result.Score = score
+			hits = append(hits, searchHit{strings.ToLower(term), result})
+		}
+	}
+	return sortHits(hits), nil
+}
+
+// searchHit is a ranked result with its folded name, for sorting.
+type searchHit struct {
+	folded string
+	result TermResponse
+}
+
+// sortHits returns the results of hits, most relevant first. Ties are
+// broken the way keys are ordered, so the order is stable between
+// requests.
+func sortHits(hits []searchHit) []TermResponse {
+	slices.SortFunc(hits, func(a, b searchHit) int {
+		return cmp.Or(
+			cmp.Compare(b.result.Score, a.result.Score),
+			cmp.Compare(a.folded, b.folded),
+			cmp.Compare(a.result.Term, b.result.Term),
+		)
+	})
+	results := make([]TermResponse, len(hits))
+	for i, h := range hits {
+		results[i] = h.result
+	}
+	return results
+}
+
+// tokenize lowercases text and splits it into words, treating anything that
+// isn't a letter or digit as a separator so punctuation next to a word
+// doesn't stop it from matching.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// containsWords reports whether words appears as a consecutive run in text.
+func containsWords(text, words []string) bool {
+	for i := 0; i+len(words) <= len(text); i++ {
+		match := true
+		for j, w := range words {
+			if text[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Fuzzy returns the terms whose names are within maxDistance edits of
+// query, closest first and alphabetically within the same distance. Only
+// names are compared; definitions are far too long for edit distance to be
+// meaningful or cheap. Like Search, it gives up with ctx's error once ctx is
+// done.
+func (s *MemoryStore) Fuzzy(ctx context.Context, query string, maxDistance int) ([]TermResponse, error) {
+	q := []rune(strings.ToLower(query))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := []TermResponse{}
+	for i, term := range s.keys {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		name := []rune(s.folded[i])
+		if abs(len(name)-len(q)) > maxDistance {
+			continue
+		}
+		if d := levenshtein(q, name, maxDistance); d <= maxDistance {
+			result := s.terms[term].Response()
+			result.Distance = &d
+			results = append(results, result)
+		}
+	}
+
+	// keys are already alphabetical, so a stable sort keeps that order
+	// within each distance
+	sort.SliceStable(results, func(i, j int) bool {
+		return *results[i].Distance < *results[j].Distance
+	})
+	return results, nil
+}
+
+// Suggest returns up to limit existing term names that a missed lookup of
+// term probably meant: names starting with it first, then names within a
+// few edits of it, closest first. The query is folded the way lookups fold
+// it, and short queries allow a single edit so that "go" doesn't suggest
+// every two-letter term.
+func (s *MemoryStore) Suggest(term string, limit int) []string {
+	folded := strings.ToLower(displayName(norm.NFC.String(term)))
+	if folded == "" || limit <= 0 {
+		return []string{}
+	}
+	q := []rune(folded)
+	maxDistance := DefaultFuzzyDistance
+	if len(q) < 5 {
+		maxDistance = 1
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := []string{}
+	seen := map[string]bool{}
+	for i := sort.SearchStrings(s.folded, folded); i < len(s.keys) && len(names) < limit; i++ {
+		if !strings.HasPrefix(s.folded[i], folded) {
+			break
+		}
+		names = append(names, s.keys[i])
+		seen[s.keys[i]] = true
+	}
+	if len(names) == limit {
+		return names
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var nearby []candidate
+	for i, name := range s.keys {
+		f := []rune(s.folded[i])
+		if seen[name] || abs(len(f)-len(q)) > maxDistance {
+			continue
+		}
+		if d := levenshtein(q, f, maxDistance); d <= maxDistance {
+			nearby = append(nearby, candidate{name, d})
+		}
+	}
+	// keys are already alphabetical, so a stable sort keeps that order
+	// within each distance
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].distance < nearby[j].distance
+	})
+	for _, c := range nearby[:min(len(nearby), limit-len(names))] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// levenshtein computes the edit distance between a and b. Once every cell
+// in a row exceeds limit the exact value no longer matters, so it gives up
+// early and returns limit+1.
+func levenshtein(a, b []rune, limit int) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// snippetLength is the size, in runes, of the window of definition text
+// returned with each search hit.
+const snippetLength = 150
+
+// Highlighter wraps matched text in a snippet. Leaving both markers empty
+// disables highlighting.
+type Highlighter struct {
+	Pre  string
+	Post string
+}
+
+var DefaultHighlighter = Highlighter{Pre: "<mark>", Post: "</mark>"}
+
+// Snippet returns the sentence around the first match of m in definition,
+// or a window of snippetLength runes if that sentence is too long, with the
+// match highlighted. Definitions that don't contain the match (the hit came
+// from the term name) get their opening text instead. Everything works on
+// runes so a multi-byte character is never split.
+func Snippet(definition string, m Matcher, hl Highlighter) string {
+	text := []rune(definition)
+	start, end, found := m.locate(text)
+	if !found {
+		if len(text) <= snippetLength {
+			return definition
+		}
+		return string(text[:snippetLength]) + "…"
+	}
+
+	from, to := sentenceAround(text, start, end)
+	clipped := to-from > snippetLength
+	if clipped {
+		from = max(0, start-(snippetLength-(end-start))/2)
+		to = min(len(text), max(from+snippetLength, end))
+	}
+
+	var b strings.Builder
+	if clipped && from > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(strings.TrimLeft(string(text[from:start]), " "))
+	b.WriteString(hl.Pre)
+	b.WriteString(string(text[start:end]))
+	b.WriteString(hl.Post)
+	b.WriteString(strings.TrimRight(string(text[end:to]), " "))
+	if clipped && to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// locate finds the earliest match of any part of the query in text and
+// returns its rune offsets. In exact mode only occurrences on word
+// boundaries count; a stemmed part also matches a word of the same stem.
+func (m Matcher) locate(text []rune) (int, int, bool) {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	start, end, found := 0, 0, false
+	for _, p := range m.parts {
+		if i, j, ok := m.locatePart(lower, []rune(p.text)); ok && (!found || i < start) {
+			start, end, found = i, j, true
+		}
+		if p.stem == "" {
+			continue
+		}
+		if i, j, ok := locateStem(lower, p.stem); ok && (!found || i < start) {
+			start, end, found = i, j, true
+		}
+	}
+	return start, end, found
+}
+
+// locateStem finds the first word in lower whose stem is stem.
+func locateStem(lower []rune, stem string) (int, int, bool) {
+	for i := 0; i < len(lower); {
+		if !isWordRune(lower[i]) {
+			i++
+			continue
+		}
+		end := i
+		for end < len(lower) && isWordRune(lower[end]) {
+			end++
+		}
+		if stemWord(string(lower[i:end])) == stem {
+			return i, end, true
+		}
+		i = end
+	}
+	return 0, 0, false
+}
+
+// locatePart finds the first match of part in lower.
+func (m Matcher) locatePart(lower, part []rune) (int, int, bool) {
+	for i := 0; i+len(part) <= len(lower); i++ {
+		if !slices.Equal(lower[i:i+len(part)], part) {
+			continue
+		}
+		end := i + len(part)
+		if m.exact && (i > 0 && isWordRune(lower[i-1]) ||
+			end < len(lower) && isWordRune(lower[end])) {
+			continue
+		}
+		return i, end, true
+	}
+	return 0, 0, false
+}
+
+// sentenceAround returns the bounds of the sentence containing text[start:end].
+func sentenceAround(text []rune, start, end int) (int, int) {
+	from := start
+	for from > 0 && !isSentenceEnd(text, from-1) {
+		from--
+	}
+	to := end
+	for to < len(text) && !isSentenceEnd(text, to-1) {
+		to++
+	}
+	return from, to
+}
+
+func isSentenceEnd(text []rune, i int) bool {
+	switch text[i] {
+	case '.', '!', '?':
+		return i+1 == len(text) || unicode.IsSpace(text[i+1])
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
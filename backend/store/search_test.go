@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"scrape_cp/scraper"
+)
+
+// newTestStore returns a store holding terms, as scraped from one source.
+func newTestStore(t testing.TB, terms map[string]string) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	scraped := make(map[string][]string, len(terms))
+	for name, def := range terms {
+		scraped[name] = []string{def}
+	}
+	s.Merge(scraped, scraper.Source{Name: "Test"})
+	return s
+}
+
+// search searches s with a context that is never done.
+func search(t testing.TB, s *MemoryStore, query string, opts SearchOptions) []TermResponse {
+	t.Helper()
+	results, err := s.Search(context.Background(), query, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+// names returns the names of results, in order.
+func names(results []TermResponse) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.Term
+	}
+	return out
+}
+
+func TestExactMatchesWholeWords(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		definition string
+		want       bool
+	}{
+		{"plural", "cache", "Data kept in caches.", false},
+		{"prefix of a word", "cache", "Cacheable responses.", false},
+		{"before a full stop", "cache", "Data kept in a cache.", true},
+		{"before a comma", "cache", "A cache, or buffer.", true},
+		{"in parentheses", "cache", "Fast memory (cache) near the CPU.", true},
+		{"after an apostrophe", "cache", "The CPU's cache.", true},
+		{"hyphenated", "cache", "A write-cache policy.", true},
+		{"quoted", "cache", `Called a "cache" in hardware.`, true},
+		{"phrase across punctuation", `"hash table"`, "A hash-table stores pairs.", true},
+		{"phrase with a word between", `"hash table"`, "A hash lookup table.", false},
+		{"case", "CACHE", "A cache.", true},
+		{"digits", "ipv6", "Unlike IPv6, IPv4 uses 32 bits.", true},
+		{"digits inside a word", "ipv", "Unlike IPv6, IPv4 uses 32 bits.", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.query, SearchOptions{Exact: true})
+			if err != nil {
+				t.Fatalf("NewMatcher(%q): %v", tt.query, err)
+			}
+			got := m.Rank("Entry", nil, tt.definition, FieldsDefinition) > 0
+			if got != tt.want {
+				t.Errorf("exact %q in %q matched = %v, want %v", tt.query, tt.definition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstringMatchesInsideWords(t *testing.T) {
+	m, err := NewMatcher("cache", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Rank("Entry", nil, "Data kept in caches.", FieldsDefinition) == 0 {
+		t.Error(`"cache" didn't match "caches" without exact`)
+	}
+}
+
+func TestSearchFields(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Tree":        "A hierarchical data structure of nodes.",
+		"Binary heap": "A complete binary tree kept in heap order.",
+		"Forest":      "A set of disjoint trees.",
+	})
+	tests := []struct {
+		fields SearchFields
+		exact  bool
+		want   []string
+	}{
+		{FieldsBoth, false, []string{"Tree", "Binary heap", "Forest"}},
+		{FieldsTerm, false, []string{"Tree"}},
+		{FieldsDefinition, false, []string{"Binary heap", "Forest"}},
+		{FieldsDefinition, true, []string{"Binary heap"}},
+		{FieldsBoth, true, []string{"Tree", "Binary heap"}},
+	}
+	for _, tt := range tests {
+		got := names(search(t, s, "tree", SearchOptions{Fields: tt.fields, Exact: tt.exact}))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search tree fields=%s exact=%v = %q, want %q", tt.fields, tt.exact, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Stack":          "A last-in, first-out collection.",
+		"Stack frame":    "The part of a call stack belonging to one call.",
+		"Call stack":     "A stack of the active subroutines of a program.",
+		"Recursion":      "A function calling itself, growing the stack each time.",
+		"Queue":          "A first-in, first-out collection.",
+		"Heap":           "Memory allocated at run time, unlike the stack.",
+		"Software stack": "The layers of software an application runs on.",
+	})
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Exact name, then names starting with the query, then names
+		// containing it, then definitions alone, alphabetically within each
+		{"stack", []string{"Stack", "Stack frame", "Call stack", "Software stack", "Heap", "Recursion"}},
+		{"STACK", []string{"Stack", "Stack frame", "Call stack", "Software stack", "Heap", "Recursion"}},
+		// Parts found in the name outrank parts found in the definition
+		{"call stack", []string{"Call stack", "Stack frame", "Recursion"}},
+		{"first-out", []string{"Queue", "Stack"}},
+		{"queue", []string{"Queue"}},
+	}
+	for _, tt := range tests {
+		got := names(search(t, s, tt.query, SearchOptions{}))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %q = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchScores(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Stack":       "A last-in, first-out collection.",
+		"Stack frame": "The part of a call stack belonging to one call.",
+		"Call stack":  "A stack of the active subroutines of a program.",
+		"Heap":        "Memory allocated at run time, unlike the stack.",
+	})
+	want := map[string]int{
+		"Stack":       scoreExactName,
+		"Stack frame": scorePrefixName,
+		"Call stack":  scoreSubstringName,
+		"Heap":        scoreDefinitionOnly,
+	}
+	results := search(t, s, "stack", SearchOptions{})
+	for _, r := range results {
+		if r.Score != want[r.Term] {
+			t.Errorf("%s scored %d, want %d", r.Term, r.Score, want[r.Term])
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d results, want %d", len(results), len(want))
+	}
+}
+
+func TestSearchQueries(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Hash table":    "A structure mapping keys to values through a hash function; a collision puts two keys in one bucket.",
+		"Hash function": "A function mapping data to fixed-size values.",
+		"Binary tree":   "A tree whose nodes have at most two children.",
+		"Heap":          "A binary tree kept in heap order.",
+		"Trie":          "A search tree over the characters of keys, unlike a binary search tree.",
+		"Bloom filter":  "A set that may report false positives, built from several hash functions.",
+	})
+	tests := []struct {
+		query string
+		op    SearchOp
+		want  []string
+	}{
+		// Every word must match, wherever it is
+		{"hash collision", OpAnd, []string{"Hash table"}},
+		{"binary tree", OpAnd, []string{"Binary tree", "Heap", "Trie"}},
+		{"tree binary", OpAnd, []string{"Binary tree", "Heap", "Trie"}},
+		// Any word may match
+		{"collision positives", OpOr, []string{"Bloom filter", "Hash table"}},
+		{"collision positives", OpAnd, nil},
+		// A phrase matches its words together and in order
+		{`"binary search tree"`, OpAnd, []string{"Trie"}},
+		{`"tree binary"`, OpAnd, nil},
+		{`"hash function"`, OpAnd, []string{"Hash function", "Bloom filter", "Hash table"}},
+		// Phrases and words combine like words do
+		{`"hash function" collision`, OpAnd, []string{"Hash table"}},
+		{`keys "search tree"`, OpAnd, []string{"Trie"}},
+		{`"search tree" positives`, OpOr, []string{"Bloom filter", "Trie"}},
+		// An unterminated quote runs to the end of the query
+		{`collision "two keys`, OpAnd, []string{"Hash table"}},
+		// Punctuation around words is dropped
+		{"(hash), collision!", OpAnd, []string{"Hash table"}},
+	}
+	for _, tt := range tests {
+		got := names(search(t, s, tt.query, SearchOptions{Op: tt.op}))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %s op=%s = %q, want %q", tt.query, tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyQueries(t *testing.T) {
+	for _, query := range []string{"", "   ", `""`, `" "`, "!!! ???", `"", ;`, "()"} {
+		if _, err := NewMatcher(query, SearchOptions{}); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("NewMatcher(%q) = %v, want ErrEmptyQuery", query, err)
+		}
+	}
+}
+
+// doneAfter is a context that is done once its Err has been checked
+// checks times.
+type doneAfter struct {
+	context.Context
+	checks int
+}
+
+func (c *doneAfter) Err() error {
+	if c.checks == 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestSearchStopsWhenDone(t *testing.T) {
+	s := NewMemoryStore()
+	s.Merge(generatedTerms(2000), scraper.Source{Name: "Test"})
+
+	// The fuzzy query is a name with a letter missing
+	misspelt := s.Snapshot()[0].Name[1:]
+	finds := map[string]func(context.Context) ([]TermResponse, error){
+		"search": func(ctx context.Context) ([]TermResponse, error) {
+			return s.Search(ctx, "cache", SearchOptions{})
+		},
+		"fuzzy": func(ctx context.Context) ([]TermResponse, error) {
+			return s.Fuzzy(ctx, misspelt, DefaultFuzzyDistance)
+		},
+	}
+	for name, find := range finds {
+		if results, err := find(context.Background()); err != nil || len(results) == 0 {
+			t.Fatalf("%s found %d terms: %v", name, len(results), err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if results, err := find(ctx); !errors.Is(err, context.Canceled) || results != nil {
+			t.Errorf("%s with a cancelled context = %d terms, %v; want context.Canceled", name, len(results), err)
+		}
+
+		// A context done partway through stops the search there
+		if results, err := find(&doneAfter{Context: context.Background(), checks: 1}); !errors.Is(err, context.Canceled) || results != nil {
+			t.Errorf("%s with a context done partway = %d terms, %v; want context.Canceled", name, len(results), err)
+		}
+	}
+}
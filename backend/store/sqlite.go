@@ -0,0 +1,249 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const DefaultDBFile = "terms.db"
+
+// sqliteSchema keeps the columns worth querying on their own, and the
+// whole Term as JSON for its senses, categories and aliases. folded is the
+// name lowercased the way the in-memory store folds it, so lookups and
+// searches ignore case beyond ASCII too. The index on it is created once
+// the column is known to exist; see addFoldedColumn.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS terms (
+	name       TEXT PRIMARY KEY,
+	folded     TEXT NOT NULL COLLATE NOCASE,
+	definition TEXT NOT NULL,
+	source     TEXT NOT NULL,
+	source_url TEXT NOT NULL DEFAULT '',
+	first_seen TEXT NOT NULL,
+	updated    TEXT NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// sqliteIndexes are created after any missing column has been added.
+// The folded column's NOCASE collation makes no difference to its already
+// lowercased values, but it lets a LIKE, which ignores ASCII case, use the
+// index for a prefix.
+const sqliteIndexes = `
+DROP INDEX IF EXISTS terms_name_nocase;
+CREATE INDEX IF NOT EXISTS terms_folded ON terms (folded);
+`
+
+// termOrder sorts like the in-memory store: by folded name, then exactly.
+const termOrder = `ORDER BY folded, name`
+
+// sqliteBackend keeps the terms in a SQLite database.
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+func openSQLite(path string) (*sqliteBackend, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	// SQLite allows one writer at a time, so share one connection rather
+	// than have writers fail with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating tables in %s: %w", path, err)
+	}
+	if err := addFoldedColumn(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("adding folded names to %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteIndexes); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating indexes in %s: %w", path, err)
+	}
+	return &sqliteBackend{db: db}, nil
+}
+
+// addFoldedColumn adds the folded column to a database created before it
+// existed, filling it in for the terms already there. SQLite's lower()
+// only folds ASCII, so the names are folded here instead.
+func addFoldedColumn(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('terms') WHERE name = 'folded'`).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`ALTER TABLE terms ADD COLUMN folded TEXT NOT NULL DEFAULT '' COLLATE NOCASE`); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT name FROM terms`)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := tx.Exec(`UPDATE terms SET folded = ? WHERE name = ?`, strings.ToLower(name), name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Upsert(terms []Term) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO terms (name, folded, definition, source, source_url, first_seen, updated, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			definition = excluded.definition,
+			source = excluded.source,
+			source_url = excluded.source_url,
+			first_seen = excluded.first_seen,
+			updated = excluded.updated,
+			data = excluded.data`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, t := range terms {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("encoding %q: %w", t.Name, err)
+		}
+		if _, err := stmt.Exec(t.Name, strings.ToLower(t.Name), t.Definition, t.Source, t.SourceURL,
+			t.FirstSeen.Format(time.RFC3339Nano), t.Updated.Format(time.RFC3339Nano), data); err != nil {
+			return fmt.Errorf("saving %q: %w", t.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Delete(name string) error {
+	_, err := b.db.Exec(`DELETE FROM terms WHERE name = ?`, name)
+	return err
+}
+
+// Get finds name ignoring case through the folded-name index, preferring
+// an exact match.
+func (b *sqliteBackend) Get(name string) (Term, bool, error) {
+	var data []byte
+	err := b.db.QueryRow(`SELECT data FROM terms WHERE folded = ? ORDER BY name = ? DESC, name LIMIT 1`,
+		strings.ToLower(name), name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Term{}, false, nil
+	}
+	if err != nil {
+		return Term{}, false, err
+	}
+	var t Term
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Term{}, false, fmt.Errorf("decoding %q: %w", name, err)
+	}
+	return t, true, nil
+}
+
+func (b *sqliteBackend) List(offset, limit int) ([]Term, error) {
+	return b.query(`SELECT data FROM terms `+termOrder+` LIMIT ? OFFSET ?`, limit, offset)
+}
+
+// Search matches query against the folded names with LIKE. A prefix
+// search is a range scan of the folded-name index; nothing indexes
+// substrings, so a substring search reads every name.
+func (b *sqliteBackend) Search(query string, substring bool, limit int) ([]Term, error) {
+	pattern := likeEscaper.Replace(strings.ToLower(query)) + "%"
+	if substring {
+		pattern = "%" + pattern
+	}
+	return b.query(`SELECT data FROM terms WHERE folded LIKE ? ESCAPE '\' `+termOrder+` LIMIT ?`, pattern, limit)
+}
+
+// likeEscaper escapes the characters LIKE treats specially.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (b *sqliteBackend) Count() (int, error) {
+	var n int
+	err := b.db.QueryRow(`SELECT COUNT(*) FROM terms`).Scan(&n)
+	return n, err
+}
+
+func (b *sqliteBackend) ScrapedAt() (time.Time, error) {
+	var value string
+	err := b.db.QueryRow(`SELECT value FROM meta WHERE key = 'last_scrape'`).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func (b *sqliteBackend) SetScraped(at time.Time, version uint64) error {
+	_, err := b.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('last_scrape', ?), ('version', ?)`,
+		at.Format(time.RFC3339Nano), strconv.FormatUint(version, 10))
+	return err
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
+
+// query runs a query selecting the data column and decodes each row.
+func (b *sqliteBackend) query(query string, args ...any) ([]Term, error) {
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []Term
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var t Term
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("decoding stored term: %w", err)
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
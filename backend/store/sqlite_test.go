@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// openTestSQLite opens a SQLite backend in a temporary file holding terms.
+func openTestSQLite(t *testing.T, terms ...Term) *sqliteBackend {
+	t.Helper()
+	b, err := openSQLite(filepath.Join(t.TempDir(), DefaultDBFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	if err := b.Upsert(terms); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// termNames returns the names of terms, in order.
+func termNames(terms []Term) []string {
+	out := make([]string, len(terms))
+	for i, t := range terms {
+		out[i] = t.Name
+	}
+	return out
+}
+
+func TestSQLiteGet(t *testing.T) {
+	b := openTestSQLite(t,
+		Term{Name: "Cache", Definition: "Fast memory."},
+		Term{Name: "CACHE", Definition: "The acronym, for the test."},
+		Term{Name: "Überlauf", Definition: "Overflow, in German."},
+	)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Cache", "Cache"},
+		{"CACHE", "CACHE"},
+		// Without an exact match, the first in order wins
+		{"cache", "CACHE"},
+		// Case is ignored beyond ASCII
+		{"ÜBERLAUF", "Überlauf"},
+		{"überlauf", "Überlauf"},
+	}
+	for _, tt := range tests {
+		got, found, err := b.Get(tt.name)
+		if err != nil || !found || got.Name != tt.want {
+			t.Errorf("Get(%q) = %q, %v, %v; want %q", tt.name, got.Name, found, err, tt.want)
+		}
+	}
+	if _, found, err := b.Get("Cach"); found || err != nil {
+		t.Errorf("Get(Cach) = %v, %v; want not found", found, err)
+	}
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	b := openTestSQLite(t,
+		Term{Name: "Hash table", Definition: "Keys to values."},
+		Term{Name: "Hash function", Definition: "Data to fixed-size values."},
+		Term{Name: "Consistent hashing", Definition: "Hashing that moves few keys."},
+		Term{Name: "100% CPU", Definition: "A busy processor."},
+		Term{Name: "snake_case", Definition: "Words joined by underscores."},
+		Term{Name: "Übersetzer", Definition: "Translator, in German."},
+	)
+	tests := []struct {
+		query     string
+		substring bool
+		limit     int
+		want      []string
+	}{
+		{"hash", false, -1, []string{"Hash function", "Hash table"}},
+		{"HASH", false, 1, []string{"Hash function"}},
+		{"hash", true, -1, []string{"Consistent hashing", "Hash function", "Hash table"}},
+		{"üBER", false, -1, []string{"Übersetzer"}},
+		// LIKE's wildcards are matched as themselves
+		{"100%", false, -1, []string{"100% CPU"}},
+		{"%", true, -1, []string{"100% CPU"}},
+		{"_", true, -1, []string{"snake_case"}},
+		{"e_c", true, -1, []string{"snake_case"}},
+	}
+	for _, tt := range tests {
+		got, err := b.Search(tt.query, tt.substring, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := termNames(got); !slices.Equal(names, tt.want) {
+			t.Errorf("Search(%q, substring=%v, %d) = %q, want %q", tt.query, tt.substring, tt.limit, names, tt.want)
+		}
+	}
+}
+
+func TestSQLitePrefixSearchUsesTheIndex(t *testing.T) {
+	b := openTestSQLite(t)
+	rows, err := b.db.Query(`EXPLAIN QUERY PLAN SELECT data FROM terms WHERE folded LIKE ? ESCAPE '\' `+termOrder+` LIMIT ?`, "hash%", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	if joined := strings.Join(plan, "; "); !strings.Contains(joined, "INDEX terms_folded (folded>? AND folded<?)") {
+		t.Errorf("a prefix search is planned as %q, not a range of the folded-name index", joined)
+	}
+}
+
+func TestSQLiteAddsFoldedNames(t *testing.T) {
+	// A database from before the folded column
+	path := filepath.Join(t.TempDir(), DefaultDBFile)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE terms (
+			name       TEXT PRIMARY KEY,
+			definition TEXT NOT NULL,
+			source     TEXT NOT NULL,
+			source_url TEXT NOT NULL DEFAULT '',
+			first_seen TEXT NOT NULL,
+			updated    TEXT NOT NULL,
+			data       TEXT NOT NULL
+		);
+		CREATE INDEX terms_name_nocase ON terms (name COLLATE NOCASE);
+		INSERT INTO terms VALUES ('Überlauf', 'Overflow.', 'Test', '', '', '', '{"term":"Überlauf","definition":"Overflow."}');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got, found, err := b.Get("ÜBERLAUF"); err != nil || !found || got.Name != "Überlauf" {
+		t.Errorf("Get(ÜBERLAUF) = %q, %v, %v after adding folded names", got.Name, found, err)
+	}
+	if err := b.Upsert([]Term{{Name: "Cache", Definition: "Fast memory."}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.Search("c", false, -1); err != nil || !slices.Equal(termNames(got), []string{"Cache"}) {
+		t.Errorf("Search(c) = %q, %v", termNames(got), err)
+	}
+}
+
+func TestSearchNamesRanksLikeSearch(t *testing.T) {
+	terms := map[string]string{
+		"Stack":          "A last-in, first-out collection.",
+		"Stack frame":    "The part of a call stack belonging to one call.",
+		"Call stack":     "A stack of the active subroutines of a program.",
+		"Heap":           "Memory allocated at run time, unlike the stack.",
+		"Software stack": "The layers of software an application runs on.",
+		"Hash table":     "Keys to values.",
+	}
+	s := newTestStore(t, terms)
+	b := openTestSQLite(t, s.Snapshot()...)
+
+	for _, q := range []struct {
+		query string
+		opts  SearchOptions
+	}{
+		{"stack", SearchOptions{}},
+		{"call stack", SearchOptions{}},
+		{"stack hash", SearchOptions{Op: OpOr}},
+		{"stack hash", SearchOptions{}},
+		{"stac", SearchOptions{Exact: true}},
+		{`"stack frame"`, SearchOptions{}},
+	} {
+		q.opts.Fields = FieldsTerm
+		got, err := SearchNames(context.Background(), b, q.query, q.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := search(t, s, q.query, q.opts); !slices.Equal(names(got), names(want)) {
+			t.Errorf("SearchNames(%q, %+v) = %q, Search found %q", q.query, q.opts, names(got), names(want))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"unicode/utf8"
+
+	"scrape_cp/scraper"
+)
+
+// lengthBuckets are the upper bounds, in characters, of the definition
+// length histogram; a last bucket holds everything longer.
+var lengthBuckets = []int{20, 50, 100, 200, 500, 1000}
+
+// extremeTerms is how many of the shortest and longest definitions are
+// listed.
+const extremeTerms = 10
+
+// NearMinimumLength is the length, in characters, under which a definition
+// is counted as suspiciously close to the shortest one scraping keeps.
+// A source that suddenly has many of them is probably being truncated.
+const NearMinimumLength = 2 * scraper.MinDefinitionLength
+
+// DefinitionStats describes the shape of the stored definitions, for
+// spotting a source whose scraping has gone wrong.
+type DefinitionStats struct {
+	Terms   int           `json:"terms"`
+	Length  LengthStats   `json:"length"`
+	Buckets []LengthRange `json:"histogram"`
+	// Shortest and Longest list the terms with the shortest and longest
+	// primary definitions
+	Shortest []TermLength `json:"shortest"`
+	Longest  []TermLength `json:"longest"`
+	// NearMinimum counts the definitions shorter than NearMinimumLength
+	NearMinimum          int           `json:"near_minimum"`
+	NearMinimumThreshold int           `json:"near_minimum_threshold"`
+	Letters              []LetterCount `json:"letters"`
+}
+
+// LengthStats summarizes the lengths of the primary definitions, in
+// characters.
+type LengthStats struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+// LengthRange counts the definitions from Min to Max characters long;
+// Max is omitted for the last, open-ended range.
+type LengthRange struct {
+	Min   int  `json:"min"`
+	Max   *int `json:"max,omitempty"`
+	Count int  `json:"count"`
+}
+
+// TermLength is a term with the length of its primary definition.
+type TermLength struct {
+	Term   string `json:"term"`
+	Length int    `json:"length"`
+	Source string `json:"source,omitempty"`
+}
+
+// DefinitionStats returns the statistics of the primary definitions. They
+// are computed once per version of the store and shared until it changes,
+// so the result must not be modified.
+func (s *MemoryStore) DefinitionStats() DefinitionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.lazy.Lock()
+	defer s.lazy.Unlock()
+
+	if s.defStats == nil || s.defStatsAt != s.version {
+		stats := s.computeDefinitionStats()
+		s.defStats = &stats
+		s.defStatsAt = s.version
+	}
+	return *s.defStats
+}
+
+// computeDefinitionStats walks every term. Callers must hold s.mu for
+// reading.
+func (s *MemoryStore) computeDefinitionStats() DefinitionStats {
+	stats := DefinitionStats{
+		Terms:                len(s.keys),
+		Buckets:              make([]LengthRange, len(lengthBuckets)+1),
+		NearMinimumThreshold: NearMinimumLength,
+	}
+	low := 0
+	for i, high := range lengthBuckets {
+		stats.Buckets[i] = LengthRange{Min: low, Max: &lengthBuckets[i]}
+		low = high + 1
+	}
+	stats.Buckets[len(lengthBuckets)] = LengthRange{Min: low}
+
+	// keys are alphabetical, so a stable sort by length keeps ties in
+	// that order
+	lengths := make([]TermLength, 0, len(s.keys))
+	total := 0
+	for _, name := range s.keys {
+		t := s.terms[name]
+		n := utf8.RuneCountInString(t.Definition)
+		lengths = append(lengths, TermLength{Term: name, Length: n, Source: t.Source})
+		total += n
+
+		if n < NearMinimumLength {
+			stats.NearMinimum++
+		}
+		i, _ := slices.BinarySearch(lengthBuckets, n)
+		stats.Buckets[i].Count++
+	}
+	slices.SortStableFunc(lengths, func(a, b TermLength) int {
+		return cmp.Compare(a.Length, b.Length)
+	})
+
+	if n := len(lengths); n > 0 {
+		stats.Length = LengthStats{
+			Min:    lengths[0].Length,
+			Max:    lengths[n-1].Length,
+			Mean:   float64(total) / float64(n),
+			Median: float64(lengths[(n-1)/2].Length+lengths[n/2].Length) / 2,
+		}
+	}
+
+	stats.Shortest = slices.Clone(lengths[:min(extremeTerms, len(lengths))])
+	slices.SortStableFunc(lengths, func(a, b TermLength) int {
+		return cmp.Compare(b.Length, a.Length)
+	})
+	stats.Longest = lengths[:min(extremeTerms, len(lengths))]
+
+	stats.Letters = s.letterCounts()
+	return stats
+}
@@ -0,0 +1,183 @@
+package store
+
+import "strings"
+
+// stemRule replaces a suffix of a word when what precedes it qualifies.
+type stemRule struct {
+	suffix      string
+	replacement string
+}
+
+// The rules of each step of the Porter stemmer that only swap suffixes.
+// Only the first rule whose suffix a word ends in is considered, so
+// longer suffixes come before the shorter ones they end in.
+var (
+	stemStep2 = []stemRule{
+		{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+		{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+		{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+		{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+		{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+		{"logi", "log"},
+	}
+	stemStep3 = []stemRule{
+		{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+		{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+	}
+	stemStep4 = []stemRule{
+		{"al", ""}, {"ance", ""}, {"ence", ""}, {"er", ""}, {"ic", ""},
+		{"able", ""}, {"ible", ""}, {"ant", ""}, {"ement", ""}, {"ment", ""},
+		{"ent", ""}, {"ion", ""}, {"ou", ""}, {"ism", ""}, {"ate", ""},
+		{"iti", ""}, {"ous", ""}, {"ive", ""}, {"ize", ""},
+	}
+)
+
+// stemWord reduces a lowercase English word to its stem with the Porter
+// stemmer, so that "caching", "caches" and "cache" all become "cach". A
+// stem is only compared with other stems, never shown, so it needn't be a
+// word. Words of fewer than three letters and words with anything but the
+// letters a to z in them are returned as they are.
+func stemWord(word string) string {
+	if len(word) < 3 || strings.IndexFunc(word, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+		return word
+	}
+	w := word
+
+	// Step 1a: plurals
+	switch {
+	case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "ies"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	// Step 1b: past tenses and participles, tidying up what they leave
+	// so that "hoping" becomes "hope" and "hopping" "hop"
+	if stem, ok := strings.CutSuffix(w, "eed"); ok {
+		if measure(stem) > 0 {
+			w = stem + "ee"
+		}
+	} else if stem, ok := cutAnySuffix(w, "ed", "ing"); ok && hasVowel(stem) {
+		w = stem
+		switch {
+		case strings.HasSuffix(w, "at"), strings.HasSuffix(w, "bl"), strings.HasSuffix(w, "iz"):
+			w += "e"
+		case doubleConsonant(w) && !strings.ContainsAny(w[len(w)-1:], "lsz"):
+			w = w[:len(w)-1]
+		case measure(w) == 1 && endsCVC(w):
+			w += "e"
+		}
+	}
+
+	// Step 1c
+	if stem, ok := strings.CutSuffix(w, "y"); ok && hasVowel(stem) {
+		w = stem + "i"
+	}
+
+	w = applyStemRules(w, stemStep2, func(stem string) bool { return measure(stem) > 0 })
+	w = applyStemRules(w, stemStep3, func(stem string) bool { return measure(stem) > 0 })
+	w = applyStemRules(w, stemStep4, func(stem string) bool {
+		if measure(stem) <= 1 {
+			return false
+		}
+		// "ion" only goes after s or t, as in "adoption" but not "onion"
+		return !strings.HasSuffix(w, "ion") || strings.HasSuffix(stem, "s") || strings.HasSuffix(stem, "t")
+	})
+
+	// Step 5: a final e, and a double l
+	if stem, ok := strings.CutSuffix(w, "e"); ok {
+		if m := measure(stem); m > 1 || m == 1 && !endsCVC(stem) {
+			w = stem
+		}
+	}
+	if measure(w) > 1 && doubleConsonant(w) && strings.HasSuffix(w, "l") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+// stemWords stems each of words.
+func stemWords(words []string) []string {
+	stems := make([]string, len(words))
+	for i, word := range words {
+		stems[i] = stemWord(word)
+	}
+	return stems
+}
+
+// applyStemRules applies the first of rules whose suffix w ends in, if
+// qualifies accepts what comes before it.
+func applyStemRules(w string, rules []stemRule, qualifies func(stem string) bool) string {
+	for _, rule := range rules {
+		if stem, ok := strings.CutSuffix(w, rule.suffix); ok {
+			if qualifies(stem) {
+				return stem + rule.replacement
+			}
+			return w
+		}
+	}
+	return w
+}
+
+func cutAnySuffix(w string, suffixes ...string) (string, bool) {
+	for _, suffix := range suffixes {
+		if stem, ok := strings.CutSuffix(w, suffix); ok {
+			return stem, true
+		}
+	}
+	return w, false
+}
+
+// consonant reports whether w[i] is a consonant. A y is one unless it
+// follows a consonant, so it is in "toy" but not in "syzygy".
+func consonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the runs of vowels followed by consonants in w, which is
+// how the stemmer judges whether enough of a word is left to take a
+// suffix off it: 0 for "tree", 1 for "trouble", 2 for "troubles".
+func measure(w string) int {
+	m := 0
+	vowel := false
+	for i := range len(w) {
+		switch {
+		case !consonant(w, i):
+			vowel = true
+		case vowel:
+			m++
+			vowel = false
+		}
+	}
+	return m
+}
+
+func hasVowel(w string) bool {
+	for i := range len(w) {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether w ends in the same consonant twice.
+func doubleConsonant(w string) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && consonant(w, n-1)
+}
+
+// endsCVC reports whether w ends in a consonant, a vowel and a consonant
+// other than w, x or y, as short words like "hop" and "fil" do.
+func endsCVC(w string) bool {
+	n := len(w)
+	return n >= 3 && consonant(w, n-3) && !consonant(w, n-2) && consonant(w, n-1) &&
+		!strings.ContainsAny(w[n-1:], "wxy")
+}
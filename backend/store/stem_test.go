@@ -0,0 +1,110 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStemWord(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		// Forms of one word share a stem
+		{"cache", "cach"},
+		{"caches", "cach"},
+		{"cached", "cach"},
+		{"caching", "cach"},
+		{"hash", "hash"},
+		{"hashes", "hash"},
+		{"hashed", "hash"},
+		{"hashing", "hash"},
+		{"address", "address"},
+		{"addresses", "address"},
+		{"addressing", "address"},
+		{"compile", "compil"},
+		{"compiler", "compil"},
+		{"compilers", "compil"},
+		{"compiling", "compil"},
+		{"index", "index"},
+		{"indexes", "index"},
+		{"indexing", "index"},
+		{"thread", "thread"},
+		{"threading", "thread"},
+		{"sorted", "sort"},
+		{"sorting", "sort"},
+		{"class", "class"},
+		{"classes", "class"},
+		{"processing", "process"},
+		{"encryption", "encrypt"},
+		{"recursion", "recurs"},
+		{"recursive", "recurs"},
+
+		// Short words a naive stemmer would merge with longer ones are
+		// kept apart
+		{"has", "ha"},
+		{"add", "add"},
+		{"adding", "ad"},
+		{"point", "point"},
+		{"pointer", "pointer"},
+		{"route", "rout"},
+		{"router", "router"},
+		{"parse", "pars"},
+		{"parser", "parser"},
+		{"processor", "processor"},
+
+		// Known misses, pinned so a change to them is noticed
+		{"queueing", "queue"},
+		{"queuing", "queu"},
+		{"indices", "indic"},
+		{"bus", "bu"},
+		{"buses", "buse"},
+
+		// Words with anything but lowercase letters, and very short ones,
+		// are left alone
+		{"c++", "c++"},
+		{"ipv6", "ipv6"},
+		{"os", "os"},
+		{"Caching", "Caching"},
+	}
+	for _, tt := range tests {
+		if got := stemWord(tt.word); got != tt.want {
+			t.Errorf("stemWord(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStemmedSearch(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Cache":      "Fast memory holding recently used data.",
+		"Hash table": "A structure mapping keys to values.",
+		"Compiler":   "A program translating source code.",
+		"Address":    "Where something is kept in memory.",
+	})
+	tests := []struct {
+		query string
+		stem  bool
+		want  []string
+	}{
+		{"caching", true, []string{"Cache"}},
+		{"caching", false, nil},
+		{"hashing", true, []string{"Hash table"}},
+		{"compiling", true, []string{"Compiler"}},
+		{"addressing", true, []string{"Address"}},
+		// Phrases are never stemmed
+		{`"caching"`, true, nil},
+	}
+	for _, tt := range tests {
+		m, err := NewMatcher(tt.query, SearchOptions{Stem: tt.stem})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := names(search(t, s, tt.query, SearchOptions{Stem: tt.stem}))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %s stem=%v = %q, want %q", tt.query, tt.stem, got, tt.want)
+		}
+		if wantStemmed := tt.stem && tt.query[0] != '"'; m.Stemmed() != wantStemmed {
+			t.Errorf("search %s stem=%v reported stemmed = %v", tt.query, tt.stem, m.Stemmed())
+		}
+	}
+}
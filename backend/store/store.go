@@ -0,0 +1,892 @@
+// Package store keeps the scraped terms in memory, indexed for the lookups
+// the API serves, merging what each source supplies and optionally writing
+// every change through to a persistent backend.
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"scrape_cp/internal/events"
+	"scrape_cp/scraper"
+)
+
+// TermStore is a collection of terms that the API serves and scrapes are
+// merged into. MemoryStore is the implementation; the API only depends on
+// this, so handlers can be served from another one, such as a fake in a
+// test. The methods are documented on MemoryStore.
+type TermStore interface {
+	// Get looks a term up by its exact name, falling back to a
+	// case-insensitive match and then to its aliases
+	Get(term string) (Term, bool)
+	// Upsert adds terms as they are, replacing any stored under the same
+	// name. It fails if they couldn't be written through to the backend.
+	Upsert(terms []Term) error
+	// Delete removes a term by its exact name, reporting whether it was
+	// there
+	Delete(term string) (bool, error)
+	List(opts ListOptions) []TermResponse
+	Search(ctx context.Context, query string, opts SearchOptions) ([]TermResponse, error)
+	Len() int
+	// Snapshot returns a copy of every term in alphabetical order
+	Snapshot() []Term
+
+	// Merging scrapes
+	Merge(terms map[string][]string, src scraper.Source) (MergeCounts, error)
+	ScrapedFrom(source string) (map[string][]string, bool)
+	MarkScraped(at time.Time)
+	Link() error
+
+	// Lookups
+	Fuzzy(ctx context.Context, query string, maxDistance int) ([]TermResponse, error)
+	Suggest(term string, limit int) []string
+	Related(term string, limit int) (string, []TermResponse, bool)
+	Linkified(term, base string) (string, bool)
+	LinkifiedFunc(term string, href func(name string) string) (string, bool)
+	Pick(choose func(n int) []int) []TermResponse
+	After(cursor string, n int) []TermResponse
+	WithPrefix(prefix string, limit int) []string
+	Names(prefix, source string) []string
+	ByLetter(letter string) []TermResponse
+	LetterCounts() []LetterCount
+	ChangedSince(t time.Time, limit int) []Term
+	Definitions() map[string]string
+
+	// Describing the whole collection
+	DefinitionStats() DefinitionStats
+	SourceCounts() map[string]int
+	DataVersion() uint64
+	Events() *events.Hub[TermEvent]
+	Persistent() Backend
+}
+
+var _ TermStore = (*MemoryStore)(nil)
+
+// MemoryStore holds the scraped terms in memory together with a sorted
+// index of their names, so handlers that need ordering, prefix lookups or
+// random access don't have to walk the map on every request.
+type MemoryStore struct {
+	// mu is held for reading by every lookup, so they only wait on each
+	// other while a change is being made
+	mu    sync.RWMutex
+	terms map[string]Term
+
+	// keys holds the term names in case-insensitive alphabetical order and
+	// folded their lowercased forms at the same positions.
+	keys   []string
+	folded []string
+
+	// letters counts the terms in each alphabetical bucket
+	letters map[string]int
+
+	// aliases maps the normalized key of every variant of a name to the
+	// name the term is stored under
+	aliases map[string]string
+
+	// inverted indexes the words of every term for Search. It is kept up
+	// to date by every change, under the same lock.
+	inverted wordIndex
+
+	// scraped holds what each source supplied the last time it was
+	// merged, so it can be merged again when the source hasn't changed
+	scraped map[string]map[string][]string
+
+	// refs records which terms each definition mentions. It is rebuilt
+	// on first use after the store changes.
+	refs      crossRefs
+	refsStale bool
+
+	// linker finds mentions of terms in definitions. It is rebuilt by
+	// each linking pass.
+	linker *linker
+
+	// listing holds every term in alphabetical order as of listed, the
+	// version it was built at, so listings share one copy until the store
+	// changes. bySource splits it by the source of each term's definition,
+	// keyed by the source's lowercased name.
+	listing  []TermResponse
+	bySource map[string][]TermResponse
+	listed   uint64
+
+	// defStats holds the DefinitionStats as of defStatsAt, the version
+	// they were computed at
+	defStats   *DefinitionStats
+	defStatsAt uint64
+
+	// lazy guards refs, linker, the listings and defStats while a reader builds them
+	// under the read lock; writers hold mu for writing instead
+	lazy sync.Mutex
+
+	// backend is where changes are written through to, when the terms
+	// are kept beyond the process; writes serializes the writes to it
+	backend Backend
+	writes  sync.Mutex
+
+	// version is bumped on every change so clients can tell whether the
+	// data they hold is current.
+	version uint64
+
+	// events is told about every change
+	events *events.Hub[TermEvent]
+}
+
+// Term is a stored term: its definitions, with the primary one and the
+// source and page it came from copied to the top level, the categories of
+// every source that supplied it, other names it goes by, when it was first
+// seen and when it last changed. Links names the other terms its primary
+// definition mentions.
+type Term struct {
+	Name        string    `json:"term"`
+	Definition  string    `json:"definition"`
+	Source      string    `json:"source"`
+	SourceURL   string    `json:"source_url,omitempty"`
+	Definitions []Sense   `json:"definitions,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	Aliases     []string  `json:"aliases,omitempty"`
+	Links       []string  `json:"links,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	Updated     time.Time `json:"last_updated"`
+}
+
+// Sense is one definition of a term and where it was scraped from.
+type Sense struct {
+	Text      string `json:"text" xml:"text" yaml:"text"`
+	Source    string `json:"source" xml:"source" yaml:"source"`
+	SourceURL string `json:"source_url,omitempty" xml:"source_url,omitempty" yaml:"source_url,omitempty"`
+}
+
+type TermResponse struct {
+	Term       string `json:"term" xml:"name" yaml:"term"`
+	Definition string `json:"definition" xml:"definition" yaml:"definition"`
+	Source     string `json:"source,omitempty" xml:"source,omitempty" yaml:"source,omitempty"`
+	SourceURL  string `json:"source_url,omitempty" xml:"source_url,omitempty" yaml:"source_url,omitempty"`
+	Snippet    string `json:"snippet,omitempty" xml:"snippet,omitempty" yaml:"snippet,omitempty"`
+	Score      int    `json:"score,omitempty" xml:"score,omitempty" yaml:"score,omitempty"`
+	Distance   *int   `json:"distance,omitempty" xml:"distance,omitempty" yaml:"distance,omitempty"`
+	// Categories lists the kinds of glossary the term was found in
+	Categories []string `json:"categories,omitempty" xml:"category,omitempty" yaml:"categories,omitempty"`
+	// Definitions lists every definition, longest first, when a single
+	// term is asked for
+	Definitions []Sense `json:"definitions,omitempty" xml:"sense,omitempty" yaml:"definitions,omitempty"`
+	// Links names the other terms the definition mentions, when a single
+	// term is asked for
+	Links []string `json:"links,omitempty" xml:"link,omitempty" yaml:"links,omitempty"`
+	// Aliases are other names the term goes by
+	Aliases     []string   `json:"aliases,omitempty" xml:"alias,omitempty" yaml:"aliases,omitempty"`
+	FirstSeen   *time.Time `json:"first_seen,omitempty" xml:"first_seen,omitempty" yaml:"first_seen,omitempty"`
+	LastUpdated *time.Time `json:"last_updated,omitempty" xml:"last_updated,omitempty" yaml:"last_updated,omitempty"`
+	// Candidates lists the Wikipedia articles the term might mean, when
+	// enriching it found a disambiguation page
+	Candidates []string `json:"candidates,omitempty" xml:"candidate,omitempty" yaml:"candidates,omitempty"`
+}
+
+type LetterCount struct {
+	Letter string `json:"letter"`
+	Count  int    `json:"count"`
+}
+
+// Kinds of TermEvent.
+const (
+	EventAdded   = "added"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// TermEvent describes one change to the store.
+type TermEvent struct {
+	Type       string `json:"type"`
+	Term       string `json:"term"`
+	Definition string `json:"definition,omitempty"`
+}
+
+// Detail returns the term as it is served on its own, with every
+// definition and the terms it links to.
+func (t Term) Detail() TermResponse {
+	response := t.Response()
+	response.Definitions = t.Definitions
+	response.Links = t.Links
+	return response
+}
+
+// Response returns the term as it is served in listings, with only its
+// primary definition.
+func (t Term) Response() TermResponse {
+	return TermResponse{
+		Term:        t.Name,
+		Definition:  t.Definition,
+		Source:      t.Source,
+		SourceURL:   t.SourceURL,
+		Categories:  t.Categories,
+		Aliases:     t.Aliases,
+		FirstSeen:   &t.FirstSeen,
+		LastUpdated: &t.Updated,
+	}
+}
+
+// withCategory returns categories with category added, keeping them sorted
+// and without duplicates. categories itself is left alone, as terms are
+// handed out to callers outside the lock.
+func withCategory(categories []string, category string) []string {
+	i, found := slices.BinarySearch(categories, category)
+	if found || category == "" {
+		return categories
+	}
+	return slices.Insert(slices.Clone(categories), i, category)
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		terms:    make(map[string]Term),
+		letters:  make(map[string]int),
+		aliases:  make(map[string]string),
+		inverted: newWordIndex(),
+		scraped:  make(map[string]map[string][]string),
+		events:   events.NewHub[TermEvent](),
+	}
+}
+
+// set inserts or replaces a term's definitions, the first of which is its
+// primary one, and its aliases, and adds category to those it already
+// has. Callers must hold s.mu.
+func (s *MemoryStore) set(term string, senses []Sense, category string, aliases []string) {
+	now := time.Now()
+	event := TermEvent{Type: EventUpdated, Term: term, Definition: senses[0].Text}
+	existing, exists := s.terms[term]
+	firstSeen := existing.FirstSeen
+	if !exists {
+		event.Type = EventAdded
+		firstSeen = now
+		s.index(term)
+	}
+	s.terms[term] = Term{
+		Name:        term,
+		Definition:  senses[0].Text,
+		Source:      senses[0].Source,
+		SourceURL:   senses[0].SourceURL,
+		Definitions: senses,
+		Categories:  withCategory(existing.Categories, category),
+		Aliases:     aliases,
+		Links:       existing.Links,
+		FirstSeen:   firstSeen,
+		Updated:     now,
+	}
+	s.inverted.update(s.terms[term])
+	s.refsStale = true
+	s.version++
+	s.events.Publish(event)
+}
+
+// index adds a new term to the sorted names and letter counts. Callers
+// must hold s.mu.
+func (s *MemoryStore) index(term string) {
+	f := strings.ToLower(term)
+	i := sort.Search(len(s.keys), func(i int) bool {
+		return s.folded[i] > f || s.folded[i] == f && s.keys[i] >= term
+	})
+	s.keys = slices.Insert(s.keys, i, term)
+	s.folded = slices.Insert(s.folded, i, f)
+	s.letters[LetterOf(term)]++
+}
+
+// Upsert adds terms as they were saved, such as ones read back from a
+// snapshot, replacing any stored under the same name and writing them
+// through to the backend. Terms from the old flat format only have their
+// primary definition. It fails if the terms couldn't be written through,
+// though the store has them all the same.
+func (s *MemoryStore) Upsert(terms []Term) error {
+	s.mu.Lock()
+	return s.unlockAndPersist(s.upsert(terms))
+}
+
+// upsert is Upsert without the writing through, returning the terms as
+// stored. Callers must hold s.mu.
+func (s *MemoryStore) upsert(terms []Term) []Term {
+	loaded := make([]Term, 0, len(terms))
+	for _, t := range terms {
+		if len(t.Definitions) == 0 {
+			t.Definitions = []Sense{{Text: t.Definition, Source: t.Source, SourceURL: t.SourceURL}}
+		}
+		event := TermEvent{Type: EventUpdated, Term: t.Name, Definition: t.Definition}
+		if _, exists := s.terms[t.Name]; !exists {
+			event.Type = EventAdded
+			s.index(t.Name)
+		}
+		s.terms[t.Name] = t
+		s.inverted.update(t)
+		loaded = append(loaded, t)
+		s.aliases[normalizeKey(t.Name)] = t.Name
+		for _, alias := range t.Aliases {
+			if k := normalizeKey(alias); s.aliases[k] == "" {
+				s.aliases[k] = t.Name
+			}
+		}
+		s.events.Publish(event)
+	}
+	s.refsStale = true
+	s.version++
+	return loaded
+}
+
+// Merge adds terms scraped from src to the store. Names that only differ
+// in case, a trailing abbreviation or a plural ending are merged into one
+// term, with the other names and any acronym or synonym found for it kept
+// as its aliases. What src supplied replaces what it said before about
+// each term, and is settled with the definitions from other sources by
+// MergeStrategy. Every term is tagged with src's category. It returns what
+// the merge did, and fails as Upsert does if the changes couldn't be
+// written through.
+func (s *MemoryStore) Merge(terms map[string][]string, src scraper.Source) (counts MergeCounts, err error) {
+	s.mu.Lock()
+	var changed []Term
+	defer func() { err = s.unlockAndPersist(changed) }()
+
+	s.scraped[src.Name] = terms
+	groups := groupVariants(terms)
+	for key, variants := range groups {
+		if len(variants.defs) == 0 {
+			continue
+		}
+		name, exists := s.aliases[key]
+		if !exists {
+			name = variants.canonicalName(key)
+		}
+		existing := s.terms[name]
+		acronyms := acronymsFor(name, variants.names, variants.defs)
+		synonyms := s.claimSynonyms(name, key, groups, synonymsFor(name, variants.defs))
+		aliases := withAliases(existing.Aliases, name, slices.Concat(variants.names, acronyms, synonyms))
+		// An acronym only points at the term if nothing else goes by it
+		for _, acronym := range acronyms {
+			if k := normalizeKey(acronym); s.aliases[k] == "" {
+				s.aliases[k] = name
+			}
+		}
+
+		incoming := Term{Name: name}
+		for _, def := range variants.defs {
+			incoming.Definitions = append(incoming.Definitions, Sense{Text: def, Source: src.Name, SourceURL: src.URL})
+		}
+		// What src said before is replaced by what it says now
+		others := existing
+		others.Definitions = slices.DeleteFunc(slices.Clone(existing.Definitions), func(sense Sense) bool {
+			return sense.Source == src.Name
+		})
+		senses := MergeStrategy(others, incoming).Definitions
+		if len(others.Definitions) > 0 {
+			counts.Collided++
+		}
+
+		switch {
+		case !exists:
+			counts.Added++
+			s.aliases[key] = name
+		case !slices.Equal(senses, existing.Definitions):
+			counts.Updated++
+		default:
+			categories := withCategory(existing.Categories, src.Category)
+			if len(categories) != len(existing.Categories) || len(aliases) != len(existing.Aliases) {
+				existing.Categories = categories
+				existing.Aliases = aliases
+				s.terms[name] = existing
+				s.inverted.update(existing)
+				s.version++
+				s.events.Publish(TermEvent{Type: EventUpdated, Term: name, Definition: existing.Definition})
+				changed = append(changed, existing)
+			}
+			continue
+		}
+		s.set(name, senses, src.Category, aliases)
+		changed = append(changed, s.terms[name])
+	}
+	return counts, nil
+}
+
+// MergeCounts says what a Merge did: how many terms were new, how many had
+// their definitions changed, and how many another source had already
+// supplied, so that MergeStrategy settled their definitions.
+type MergeCounts struct {
+	Added    int
+	Updated  int
+	Collided int
+}
+
+// claimSynonyms registers synonyms as names for the term stored as name,
+// under key, and returns those it could. A synonym that is already
+// another term's name, or will be once groups are merged, is left to that
+// term. Callers must hold s.mu.
+func (s *MemoryStore) claimSynonyms(name, key string, groups map[string]*termVariants, synonyms []string) []string {
+	var claimed []string
+	for _, synonym := range synonyms {
+		k := normalizeKey(synonym)
+		if k == key {
+			continue
+		}
+		_, pending := groups[k]
+		if owner := s.aliases[k]; owner != "" && owner != name || owner == "" && pending {
+			slog.Warn("Not aliasing a term of its own", "alias", synonym, "term", name)
+			continue
+		}
+		s.aliases[k] = name
+		claimed = append(claimed, synonym)
+	}
+	return claimed
+}
+
+// ScrapedFrom returns the terms source supplied the last time it was
+// merged, if it has been.
+func (s *MemoryStore) ScrapedFrom(source string) (map[string][]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms, ok := s.scraped[source]
+	return terms, ok
+}
+
+// Get looks a term up by its exact name, falling back to a
+// case-insensitive match and then to its aliases.
+func (s *MemoryStore) Get(term string) (Term, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name, exists := s.lookup(term)
+	return s.terms[name], exists
+}
+
+// Delete removes the term stored under name, along with the aliases that
+// point at it, and deletes it from the backend. Like Upsert, it fails if
+// the backend couldn't be changed, though the store no longer has the term.
+func (s *MemoryStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	if !s.remove(name) {
+		s.mu.Unlock()
+		return false, nil
+	}
+
+	b := s.backend
+	s.writes.Lock()
+	defer s.writes.Unlock()
+	s.mu.Unlock()
+
+	if b != nil {
+		if err := b.Delete(name); err != nil {
+			return true, fmt.Errorf("deleting %q from the store: %w", name, err)
+		}
+	}
+	return true, nil
+}
+
+// remove is Delete without the deleting from the backend, reporting
+// whether there was a term to remove. Callers must hold s.mu.
+func (s *MemoryStore) remove(name string) bool {
+	t, exists := s.terms[name]
+	if !exists {
+		return false
+	}
+
+	delete(s.terms, name)
+	s.inverted.remove(name)
+	f := strings.ToLower(name)
+	i := sort.Search(len(s.keys), func(i int) bool {
+		return s.folded[i] > f || s.folded[i] == f && s.keys[i] >= name
+	})
+	s.keys = slices.Delete(s.keys, i, i+1)
+	s.folded = slices.Delete(s.folded, i, i+1)
+	if letter := LetterOf(name); s.letters[letter] > 1 {
+		s.letters[letter]--
+	} else {
+		delete(s.letters, letter)
+	}
+	for key, owner := range s.aliases {
+		if owner == name {
+			delete(s.aliases, key)
+		}
+	}
+	s.refsStale = true
+	s.linker = nil
+	s.version++
+	s.events.Publish(TermEvent{Type: EventDeleted, Term: name, Definition: t.Definition})
+	return true
+}
+
+// lookup finds the stored name for term, which may be any of its
+// aliases. Callers must hold s.mu.
+func (s *MemoryStore) lookup(term string) (string, bool) {
+	// Names are stored in NFC, so a decomposed accent must be composed to
+	// match
+	term = norm.NFC.String(term)
+	if _, exists := s.terms[term]; exists {
+		return term, true
+	}
+
+	f := strings.ToLower(term)
+	if i := sort.SearchStrings(s.folded, f); i < len(s.folded) && s.folded[i] == f {
+		return s.keys[i], true
+	}
+	name, exists := s.aliases[normalizeKey(term)]
+	return name, exists
+}
+
+// Events returns the hub told about every change to the store.
+func (s *MemoryStore) Events() *events.Hub[TermEvent] {
+	return s.events
+}
+
+// DataVersion counts the changes made to the store, so a listing can be
+// told apart from one taken before or after a change.
+func (s *MemoryStore) DataVersion() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.version
+}
+
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.terms)
+}
+
+// Definitions returns the primary definition of every term, to diff a
+// refresh against.
+func (s *MemoryStore) Definitions() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	defs := make(map[string]string, len(s.terms))
+	for name, t := range s.terms {
+		defs[name] = t.Definition
+	}
+	return defs
+}
+
+// FromSource keeps the terms whose definition came from the named source,
+// ignoring case. An empty name keeps them all.
+func FromSource(terms []TermResponse, source string) []TermResponse {
+	if source == "" {
+		return terms
+	}
+	return slices.DeleteFunc(terms, func(t TermResponse) bool {
+		return !strings.EqualFold(t.Source, source)
+	})
+}
+
+// LengthBounds bounds the length of a definition in runes, both ends
+// included. A zero Min or Max leaves that end open.
+type LengthBounds struct {
+	Min int
+	Max int
+}
+
+// Contains reports whether definition is within the bounds.
+func (lb LengthBounds) Contains(definition string) bool {
+	n := utf8.RuneCountInString(definition)
+	return n >= lb.Min && (lb.Max == 0 || n <= lb.Max)
+}
+
+// WithLength keeps the terms whose definition is within lb. The zero
+// bounds keep them all.
+func WithLength(terms []TermResponse, lb LengthBounds) []TermResponse {
+	if lb == (LengthBounds{}) {
+		return terms
+	}
+	return slices.DeleteFunc(terms, func(t TermResponse) bool {
+		return !lb.Contains(t.Definition)
+	})
+}
+
+// Snapshot returns a copy of every term in alphabetical order, for callers
+// that need a consistent view of the whole store without holding the lock.
+func (s *MemoryStore) Snapshot() []Term {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := make([]Term, len(s.keys))
+	for i, term := range s.keys {
+		terms[i] = s.terms[term]
+	}
+	return terms
+}
+
+// ChangedSince returns up to limit terms added or updated at or after t,
+// most recent first.
+func (s *MemoryStore) ChangedSince(t time.Time, limit int) []Term {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changed []Term
+	for _, term := range s.keys {
+		if e := s.terms[term]; !e.Updated.Before(t) {
+			changed = append(changed, e)
+		}
+	}
+	sort.SliceStable(changed, func(i, j int) bool {
+		return changed[i].Updated.After(changed[j].Updated)
+	})
+	return changed[:min(limit, len(changed))]
+}
+
+// Pick resolves positions chosen by choose against the sorted index. choose
+// is given the index size and runs under the lock, so the positions it
+// returns are always valid.
+func (s *MemoryStore) Pick(choose func(n int) []int) []TermResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexes := choose(len(s.keys))
+	picked := make([]TermResponse, 0, len(indexes))
+	for _, i := range indexes {
+		term := s.keys[i]
+		picked = append(picked, s.terms[term].Response())
+	}
+	return picked
+}
+
+// After returns up to n terms that come after cursor in alphabetical
+// order, so the store can be walked a chunk at a time without holding the
+// lock throughout. An empty cursor starts from the beginning.
+func (s *MemoryStore) After(cursor string, n int) []TermResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := 0
+	if cursor != "" {
+		f := strings.ToLower(cursor)
+		i = sort.Search(len(s.keys), func(i int) bool {
+			return s.folded[i] > f || s.folded[i] == f && s.keys[i] > cursor
+		})
+	}
+
+	terms := make([]TermResponse, 0, min(n, len(s.keys)-i))
+	for ; i < len(s.keys) && len(terms) < n; i++ {
+		term := s.keys[i]
+		terms = append(terms, s.terms[term].Response())
+	}
+	return terms
+}
+
+// WithPrefix returns up to limit term names starting with prefix, ignoring
+// case, in alphabetical order.
+func (s *MemoryStore) WithPrefix(prefix string, limit int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix = strings.ToLower(prefix)
+	names := []string{}
+	for i := sort.SearchStrings(s.folded, prefix); i < len(s.keys) && len(names) < limit; i++ {
+		if !strings.HasPrefix(s.folded[i], prefix) {
+			break
+		}
+		names = append(names, s.keys[i])
+	}
+	return names
+}
+
+// Names returns the names of the terms starting with prefix, ignoring
+// case, whose primary definition came from source, or any source if it is
+// empty, in alphabetical order.
+func (s *MemoryStore) Names(prefix, source string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix = strings.ToLower(prefix)
+	start := sort.SearchStrings(s.folded, prefix)
+	end := start + sort.Search(len(s.folded)-start, func(i int) bool {
+		return !strings.HasPrefix(s.folded[start+i], prefix)
+	})
+	if source == "" {
+		return slices.Clone(s.keys[start:end])
+	}
+
+	names := []string{}
+	for _, name := range s.keys[start:end] {
+		if strings.EqualFold(s.terms[name].Source, source) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// OtherBucket groups terms that start with a digit or symbol.
+const OtherBucket = "#"
+
+// LetterOf returns the alphabetical bucket a term is listed under: its
+// upper-cased first letter, or OtherBucket.
+func LetterOf(term string) string {
+	r, _ := utf8.DecodeRuneInString(term)
+	if !unicode.IsLetter(r) {
+		return OtherBucket
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// ByLetter returns the terms in a bucket in alphabetical order. Letter
+// buckets are a contiguous run of the sorted index; the catch-all bucket
+// is spread around it, so that one needs a walk over the index.
+func (s *MemoryStore) ByLetter(letter string) []TermResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := []TermResponse{}
+	if letter == OtherBucket {
+		for _, term := range s.keys {
+			if LetterOf(term) == OtherBucket {
+				terms = append(terms, s.terms[term].Response())
+			}
+		}
+		return terms
+	}
+
+	prefix := strings.ToLower(letter)
+	for i := sort.SearchStrings(s.folded, prefix); i < len(s.keys); i++ {
+		if !strings.HasPrefix(s.folded[i], prefix) {
+			break
+		}
+		term := s.keys[i]
+		terms = append(terms, s.terms[term].Response())
+	}
+	return terms
+}
+
+// LetterCounts returns the non-empty buckets with the catch-all bucket
+// first and letters in alphabetical order after it.
+func (s *MemoryStore) LetterCounts() []LetterCount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.letterCounts()
+}
+
+// letterCounts is LetterCounts for callers that hold s.mu for reading.
+func (s *MemoryStore) letterCounts() []LetterCount {
+	counts := make([]LetterCount, 0, len(s.letters))
+	for letter, n := range s.letters {
+		counts = append(counts, LetterCount{Letter: letter, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Letter == OtherBucket ||
+			counts[j].Letter != OtherBucket && counts[i].Letter < counts[j].Letter
+	})
+	return counts
+}
+
+// Orderings accepted by List.
+const (
+	SortAlpha     = "alpha"
+	SortAlphaDesc = "alpha_desc"
+	SortLength    = "length"
+	SortRecent    = "recent"
+)
+
+var SortOrders = []string{SortAlpha, SortAlphaDesc, SortLength, SortRecent}
+
+// ListOptions selects and orders the terms List returns.
+type ListOptions struct {
+	// Order is one of SortOrders; alphabetical if empty
+	Order string
+	// Source keeps only the terms whose definition came from the named
+	// source, ignoring case
+	Source string
+	// Length keeps only the terms whose definition is within it
+	Length LengthBounds
+}
+
+// List returns every term in the order opts asks for: alphabetical,
+// reverse alphabetical, longest definition first, or most recently
+// updated first. Ties are broken alphabetically so the order is stable
+// between requests. Alphabetical listings, of every term or of one
+// source's, are shared between callers until the store changes, so they
+// must not be modified.
+func (s *MemoryStore) List(opts ListOptions) []TermResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	listing := s.sourceListing(opts.Source)
+	var terms []TermResponse
+	switch {
+	case opts.Length != (LengthBounds{}):
+		// Only the terms that fit are copied
+		terms = make([]TermResponse, 0)
+		for _, t := range listing {
+			if opts.Length.Contains(t.Definition) {
+				terms = append(terms, t)
+			}
+		}
+	case opts.Order == "" || opts.Order == SortAlpha:
+		return listing
+	default:
+		terms = slices.Clone(listing)
+	}
+
+	switch opts.Order {
+	case SortAlphaDesc:
+		slices.Reverse(terms)
+	case SortLength:
+		sort.SliceStable(terms, func(i, j int) bool {
+			return utf8.RuneCountInString(terms[i].Definition) > utf8.RuneCountInString(terms[j].Definition)
+		})
+	case SortRecent:
+		sort.SliceStable(terms, func(i, j int) bool {
+			return s.terms[terms[i].Term].Updated.After(s.terms[terms[j].Term].Updated)
+		})
+	}
+	return terms
+}
+
+// sourceListing returns the shared alphabetical listing of the terms whose
+// definition came from source, ignoring case, or of every term if source
+// is empty. Callers must hold s.mu for reading.
+func (s *MemoryStore) sourceListing(source string) []TermResponse {
+	s.lazy.Lock()
+	defer s.lazy.Unlock()
+
+	s.buildListings()
+	if source == "" {
+		return s.listing
+	}
+	return s.bySource[strings.ToLower(source)]
+}
+
+// buildListings rebuilds the shared listings if the store has changed
+// since they were last built. Callers must hold s.mu for reading and
+// s.lazy.
+func (s *MemoryStore) buildListings() {
+	if s.listing != nil && s.listed == s.version {
+		return
+	}
+	s.listing = make([]TermResponse, 0, len(s.keys))
+	s.bySource = make(map[string][]TermResponse)
+	for _, term := range s.keys {
+		t := s.terms[term].Response()
+		s.listing = append(s.listing, t)
+		key := strings.ToLower(t.Source)
+		s.bySource[key] = append(s.bySource[key], t)
+	}
+	s.listed = s.version
+}
+
+// SourceCounts returns how many terms have their definition from each
+// source, by the source's name.
+func (s *MemoryStore) SourceCounts() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.lazy.Lock()
+	defer s.lazy.Unlock()
+
+	s.buildListings()
+	counts := make(map[string]int, len(s.bySource))
+	for _, terms := range s.bySource {
+		counts[terms[0].Source] = len(terms)
+	}
+	return counts
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrape_cp/internal/events"
+	"scrape_cp/scraper"
+)
+
+// received returns the events sub has been sent so far, as "type term".
+func received(sub *events.Subscriber[TermEvent]) []string {
+	var got []string
+	for {
+		select {
+		case e := <-sub.C:
+			got = append(got, e.Type+" "+e.Term)
+		default:
+			return got
+		}
+	}
+}
+
+func TestWritesPublishEvents(t *testing.T) {
+	s := NewMemoryStore()
+	sub := s.Events().Subscribe()
+	defer s.Events().Unsubscribe(sub)
+
+	tests := []struct {
+		name  string
+		write func()
+		want  []string
+	}{
+		{"merge adds", func() {
+			s.Merge(map[string][]string{"Cache": {"Fast memory."}}, scraper.Source{Name: "A"})
+		}, []string{"added Cache"}},
+		{"merge changes definitions", func() {
+			s.Merge(map[string][]string{"Cache": {"Small, fast memory."}}, scraper.Source{Name: "A"})
+		}, []string{"updated Cache"}},
+		{"merge only changes categories", func() {
+			s.Merge(map[string][]string{"Cache": {"Small, fast memory."}}, scraper.Source{Name: "A", Category: "Hardware"})
+		}, []string{"updated Cache"}},
+		{"merge changes nothing", func() {
+			s.Merge(map[string][]string{"Cache": {"Small, fast memory."}}, scraper.Source{Name: "A", Category: "Hardware"})
+		}, nil},
+		{"upsert", func() {
+			s.Upsert([]Term{
+				{Name: "Cache", Definition: "Memory close to the CPU."},
+				{Name: "Heap", Definition: "A tree kept in heap order."},
+			})
+		}, []string{"updated Cache", "added Heap"}},
+		{"delete", func() { s.Delete("Heap") }, []string{"deleted Heap"}},
+	}
+	for _, tt := range tests {
+		tt.write()
+		if got := received(sub); !slices.Equal(got, tt.want) {
+			t.Errorf("%s published %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// batch returns n terms, each named after prefix.
+func batch(prefix string, n int) map[string][]string {
+	terms := make(map[string][]string, n)
+	for i := range n {
+		terms[fmt.Sprintf("%s %04d", prefix, i)] = []string{fmt.Sprintf("Definition of %s number %d.", prefix, i)}
+	}
+	return terms
+}
+
+// TestReadsDuringMerges reads the store from several goroutines while
+// scrapes are merged into it; run it with -race. Every merge adds ten
+// terms at once, so no read may see part of one.
+func TestReadsDuringMerges(t *testing.T) {
+	s := NewMemoryStore()
+	const merges = 50
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan string, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				listed := s.List(ListOptions{Order: SortAlpha})
+				if len(listed)%10 != 0 {
+					errs <- fmt.Sprintf("listed %d terms, part of a merge", len(listed))
+					return
+				}
+				if !slices.IsSortedFunc(listed, func(a, b TermResponse) int {
+					return strings.Compare(strings.ToLower(a.Term), strings.ToLower(b.Term))
+				}) {
+					errs <- "listing out of order"
+					return
+				}
+				s.Search(context.Background(), "definition", SearchOptions{})
+				s.Get("Term 0001")
+				s.LetterCounts()
+				s.DefinitionStats()
+				s.Snapshot()
+			}
+		}()
+	}
+	for i := range merges {
+		s.Merge(batch(fmt.Sprint("Term ", i), 10), scraper.Source{Name: fmt.Sprint("Source ", i)})
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := s.Len(); n != merges*10 {
+		t.Errorf("store holds %d terms, want %d", n, merges*10)
+	}
+}
+
+// BenchmarkConcurrentReads measures read throughput with every
+// goroutine listing and looking up terms at once, with and without a
+// scrape being merged in the background.
+func BenchmarkConcurrentReads(b *testing.B) {
+	for _, merging := range []bool{false, true} {
+		b.Run(fmt.Sprint("merging=", merging), func(b *testing.B) {
+			s := NewMemoryStore()
+			s.Merge(batch("Term", 5000), scraper.Source{Name: "Test"})
+			done := make(chan struct{})
+			defer close(done)
+			if merging {
+				go func() {
+					for i := 0; ; i++ {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						s.Merge(batch("Other", 100), scraper.Source{Name: fmt.Sprint("Other ", i%2)})
+					}
+				}()
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.List(ListOptions{Order: SortAlpha})
+					s.Get("Term 2500")
+				}
+			})
+		})
+	}
+}
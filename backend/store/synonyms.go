@@ -0,0 +1,66 @@
+package store
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// synonymPatterns are the phrasings that introduce another name for a
+// term in its definition. Each captures the synonyms that follow, which
+// may be several joined by "or".
+var synonymPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:also|sometimes|often|commonly) (?:known as|called|referred to as|termed) ([^,.;:()]+)`),
+	regexp.MustCompile(`(?i)\botherwise known as ([^,.;:()]+)`),
+}
+
+// Synonyms longer than these are more likely the rest of a sentence than
+// a name.
+const (
+	maxSynonymWords = 5
+	maxSynonymLen   = 60
+)
+
+// synonymsFor returns the other names the definitions of name give it,
+// as in "Also known as a hash map" or "a queue, also called a FIFO".
+func synonymsFor(name string, defs []string) []string {
+	var synonyms []string
+	for _, def := range defs {
+		for _, pattern := range synonymPatterns {
+			for _, m := range pattern.FindAllStringSubmatch(def, -1) {
+				for _, candidate := range strings.Split(m[1], " or ") {
+					candidate = trimSynonym(candidate)
+					if plausibleSynonym(candidate) && !strings.EqualFold(candidate, name) {
+						synonyms = append(synonyms, candidate)
+					}
+				}
+			}
+		}
+	}
+	return synonyms
+}
+
+// synonymQuotes are the quotes a synonym may be written in.
+const synonymQuotes = ` "'“”‘’`
+
+// trimSynonym strips the quotes and leading article a synonym is often
+// written with, either of which may come first.
+func trimSynonym(s string) string {
+	s = strings.Trim(s, synonymQuotes)
+	for _, article := range []string{"a ", "an ", "the "} {
+		if len(s) > len(article) && strings.EqualFold(s[:len(article)], article) {
+			s = s[len(article):]
+			break
+		}
+	}
+	return strings.Trim(s, synonymQuotes)
+}
+
+// plausibleSynonym reports whether s is short enough to be a name and has
+// something to look up.
+func plausibleSynonym(s string) bool {
+	if len(s) > maxSynonymLen || len(strings.Fields(s)) > maxSynonymWords {
+		return false
+	}
+	return strings.ContainsFunc(s, unicode.IsLetter)
+}
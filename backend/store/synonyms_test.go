@@ -0,0 +1,75 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSynonymsFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		term       string
+		definition string
+		want       []string
+	}{
+		// One case per phrasing in synonymPatterns
+		{"also known as", "Hash table", "A map from keys to values, also known as a hash map.", []string{"hash map"}},
+		{"also called", "Queue", "A first-in, first-out list, also called a FIFO.", []string{"FIFO"}},
+		{"sometimes referred to as", "Heap", "Memory allocated at run time, sometimes referred to as the free store.", []string{"free store"}},
+		{"often termed", "Trie", "A search tree often termed a prefix tree.", []string{"prefix tree"}},
+		{"commonly known as", "Bug", "A defect in a program, commonly known as a glitch.", []string{"glitch"}},
+		{"otherwise known as", "Subroutine", "A callable unit of code, otherwise known as a procedure.", []string{"procedure"}},
+		{"at the start", "Hash table", "Also known as a dictionary. A map from keys to values.", []string{"dictionary"}},
+		{"several joined by or", "Stack", "A LIFO list, also called a pushdown store or a push-down list.", []string{"pushdown store", "push-down list"}},
+		{"quoted", "Daemon", `A background process, also called a "service".`, []string{"service"}},
+		{"in parentheses", "Stack", "A list (also called a LIFO) with one open end.", []string{"LIFO"}},
+
+		// Sanity checks
+		{"the term itself", "Cache", "A store of data, also called a cache.", nil},
+		{"too many words", "Mutex", "A lock, also called the thing that only one thread at a time may hold.", nil},
+		{"too long", "Lambda", "An anonymous function, also known as Supercalifragilisticexpialidocious-anonymous-function-literals.", nil},
+		{"no letters", "Year", "A span of time, also known as 365.", nil},
+		{"no phrasing", "Array", "A sequence of elements, known by index.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := synonymsFor(tt.term, []string{tt.definition})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("synonymsFor(%q, %q) = %q, want %q", tt.term, tt.definition, got, tt.want)
+			}
+		})
+	}
+
+	// A new phrasing needs a case of its own
+	for _, pattern := range synonymPatterns {
+		covered := false
+		for _, tt := range tests {
+			covered = covered || tt.want != nil && pattern.MatchString(tt.definition)
+		}
+		if !covered {
+			t.Errorf("no case finds a synonym with %s", pattern)
+		}
+	}
+}
+
+func TestMergeRegistersSynonyms(t *testing.T) {
+	s := newTestStore(t, map[string]string{
+		"Hash table": "A map from keys to values, also known as a hash map or a dictionary.",
+		"Dictionary": "A reference book listing the words of a language.",
+	})
+
+	if term, ok := s.Get("hash map"); !ok || term.Name != "Hash table" {
+		t.Errorf(`"hash map" resolved to %q, %v, want Hash table`, term.Name, ok)
+	}
+	// A synonym that is a term of its own stays that term
+	if term, _ := s.Get("dictionary"); term.Name != "Dictionary" {
+		t.Errorf(`"dictionary" resolved to %q, want Dictionary`, term.Name)
+	}
+	term, _ := s.Get("Hash table")
+	if !slices.Contains(term.Aliases, "hash map") || slices.Contains(term.Aliases, "dictionary") {
+		t.Errorf("Hash table's aliases = %q, want hash map but not dictionary", term.Aliases)
+	}
+	if got := names(search(t, s, "hash map", SearchOptions{})); len(got) == 0 || got[0] != "Hash table" {
+		t.Errorf(`search "hash map" = %q, want Hash table first`, got)
+	}
+}
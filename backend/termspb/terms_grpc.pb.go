@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: terms.proto
+
+package termspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Terms_GetTerm_FullMethodName   = "/scrape_cp.v1.Terms/GetTerm"
+	Terms_ListTerms_FullMethodName = "/scrape_cp.v1.Terms/ListTerms"
+	Terms_Search_FullMethodName    = "/scrape_cp.v1.Terms/Search"
+	Terms_Stats_FullMethodName     = "/scrape_cp.v1.Terms/Stats"
+)
+
+// TermsClient is the client API for Terms service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Terms serves the scraped glossary, mirroring the REST API.
+type TermsClient interface {
+	// GetTerm looks a term up by name, ignoring case.
+	GetTerm(ctx context.Context, in *GetTermRequest, opts ...grpc.CallOption) (*Term, error)
+	// ListTerms streams every term in the requested order.
+	ListTerms(ctx context.Context, in *ListTermsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Term], error)
+	// Search matches terms and definitions, best matches first.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Stats summarises the store.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+}
+
+type termsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTermsClient(cc grpc.ClientConnInterface) TermsClient {
+	return &termsClient{cc}
+}
+
+func (c *termsClient) GetTerm(ctx context.Context, in *GetTermRequest, opts ...grpc.CallOption) (*Term, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Term)
+	err := c.cc.Invoke(ctx, Terms_GetTerm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *termsClient) ListTerms(ctx context.Context, in *ListTermsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Term], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Terms_ServiceDesc.Streams[0], Terms_ListTerms_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListTermsRequest, Term]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Terms_ListTermsClient = grpc.ServerStreamingClient[Term]
+
+func (c *termsClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Terms_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *termsClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, Terms_Stats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TermsServer is the server API for Terms service.
+// All implementations must embed UnimplementedTermsServer
+// for forward compatibility.
+//
+// Terms serves the scraped glossary, mirroring the REST API.
+type TermsServer interface {
+	// GetTerm looks a term up by name, ignoring case.
+	GetTerm(context.Context, *GetTermRequest) (*Term, error)
+	// ListTerms streams every term in the requested order.
+	ListTerms(*ListTermsRequest, grpc.ServerStreamingServer[Term]) error
+	// Search matches terms and definitions, best matches first.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Stats summarises the store.
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	mustEmbedUnimplementedTermsServer()
+}
+
+// UnimplementedTermsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTermsServer struct{}
+
+func (UnimplementedTermsServer) GetTerm(context.Context, *GetTermRequest) (*Term, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTerm not implemented")
+}
+func (UnimplementedTermsServer) ListTerms(*ListTermsRequest, grpc.ServerStreamingServer[Term]) error {
+	return status.Errorf(codes.Unimplemented, "method ListTerms not implemented")
+}
+func (UnimplementedTermsServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedTermsServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedTermsServer) mustEmbedUnimplementedTermsServer() {}
+func (UnimplementedTermsServer) testEmbeddedByValue()               {}
+
+// UnsafeTermsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TermsServer will
+// result in compilation errors.
+type UnsafeTermsServer interface {
+	mustEmbedUnimplementedTermsServer()
+}
+
+func RegisterTermsServer(s grpc.ServiceRegistrar, srv TermsServer) {
+	// If the following call pancis, it indicates UnimplementedTermsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Terms_ServiceDesc, srv)
+}
+
+func _Terms_GetTerm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTermRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TermsServer).GetTerm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Terms_GetTerm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TermsServer).GetTerm(ctx, req.(*GetTermRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Terms_ListTerms_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTermsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TermsServer).ListTerms(m, &grpc.GenericServerStream[ListTermsRequest, Term]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Terms_ListTermsServer = grpc.ServerStreamingServer[Term]
+
+func _Terms_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TermsServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Terms_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TermsServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Terms_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TermsServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Terms_Stats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TermsServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Terms_ServiceDesc is the grpc.ServiceDesc for Terms service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Terms_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scrape_cp.v1.Terms",
+	HandlerType: (*TermsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTerm",
+			Handler:    _Terms_GetTerm_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Terms_Search_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Terms_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListTerms",
+			Handler:       _Terms_ListTerms_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "terms.proto",
+}
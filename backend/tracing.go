@@ -0,0 +1,87 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// serviceName is what traces call the binary unless OTEL_SERVICE_NAME
+// says otherwise.
+const serviceName = "scrape_cp"
+
+// tracingFlushTimeout is how long exiting waits for the spans still
+// queued to be exported.
+const tracingFlushTimeout = 5 * time.Second
+
+// startTracing sets up exporting traces over OTLP when an endpoint is
+// configured with OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT. The rest of the standard OTEL_*
+// variables choose the protocol, headers, sampler and resource; see the
+// README. It reports whether tracing is on, and returns a function that
+// flushes the spans still queued, to call before exiting. Without an
+// endpoint nothing is set up and every span is a no-op.
+func startTracing() (stop func(), enabled bool, err error) {
+	endpoint := cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"), os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	if endpoint == "" || strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") ||
+		os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return func() {}, false, nil
+	}
+
+	ctx := context.Background()
+	var exporter sdktrace.SpanExporter
+	protocol := cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"), os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"), "http/protobuf")
+	switch protocol {
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "http/protobuf":
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, false, fmt.Errorf("unsupported OTLP protocol %q; use grpc or http/protobuf", protocol)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("creating trace exporter: %w", err)
+	}
+
+	// The defaults come first so that OTEL_SERVICE_NAME and
+	// OTEL_RESOURCE_ATTRIBUTES override them
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("describing trace resource: %w", err)
+	}
+
+	// The sampler comes from OTEL_TRACES_SAMPLER, which the provider reads
+	// itself
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Tracing failed", "err", err)
+	}))
+	slog.Info("Exporting traces", "endpoint", endpoint, "protocol", protocol)
+
+	stop = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "err", err)
+		}
+	}
+	return stop, true, nil
+}